@@ -488,6 +488,7 @@ if [ "$1" = 'mysqld' -a -z "$wantHelp" ]; then
 			)"
 			wsrep_start_position_opt="--wsrep_start_position=$start_pos"
 			seqno=$(echo "$start_pos" | awk -F':' '{print $NF}' || :)
+			uuid=$(echo "$start_pos" | awk -F':' '{print $1}' || :)
 		else
 			# The server prints "..skipping position recovery.." if started without wsrep.
 			if grep 'skipping position recovery' "$wsrep_verbose_logfile"; then
@@ -526,6 +527,8 @@ if [ "$1" = 'mysqld' -a -z "$wantHelp" ]; then
 			if [[ -z ${seqno} ]]; then
 				seqno="-1"
 			fi
+			# the operator reads the node position from the file to choose the node the cluster is bootstrapped from
+			printf 'uuid: %s\nseqno: %s\nsafe_to_bootstrap: %s\n' "$uuid" "$seqno" "$safe_to_bootstrap" >/tmp/recovery-case
 
 			set +o xtrace
 			sleep 3
@@ -537,7 +540,7 @@ if [ "$1" = 'mysqld' -a -z "$wantHelp" ]; then
 			echo 'Cluster will recover automatically from the crash now.'
 			echo 'If you have set spec.pxc.autoRecovery to false, run the following command to recover manually from this node:'
 			echo "kubectl -n $POD_NAMESPACE exec $(hostname) -c pxc -- sh -c 'kill -s USR1 1'"
-			#DO NOT CHANGE THE LINE BELOW. OUR AUTO-RECOVERY IS USING IT TO DETECT SEQNO OF CURRENT NODE. See K8SPXC-564
+			#DO NOT CHANGE THE LINE BELOW. THE AUTO-RECOVERY OF THE PODS STARTED WITH THE OLDER ENTRYPOINT IS USING IT TO DETECT SEQNO OF CURRENT NODE. See K8SPXC-564
 			echo "#####################################################LAST_LINE:$NODE_NAME:$seqno:#####################################################"
 
 			for (( ; ; )) do
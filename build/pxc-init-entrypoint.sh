@@ -9,3 +9,6 @@ install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /pxc-configure-pxc.sh /var/lib/my
 install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /liveness-check.sh /var/lib/mysql/liveness-check.sh
 install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /readiness-check.sh /var/lib/mysql/readiness-check.sh
 install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /peer-list /var/lib/mysql/peer-list
+install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /replica-bootstrap.sh /var/lib/mysql/replica-bootstrap.sh
+install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /replica-entrypoint.sh /var/lib/mysql/replica-entrypoint.sh
+install -o "$(id -u)" -g "$(id -g)" -m 0755 -D /replica-check.sh /var/lib/mysql/replica-check.sh
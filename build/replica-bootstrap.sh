@@ -0,0 +1,35 @@
+#!/bin/bash
+
+set -o errexit
+set -o xtrace
+
+# Copies the data of a cluster node into the empty datadir of the read replica.
+# The node streams it with xtrabackup the same way as for the backup jobs.
+
+DATADIR=/var/lib/mysql
+BOOTSTRAP_DIR="$DATADIR/replica-bootstrap"
+
+if [ -d "$DATADIR/mysql" ]; then
+	echo "datadir is already initialized, skipping bootstrap"
+	exit 0
+fi
+
+rm -rf "$BOOTSTRAP_DIR"
+mkdir -p "$BOOTSTRAP_DIR"
+
+BACKUP_DIR="$BOOTSTRAP_DIR" bash /usr/bin/backup.sh
+
+mkdir -p "$BOOTSTRAP_DIR/data"
+xbstream -x -C "$BOOTSTRAP_DIR/data" <"$BOOTSTRAP_DIR/xtrabackup.stream"
+rm -f "$BOOTSTRAP_DIR/xtrabackup.stream"
+
+if find "$BOOTSTRAP_DIR/data" -name '*.qp' -o -name '*.zst' | grep -q .; then
+	xtrabackup --decompress --remove-original --target-dir="$BOOTSTRAP_DIR/data"
+fi
+xtrabackup --prepare --target-dir="$BOOTSTRAP_DIR/data"
+
+# the gtid set of the copy is applied by the replica entrypoint on the first start
+cp "$BOOTSTRAP_DIR/data/xtrabackup_binlog_info" "$DATADIR/replica-gtid-purged" || :
+shopt -s dotglob
+mv "$BOOTSTRAP_DIR"/data/* "$DATADIR"/
+rm -rf "$BOOTSTRAP_DIR"
@@ -0,0 +1,38 @@
+#!/bin/bash
+
+# liveness: the server responds
+# readiness: the server responds and both replication threads are running
+
+{ set +x; } 2>/dev/null
+MYSQL_USERNAME="${MYSQL_USERNAME:-monitor}"
+mysql_pass=$(cat /etc/mysql/mysql-users-secret/monitor || :)
+export MYSQL_PWD="${mysql_pass:-$MONITOR_PASSWORD}"
+DB_HOST=${HOSTNAME:-localhost}
+TIMEOUT=10
+
+MYSQL_CMDLINE="/usr/bin/timeout $TIMEOUT mysql -nNE --connect-timeout=$TIMEOUT -P 33062 -h${DB_HOST} --user=${MYSQL_USERNAME}"
+
+# SHOW REPLICA STATUS and the Replica_* columns replaced the SLAVE ones in 8.0.22
+MYSQL_VERSION=$(mysqld -V | awk '{print $3}' | cut -d'-' -f1)
+SHOW_STATUS='SHOW REPLICA STATUS;'
+if [ "$(printf '%s\n' "$MYSQL_VERSION" 8.0.22 | sort -V | head -n1)" != '8.0.22' ]; then
+	SHOW_STATUS='SHOW SLAVE STATUS;'
+fi
+
+case "$1" in
+liveness)
+	$MYSQL_CMDLINE -e 'SELECT 1;' >/dev/null
+	exit $?
+	;;
+readiness)
+	STATUS=$($MYSQL_CMDLINE -e "$SHOW_STATUS" | grep -E '^ *(Replica|Slave)_(IO|SQL)_Running:' | awk '{ print $2 }' | tr '\n' ' ')
+	if [[ $STATUS == 'Yes Yes ' ]]; then
+		exit 0
+	fi
+	exit 1
+	;;
+*)
+	echo "Usage: $0 liveness|readiness"
+	exit 1
+	;;
+esac
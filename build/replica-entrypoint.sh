@@ -0,0 +1,69 @@
+#!/bin/bash
+
+set -o errexit
+set -o xtrace
+
+# Starts the read replica: the PXC server without Galera in read-only mode.
+# The replication channel is configured by the operator.
+
+DATADIR=/var/lib/mysql
+SOCKET=/tmp/mysql.sock
+NODE_IP=$(hostname -I | awk ' { print $1 } ')
+# server_id has to be unique among the cluster nodes and the replicas
+SERVER_ID=$(echo -n "$HOSTNAME" | cksum | cut -d' ' -f1)
+# log_replica_updates replaced log_slave_updates in 8.0.26
+MYSQL_VERSION=$(mysqld -V | awk '{print $3}' | cut -d'-' -f1)
+LOG_REPLICA_UPDATES=--log-replica-updates=ON
+if [ "$(printf '%s\n' "$MYSQL_VERSION" 8.0.26 | sort -V | head -n1)" != '8.0.26' ]; then
+	LOG_REPLICA_UPDATES=--log-slave-updates=ON
+fi
+
+replica_opts=(
+	--wsrep-provider=none
+	--server-id="$SERVER_ID"
+	--read-only=ON
+	--super-read-only=ON
+	"$LOG_REPLICA_UPDATES"
+	--gtid-mode=ON
+	--enforce-gtid-consistency=ON
+	--admin-address="$NODE_IP"
+	--admin-port=33062
+	--relay-log="$HOSTNAME-relay-bin"
+)
+
+if [ -f "$DATADIR/replica-gtid-purged" ]; then
+	GTID_PURGED=$(awk '{ print $3 }' "$DATADIR/replica-gtid-purged" | tr -d '\n')
+
+	"$@" "${replica_opts[@]}" --skip-networking --socket="$SOCKET" --super-read-only=OFF &
+	pid="$!"
+
+	mysql=(mysql --protocol=socket -uoperator -hlocalhost --socket="$SOCKET")
+	{ set +x; } 2>/dev/null
+	export MYSQL_PWD="${OPERATOR_ADMIN_PASSWORD}"
+	set -x
+
+	for i in {120..0}; do
+		if echo 'SELECT 1' | "${mysql[@]}" &>/dev/null; then
+			break
+		fi
+		echo 'MySQL init process in progress...'
+		sleep 1
+	done
+	if [ "$i" = 0 ]; then
+		echo >&2 'MySQL init process failed.'
+		exit 1
+	fi
+
+	if [ -n "$GTID_PURGED" ]; then
+		echo "RESET MASTER; SET GLOBAL gtid_purged='$GTID_PURGED';" | "${mysql[@]}"
+	fi
+
+	if ! kill -s TERM "$pid" || ! wait "$pid"; then
+		echo >&2 'MySQL init process failed.'
+		exit 1
+	fi
+	unset MYSQL_PWD
+	rm -f "$DATADIR/replica-gtid-purged"
+fi
+
+exec "$@" "${replica_opts[@]}"
@@ -2,17 +2,18 @@ package collector
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"fmt"
-	"io"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/minio/minio-go/v7"
 	"github.com/pkg/errors"
 
@@ -23,10 +24,13 @@ import (
 type Collector struct {
 	db             *pxc.PXC
 	storage        storage.Storage
-	lastSet        string // last uploaded binary logs set
-	pxcServiceName string // k8s service name for PXC, its for get correct host for connection
-	pxcUser        string // user for connection to PXC
-	pxcPass        string // password for connection to PXC
+	lastSet        string        // last uploaded binary logs set
+	pxcServiceName string        // k8s service name for PXC, its for get correct host for connection
+	pxcUser        string        // user for connection to PXC
+	pxcPass        string        // password for connection to PXC
+	chunkSpan      time.Duration // how often collected transactions are uploaded
+	chunkSize      int64         // size after which collected transactions are uploaded regardless of chunkSpan
+	compression    string        // compression algorithm for uploaded binlogs
 }
 
 type Config struct {
@@ -40,13 +44,21 @@ type Config struct {
 	S3Region       string  `env:"DEFAULT_REGION,required"`
 	BufferSize     int64   `env:"BUFFER_SIZE"`
 	CollectSpanSec float64 `env:"COLLECT_SPAN_SEC" envDefault:"60"`
+	Compression    string  `env:"COMPRESSION"`
 }
 
 const (
 	lastSetFilePrefix string = "last-binlog-set-" // filename prefix for object where the last binlog set will stored
+	binlogPrefix      string = "binlog_"          // filename prefix for binlog chunks
 	gtidPostfix       string = "-gtid-set"        // filename postfix for files with GTID set
+
+	defaultChunkSize = 64 * 1024 * 1024
+	pxcPort          = 3306
 )
 
+// binlogMagic is the header every binary log file starts with
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
 func New(c Config) (*Collector, error) {
 	bucketArr := strings.Split(c.S3BucketURL, "/")
 	prefix := ""
@@ -59,10 +71,31 @@ func New(c Config) (*Collector, error) {
 		return nil, errors.Wrap(err, "new storage manager")
 	}
 
+	chunkSpan := time.Duration(c.CollectSpanSec * float64(time.Second))
+	// chunks are named by the timestamp (in seconds) of the first transaction,
+	// so they shouldn't be uploaded more often than once a second
+	if chunkSpan < time.Second {
+		chunkSpan = time.Second
+	}
+
+	switch c.Compression {
+	case storage.CompressionNone, storage.CompressionGzip, storage.CompressionZstd:
+	default:
+		return nil, errors.Errorf("unknown compression %s", c.Compression)
+	}
+
+	chunkSize := c.BufferSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
 	return &Collector{
 		storage:        s3,
 		pxcUser:        c.PXCUser,
 		pxcServiceName: c.PXCServiceName,
+		chunkSpan:      chunkSpan,
+		chunkSize:      chunkSize,
+		compression:    c.Compression,
 	}, nil
 }
 
@@ -128,13 +161,13 @@ func (c *Collector) close() error {
 	return c.db.Close()
 }
 
-func (c *Collector) CurrentSourceID(logs []pxc.Binlog) (string, error) {
+func (c *Collector) CurrentSourceID(logs []string) (string, error) {
 	var (
 		gtidSet string
 		err     error
 	)
 	for i := len(logs) - 1; i >= 0 && gtidSet == ""; i-- {
-		gtidSet, err = c.db.GetGTIDSet(logs[i].Name)
+		gtidSet, err = c.db.GetGTIDSet(logs[i])
 		if err != nil {
 			return gtidSet, err
 		}
@@ -142,260 +175,292 @@ func (c *Collector) CurrentSourceID(logs []pxc.Binlog) (string, error) {
 	return strings.Split(gtidSet, ":")[0], nil
 }
 
-func (c *Collector) removeEmptyBinlogs(logs []pxc.Binlog) ([]pxc.Binlog, error) {
-	result := make([]pxc.Binlog, 0)
-	for _, v := range logs {
-		set, err := c.db.GetGTIDSet(v.Name)
-		if err != nil {
-			return nil, errors.Wrap(err, "get GTID set")
-		}
-		// we don't upload binlog without gtid
-		// because it is empty and doesn't have any information
-		if set != "" {
-			v.GTIDSet = set
-			result = append(result, v)
-		}
-	}
-	return result, nil
-}
-
-func (c *Collector) filterBinLogs(logs []pxc.Binlog, lastBinlogName string) ([]pxc.Binlog, error) {
-	if lastBinlogName == "" {
-		return c.removeEmptyBinlogs(logs)
+// CollectBinLogs connects to PXC as a replica and streams binary log events
+// to the storage until the connection is broken
+func (c *Collector) CollectBinLogs() error {
+	list, err := c.db.GetBinLogNamesList()
+	if err != nil {
+		return errors.Wrap(err, "get binlog list")
 	}
 
-	logsLen := len(logs)
-
-	startIndex := 0
-	for logs[startIndex].Name != lastBinlogName && startIndex < logsLen {
-		startIndex++
+	sourceID, err := c.CurrentSourceID(list)
+	if err != nil {
+		return errors.Wrap(err, "get current source id")
 	}
 
-	if startIndex == logsLen {
-		return nil, nil
+	if sourceID != "" {
+		c.lastSet, err = c.lastGTIDSet(sourceID)
+		if err != nil {
+			return errors.Wrap(err, "get last uploaded gtid set")
+		}
 	}
 
-	set, err := c.db.GetGTIDSet(logs[startIndex].Name)
+	startSet, err := c.startGTIDSet()
 	if err != nil {
-		return nil, errors.Wrap(err, "get gtid set of last uploaded binlog")
-	}
-	// we don't need to reupload last file
-	// if gtid set is not changed
-	if set == c.lastSet {
-		startIndex++
+		return errors.Wrap(err, "get gtid set to start from")
+	}
+
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:        serverID(),
+		Flavor:          mysql.MySQLFlavor,
+		Host:            c.db.GetHost(),
+		Port:            pxcPort,
+		User:            c.pxcUser,
+		Password:        c.pxcPass,
+		RawModeEnabled:  true,
+		HeartbeatPeriod: c.chunkSpan,
+		// the stream is restarted from the last uploaded gtid set
+		// since raw mode doesn't track the current position
+		DisableRetrySync: true,
+	})
+	defer syncer.Close()
+
+	log.Println("Starting to stream binlogs after gtid set", startSet.String())
+
+	streamer, err := syncer.StartSyncGTID(startSet)
+	if err != nil {
+		return errors.Wrap(err, "start binlog stream")
 	}
 
-	return c.removeEmptyBinlogs(logs[startIndex:])
+	return c.stream(streamer)
 }
 
-func (c *Collector) CollectBinLogs() error {
-	list, err := c.db.GetBinLogList()
+// startGTIDSet returns the set of transactions the server shouldn't send,
+// i.e. everything up to the last uploaded transaction
+func (c *Collector) startGTIDSet() (*mysql.MysqlGTIDSet, error) {
+	purged, err := c.db.GetGTIDPurged()
 	if err != nil {
-		return errors.Wrap(err, "get binlog list")
+		return nil, errors.Wrap(err, "get purged gtid set")
 	}
 
-	sourceID, err := c.CurrentSourceID(list)
+	purgedSet, err := mysql.ParseMysqlGTIDSet(purged)
 	if err != nil {
-		return errors.Wrap(err, "get current source id")
+		return nil, errors.Wrapf(err, "parse purged gtid set %s", purged)
 	}
 
-	if sourceID == "" {
-		log.Println("No binlogs to upload")
-		return nil
+	if c.lastSet == "" {
+		return purgedSet.(*mysql.MysqlGTIDSet), nil
 	}
 
-	c.lastSet, err = c.lastGTIDSet(sourceID)
+	lastSet, err := mysql.ParseMysqlGTIDSet(c.lastSet)
 	if err != nil {
-		return errors.Wrap(err, "get last uploaded gtid set")
+		return nil, errors.Wrapf(err, "parse last gtid set %s", c.lastSet)
 	}
 
-	lastUploadedBinlogName := ""
-
-	if c.lastSet != "" {
-		// get last uploaded binlog file name
-		lastUploadedBinlogName, err = c.db.GetBinLogName(c.lastSet)
-		if err != nil {
-			return errors.Wrap(err, "get last uploaded binlog name by gtid set")
-		}
-
-		if lastUploadedBinlogName == "" {
-			log.Println("Gap detected in the binary logs. Binary logs will be uploaded anyway, but full backup needed for consistent recovery.")
+	set := lastSet.(*mysql.MysqlGTIDSet)
+	for _, uuidSet := range set.Sets {
+		if len(uuidSet.Intervals) == 0 {
+			continue
 		}
+		uuidSet.Intervals = mysql.IntervalSlice{{Start: 1, Stop: uuidSet.Intervals[len(uuidSet.Intervals)-1].Stop}}
 	}
 
-	list, err = c.filterBinLogs(list, lastUploadedBinlogName)
-	if err != nil {
-		return errors.Wrap(err, "filter empty binlogs")
-	}
-
-	if len(list) == 0 {
-		log.Println("No binlogs to upload")
-		return nil
-	}
-
-	for _, binlog := range list {
-		err = c.manageBinlog(binlog)
+	if !set.Contain(purgedSet) {
+		log.Println("Gap detected in the binary logs. Binary logs will be uploaded anyway, but full backup needed for consistent recovery.")
+		err = set.Update(purged)
 		if err != nil {
-			return errors.Wrap(err, "manage binlog")
+			return nil, errors.Wrap(err, "add purged gtid set")
 		}
 	}
-	return nil
+
+	return set, nil
 }
 
-func mergeErrors(a, b error) error {
-	if a != nil && b != nil {
-		return errors.New(a.Error() + "; " + b.Error())
-	}
-	if a != nil {
-		return a
-	}
+// chunk is a set of complete transactions uploaded as a single binlog object
+type chunk struct {
+	buf       bytes.Buffer
+	gtidSet   *mysql.MysqlGTIDSet
+	firstTS   uint32
+	lastTS    uint32
+	startedAt time.Time
+}
+
+func (ch *chunk) empty() bool {
+	return ch.buf.Len() == 0
+}
 
-	return b
+// transaction holds the events of the transaction that is being received
+type transaction struct {
+	events [][]byte
+	gtid   string
+	ts     uint32
+	size   int
 }
 
-func (c *Collector) manageBinlog(binlog pxc.Binlog) (err error) {
+func (c *Collector) stream(streamer *replication.BinlogStreamer) error {
+	var (
+		fde *replication.FormatDescriptionEvent
+		// raw format description event is written
+		// at the beginning of each chunk
+		fdeRaw []byte
+		trx    *transaction
+		ch     = &chunk{}
+	)
 
-	binlogTmstmp, err := c.db.GetBinLogFirstTimestamp(binlog.Name)
-	if err != nil {
-		return errors.Wrapf(err, "get first timestamp for %s", binlog.Name)
-	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), c.chunkSpan)
+		ev, err := streamer.GetEvent(ctx)
+		cancel()
+		if err != nil && err != context.DeadlineExceeded {
+			return errors.Wrap(err, "get binlog event")
+		}
 
-	binlogName := fmt.Sprintf("binlog_%s_%x", binlogTmstmp, md5.Sum([]byte(binlog.GTIDSet)))
+		if ev != nil {
+			switch ev.Header.EventType {
+			case replication.FORMAT_DESCRIPTION_EVENT:
+				fde = ev.Event.(*replication.FormatDescriptionEvent)
+				fdeRaw = ev.RawData
+				continue
+			case replication.ROTATE_EVENT, replication.HEARTBEAT_EVENT, replication.PREVIOUS_GTIDS_EVENT, replication.STOP_EVENT:
+				continue
+			case replication.GTID_EVENT:
+				gtid, err := decodeGTID(eventBody(fde, ev.RawData))
+				if err != nil {
+					return errors.Wrap(err, "decode gtid event")
+				}
+				trx = &transaction{gtid: gtid, ts: ev.Header.Timestamp}
+			}
 
-	var setBuffer bytes.Buffer
-	// no error handling because WriteString() always return nil error
-	// nolint:errcheck
-	setBuffer.WriteString(binlog.GTIDSet)
+			if trx == nil {
+				// event is outside of transaction
+				continue
+			}
 
-	tmpDir := os.TempDir() + "/"
+			trx.events = append(trx.events, ev.RawData)
+			trx.size += len(ev.RawData)
 
-	err = os.Remove(tmpDir + binlog.Name)
-	if err != nil && !os.IsNotExist(err) {
-		return errors.Wrap(err, "remove temp file")
-	}
+			if !isTrxEnd(fde, ev) {
+				continue
+			}
 
-	err = syscall.Mkfifo(tmpDir+binlog.Name, 0666)
-	if err != nil {
-		return errors.Wrap(err, "make named pipe file error")
-	}
+			if ch.empty() {
+				ch.buf.Write(binlogMagic)
+				ch.buf.Write(fdeRaw)
+				ch.gtidSet = &mysql.MysqlGTIDSet{Sets: make(map[string]*mysql.UUIDSet)}
+				ch.firstTS = trx.ts
+				ch.lastTS = trx.ts
+				ch.startedAt = time.Now()
+			}
+			for _, e := range trx.events {
+				ch.buf.Write(e)
+			}
+			err = ch.gtidSet.Update(trx.gtid)
+			if err != nil {
+				return errors.Wrapf(err, "add gtid %s to chunk", trx.gtid)
+			}
+			// timestamps of the transactions aren't monotonic,
+			// e.g. the long transaction has the time of its start
+			if trx.ts < ch.firstTS {
+				ch.firstTS = trx.ts
+			}
+			if trx.ts > ch.lastTS {
+				ch.lastTS = trx.ts
+			}
+			trx = nil
+		}
 
-	errBuf := &bytes.Buffer{}
-	cmd := exec.Command("mysqlbinlog", "-R", "--raw", "-h"+c.db.GetHost(), "-u"+c.pxcUser, binlog.Name)
-	cmd.Env = append(cmd.Env, "MYSQL_PWD="+c.pxcPass)
-	cmd.Dir = os.TempDir()
-	cmd.Stderr = errBuf
+		if ch.empty() {
+			continue
+		}
 
-	err = cmd.Start()
-	if err != nil {
-		return errors.Wrap(err, "run mysqlbinlog command")
+		if time.Since(ch.startedAt) >= c.chunkSpan ||
+			int64(ch.buf.Len()) >= c.chunkSize && ch.lastTS > ch.firstTS {
+			err = c.uploadChunk(ch)
+			if err != nil {
+				return errors.Wrap(err, "upload chunk")
+			}
+			ch = &chunk{}
+		}
 	}
+}
 
-	log.Println("Starting to process binlog with name", binlog.Name)
+func (c *Collector) uploadChunk(ch *chunk) error {
+	// the timestamps of the transactions aren't monotonic, so the names are ordered
+	// only roughly, the recoverer orders the chunks by their GTID sets
+	gtidSet := ch.gtidSet.String()
+	binlogName := fmt.Sprintf("%s%d_%x", binlogPrefix, ch.firstTS, md5.Sum([]byte(gtidSet)))
 
-	file, err := os.OpenFile(tmpDir+binlog.Name, os.O_RDONLY, os.ModeNamedPipe)
+	data, err := storage.Compress(ch.buf.Bytes(), c.compression)
 	if err != nil {
-		return errors.Wrap(err, "open named pipe file error")
+		return errors.Wrap(err, "compress chunk")
 	}
 
-	defer func() {
-		errC := file.Close()
-		if errC != nil {
-			err = mergeErrors(err, errors.Wrapf(errC, "close tmp file for %s", binlog.Name))
-			return
-		}
-		errR := os.Remove(tmpDir + binlog.Name)
-		if errR != nil {
-			err = mergeErrors(err, errors.Wrapf(errR, "remove tmp file for %s", binlog.Name))
-			return
-		}
-	}()
-
-	// create a pipe to transfer data from the binlog pipe to s3
-	pr, pw := io.Pipe()
-
-	go readBinlog(file, pw, errBuf, binlog.Name)
-
-	err = c.storage.PutObject(binlogName, pr, -1)
+	// the manifest is stored first, so the binlog object without it
+	// can only be the one uploaded before manifests were introduced
+	err = storage.PutManifest(c.storage, binlogName, storage.NewManifest(data, c.compression))
 	if err != nil {
-		return errors.Wrapf(err, "put %s object", binlog.Name)
+		return errors.Wrap(err, "put manifest object")
 	}
 
-	log.Println("Successfully written binlog file", binlog.Name, "to s3 with name", binlogName)
-
-	err = cmd.Wait()
+	err = c.storage.PutObject(binlogName, bytes.NewReader(data), int64(len(data)))
 	if err != nil {
-		return errors.Wrap(err, "wait mysqlbinlog command error:"+errBuf.String())
+		return errors.Wrapf(err, "put %s object", binlogName)
 	}
 
-	err = c.storage.PutObject(binlogName+gtidPostfix, &setBuffer, int64(setBuffer.Len()))
+	log.Println("Successfully written binlog chunk", gtidSet, "to s3 with name", binlogName)
+
+	err = c.storage.PutObject(binlogName+gtidPostfix, strings.NewReader(gtidSet), int64(len(gtidSet)))
 	if err != nil {
 		return errors.Wrap(err, "put gtid-set object")
 	}
-	// no error handling because WriteString() always return nil error
-	// nolint:errcheck
-	setBuffer.WriteString(binlog.GTIDSet)
 
-	err = c.storage.PutObject(lastSetFilePrefix+strings.Split(binlog.GTIDSet, ":")[0], &setBuffer, int64(setBuffer.Len()))
+	err = c.storage.PutObject(lastSetFilePrefix+strings.Split(gtidSet, ":")[0], strings.NewReader(gtidSet), int64(len(gtidSet)))
 	if err != nil {
 		return errors.Wrap(err, "put last-set object")
 	}
-	c.lastSet = binlog.GTIDSet
+	c.lastSet = gtidSet
 
 	return nil
 }
 
-func readBinlog(file *os.File, pipe *io.PipeWriter, errBuf *bytes.Buffer, binlogName string) {
-	b := make([]byte, 10485760) //alloc buffer for 10mb
+// eventBody returns event data without the header and the checksum
+func eventBody(fde *replication.FormatDescriptionEvent, raw []byte) []byte {
+	body := raw[replication.EventHeaderSize:]
+	if fde != nil && fde.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		body = body[:len(body)-replication.BinlogChecksumLength]
+	}
+	return body
+}
 
-	// in case of binlog is slow and hasn't written anything to the file yet
-	// we have to skip this error and try to read again until some data appears
-	isEmpty := true
-	for {
-		if errBuf.Len() != 0 {
-			// stop reading since we receive error from binlog command in stderr
-			// no error handling because CloseWithError() always return nil error
-			// nolint:errcheck
-			pipe.CloseWithError(errors.Errorf("Error: mysqlbinlog %s", errBuf.String()))
-			return
-		}
-		n, err := file.Read(b)
-		if err == io.EOF {
-			// If we got EOF immediately after starting to read a file we should skip it since
-			// data has not appeared yet. If we receive EOF error after already got some data - then exit.
-			if isEmpty {
-				time.Sleep(10 * time.Millisecond)
-				continue
-			}
-			break
-		}
-		if err != nil && !strings.Contains(err.Error(), "file already closed") {
-			// no error handling because CloseWithError() always return nil error
-			// nolint:errcheck
-			pipe.CloseWithError(errors.Wrapf(err, "Error: reading named pipe for %s", binlogName))
-			return
-		}
-		if n == 0 {
-			time.Sleep(10 * time.Millisecond)
-			continue
-		}
-		_, err = pipe.Write(b[:n])
+func decodeGTID(body []byte) (string, error) {
+	e := &replication.GTIDEvent{}
+	err := e.Decode(body)
+	if err != nil {
+		return "", err
+	}
+
+	sid := e.SID
+	return fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], e.GNO), nil
+}
+
+// isTrxEnd checks if the event completes the transaction.
+// Transaction is finished by XID event, by XA_PREPARE event of the
+// prepared XA transaction or by any query event except BEGIN
+// (e.g. COMMIT or DDL statement).
+func isTrxEnd(fde *replication.FormatDescriptionEvent, ev *replication.BinlogEvent) bool {
+	switch ev.Header.EventType {
+	case replication.XID_EVENT, replication.XA_PREPARE_LOG_EVENT:
+		return true
+	case replication.QUERY_EVENT:
+		q := &replication.QueryEvent{}
+		err := q.Decode(eventBody(fde, ev.RawData))
 		if err != nil {
-			// no error handling because CloseWithError() always return nil error
-			// nolint:errcheck
-			pipe.CloseWithError(errors.Wrapf(err, "Error: write to pipe for %s", binlogName))
-			return
+			// treat undecodable query as a separate transaction
+			// not to hold the chunk forever
+			return true
 		}
-		isEmpty = false
-	}
-	// in case of any errors from mysqlbinlog it sends EOF to pipe
-	// to prevent this, need to check error buffer before closing pipe without error
-	if errBuf.Len() != 0 {
-		// no error handling because CloseWithError() always return nil error
-		// nolint:errcheck
-		pipe.CloseWithError(errors.New("mysqlbinlog error:" + errBuf.String()))
-		return
+		return !strings.EqualFold(strings.TrimSpace(string(q.Query)), "BEGIN")
 	}
-	// no error handling because Close() always return nil error
+
+	return false
+}
+
+// serverID returns replica server id that is unique for the collector pod
+func serverID() uint32 {
+	h := fnv.New32a()
+	hostname, _ := os.Hostname()
+	// no error handling because Write() always return nil error
 	// nolint:errcheck
-	pipe.Close()
+	h.Write([]byte(hostname))
+	return 1<<31 | h.Sum32()>>1
 }
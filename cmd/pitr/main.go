@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"time"
@@ -22,8 +24,10 @@ func main() {
 		runCollector()
 	case "recover":
 		runRecoverer()
+	case "validate":
+		runValidator()
 	default:
-		fmt.Fprintf(os.Stderr, "ERROR: unknown command \"%s\".\nCommands:\n  collect - collect binlogs\n  recover - recover from binlogs\n", command)
+		fmt.Fprintf(os.Stderr, "ERROR: unknown command \"%s\".\nCommands:\n  collect - collect binlogs\n  recover - recover from binlogs\n  validate - check backup and binlogs before restore\n", command)
 		os.Exit(1)
 	}
 }
@@ -62,6 +66,88 @@ func runRecoverer() {
 	if err != nil {
 		log.Fatalln("ERROR: recover:", err)
 	}
+
+	// the operator reports the stats in the restore status
+	out, err := json.Marshal(c.Stats())
+	if err != nil {
+		log.Fatalln("ERROR: marshal recovery stats:", err)
+	}
+	log.Println("recovery stats:", string(out))
+	err = ioutil.WriteFile(terminationLog, out, 0644)
+	if err != nil {
+		log.Fatalln("ERROR: write recovery stats:", err)
+	}
+}
+
+// terminationLog is the file that k8s reads container termination message from.
+// Its size is limited to 4096 bytes.
+const (
+	terminationLog        = "/dev/termination-log"
+	terminationLogMaxSize = 4096
+)
+
+func runValidator() {
+	v, err := validate()
+	if err != nil {
+		// the operator shows the reason in the restore status
+		msg := []byte(err.Error())
+		if len(msg) > terminationLogMaxSize {
+			msg = msg[:terminationLogMaxSize]
+		}
+		ioutil.WriteFile(terminationLog, msg, 0644)
+		log.Fatalln("ERROR: validate:", err)
+	}
+
+	// binlogs list isn't needed to validate the restore
+	if v.Plan != nil && len(v.Plan.Binlogs) > 2 {
+		v.Plan.Binlogs = []string{v.Plan.Binlogs[0], v.Plan.Binlogs[len(v.Plan.Binlogs)-1]}
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		log.Fatalln("ERROR: marshal validation result:", err)
+	}
+	log.Println("validation result:", string(out))
+
+	err = ioutil.WriteFile(terminationLog, out, 0644)
+	if err != nil {
+		log.Fatalln("ERROR: write validation result:", err)
+	}
+}
+
+func validate() (recoverer.Validation, error) {
+	backupConfig := recoverer.BackupS3{}
+	err := env.Parse(&backupConfig)
+	if err != nil {
+		return recoverer.Validation{}, fmt.Errorf("get backup storage config: %v", err)
+	}
+
+	log.Println("check backup", backupConfig.BackupDest)
+	v, err := recoverer.ValidateBackup(backupConfig)
+	if err != nil {
+		return v, fmt.Errorf("check backup %s: %v", backupConfig.BackupDest, err)
+	}
+
+	if len(os.Getenv("PITR_RECOVERY_TYPE")) == 0 {
+		return v, nil
+	}
+
+	config, err := getRecovererConfig()
+	if err != nil {
+		return v, fmt.Errorf("get recoverer config: %v", err)
+	}
+	c, err := recoverer.New(config)
+	if err != nil {
+		return v, fmt.Errorf("new recoverer: %v", err)
+	}
+	log.Println("check binlogs")
+	plan, err := c.Validate()
+	if err != nil {
+		return v, fmt.Errorf("check binlogs: %v", err)
+	}
+	v.Plan = &plan
+
+	return v, nil
 }
 
 func getCollectorConfig() (collector.Config, error) {
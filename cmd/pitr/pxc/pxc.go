@@ -181,6 +181,30 @@ func (p *PXC) GetBinLogFirstTimestamp(binlog string) (string, error) {
 	return timestamp, nil
 }
 
+// GetGTIDPurged returns the set of transactions that were purged from the binary logs
+func (p *PXC) GetGTIDPurged() (string, error) {
+	var set string
+	row := p.db.QueryRow("SELECT @@GLOBAL.gtid_purged")
+	err := row.Scan(&set)
+	if err != nil {
+		return "", errors.Wrap(err, "scan gtid purged")
+	}
+
+	return set, nil
+}
+
+// GetGTIDExecuted returns the set of transactions executed on the server
+func (p *PXC) GetGTIDExecuted() (string, error) {
+	var set string
+	row := p.db.QueryRow("SELECT @@GLOBAL.gtid_executed")
+	err := row.Scan(&set)
+	if err != nil {
+		return "", errors.Wrap(err, "scan gtid executed")
+	}
+
+	return set, nil
+}
+
 func (p *PXC) SubtractGTIDSet(set, subSet string) (string, error) {
 	var result string
 	row := p.db.QueryRow("SELECT GTID_SUBTRACT(?,?)", set, subSet)
@@ -2,6 +2,7 @@ package recoverer
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
@@ -33,6 +34,19 @@ type Recoverer struct {
 	recoverFlag    string
 	recoverEndTime time.Time
 	gtid           string
+	stats          Stats
+}
+
+// progressPrefix marks the log lines with the recovery progress,
+// the operator reads them to report the progress while the recovery runs
+const progressPrefix = "progress: "
+
+// Stats describes the finished recovery
+type Stats struct {
+	BinlogsPlanned  int    `json:"binlogsPlanned"`
+	BinlogsApplied  int    `json:"binlogsApplied"`
+	BytesDownloaded int64  `json:"bytesDownloaded"`
+	LastGTID        string `json:"lastGTID,omitempty"`
 }
 
 type Config struct {
@@ -153,9 +167,24 @@ func getBucketAndPrefix(bucketURL string) (bucket string, prefix string, err err
 }
 
 func getStartGTIDSet(c BackupS3) (string, error) {
+	sstInfo, xtrabackupInfo, err := getBackupInfoObjects(c)
+	if err != nil {
+		return "", err
+	}
+
+	lastGTID, err := getLastBackupGTID(sstInfo, xtrabackupInfo)
+	if err != nil {
+		return "", errors.Wrap(err, "get last backup gtid")
+	}
+
+	return lastGTID, nil
+}
+
+// getBackupInfoObjects returns sst_info and xtrabackup_info objects of the backup
+func getBackupInfoObjects(c BackupS3) (sstInfo, xtrabackupInfo io.Reader, err error) {
 	bucketArr := strings.Split(c.BackupDest, "/")
 	if len(bucketArr) < 2 {
-		return "", errors.New("parsing bucket")
+		return nil, nil, errors.New("parsing bucket")
 	}
 
 	prefix := strings.TrimPrefix(c.BackupDest, bucketArr[0]+"/")
@@ -164,44 +193,104 @@ func getStartGTIDSet(c BackupS3) (string, error) {
 
 	s3, err := storage.NewS3(strings.TrimPrefix(strings.TrimPrefix(c.Endpoint, "https://"), "http://"), c.AccessKeyID, c.AccessKey, bucketArr[0], sstPrefix, c.Region, strings.HasPrefix(c.Endpoint, "https"))
 	if err != nil {
-		return "", errors.Wrap(err, "new storage manager")
+		return nil, nil, errors.Wrap(err, "new storage manager")
 	}
-	sstInfo, err := s3.ListObjects("sst_info")
+	sstInfoList, err := s3.ListObjects("sst_info")
 	if err != nil {
-		return "", errors.Wrapf(err, "list %s info fies", prefix)
+		return nil, nil, errors.Wrapf(err, "list %s info fies", prefix)
 	}
-	if len(sstInfo) == 0 {
-		return "", errors.New("no info files in sst dir")
+	if len(sstInfoList) == 0 {
+		return nil, nil, errors.New("no info files in sst dir")
 	}
-	sort.Strings(sstInfo)
+	sort.Strings(sstInfoList)
 
-	sstInfoObj, err := s3.GetObject(sstInfo[0])
+	sstInfo, err = s3.GetObject(sstInfoList[0])
 	if err != nil {
-		return "", errors.Wrapf(err, "get %s info", prefix)
+		return nil, nil, errors.Wrapf(err, "get %s info", prefix)
 	}
 
 	s3.SetPrefix(backupPrefix)
 
-	xtrabackupInfo, err := s3.ListObjects("xtrabackup_info")
+	xtrabackupInfoList, err := s3.ListObjects("xtrabackup_info")
 	if err != nil {
-		return "", errors.Wrapf(err, "list %s info fies", prefix)
+		return nil, nil, errors.Wrapf(err, "list %s info fies", prefix)
 	}
-	if len(xtrabackupInfo) == 0 {
-		return "", errors.New("no info files in backup")
+	if len(xtrabackupInfoList) == 0 {
+		return nil, nil, errors.New("no info files in backup")
 	}
-	sort.Strings(xtrabackupInfo)
+	sort.Strings(xtrabackupInfoList)
 
-	xtrabackupInfoObj, err := s3.GetObject(xtrabackupInfo[0])
+	xtrabackupInfo, err = s3.GetObject(xtrabackupInfoList[0])
 	if err != nil {
-		return "", errors.Wrapf(err, "get %s info", prefix)
+		return nil, nil, errors.Wrapf(err, "get %s info", prefix)
+	}
+
+	return sstInfo, xtrabackupInfo, nil
+}
+
+// Validation is the result of the checks made before the restore
+type Validation struct {
+	BackupServerVersion string `json:"backupServerVersion,omitempty"`
+	BackupSize          int64  `json:"backupSize,omitempty"`
+	Plan                *Plan  `json:"plan,omitempty"`
+}
+
+// ValidateBackup checks that the backup storage is reachable and the backup
+// has its info files. It returns the version of the server the backup was taken from.
+func ValidateBackup(c BackupS3) (Validation, error) {
+	if len(c.Endpoint) == 0 {
+		c.Endpoint = "s3.amazonaws.com"
 	}
 
-	lastGTID, err := getLastBackupGTID(sstInfoObj, xtrabackupInfoObj)
+	sstInfo, xtrabackupInfo, err := getBackupInfoObjects(c)
 	if err != nil {
-		return "", errors.Wrap(err, "get last backup gtid")
+		return Validation{}, err
 	}
 
-	return lastGTID, nil
+	// sst_info has to be readable too, it's needed to get the backup GTID
+	_, err = getDecompressedContent(sstInfo, "sst_info")
+	if err != nil {
+		return Validation{}, errors.Wrap(err, "get sst_info content")
+	}
+	content, err := getDecompressedContent(xtrabackupInfo, "xtrabackup_info")
+	if err != nil {
+		return Validation{}, errors.Wrap(err, "get xtrabackup info content")
+	}
+
+	version, err := getServerVersionFromXtrabackup(content)
+	if err != nil {
+		return Validation{}, err
+	}
+
+	size, err := getBackupSize(c)
+	if err != nil {
+		return Validation{}, errors.Wrap(err, "get backup size")
+	}
+
+	return Validation{BackupServerVersion: version, BackupSize: size}, nil
+}
+
+func getBackupSize(c BackupS3) (int64, error) {
+	bucketArr := strings.Split(c.BackupDest, "/")
+	prefix := strings.TrimPrefix(c.BackupDest, bucketArr[0]+"/") + "/"
+
+	s3, err := storage.NewS3(strings.TrimPrefix(strings.TrimPrefix(c.Endpoint, "https://"), "http://"), c.AccessKeyID, c.AccessKey, bucketArr[0], prefix, c.Region, strings.HasPrefix(c.Endpoint, "https"))
+	if err != nil {
+		return 0, errors.Wrap(err, "new storage manager")
+	}
+
+	return s3.ObjectsSize("")
+}
+
+func getServerVersionFromXtrabackup(content []byte) (string, error) {
+	for _, line := range strings.Split(string(content), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "server_version" {
+			return strings.TrimSpace(kv[1]), nil
+		}
+	}
+
+	return "", errors.New("no server_version in xtrabackup_info")
 }
 
 const (
@@ -212,6 +301,180 @@ const (
 )
 
 func (r *Recoverer) Run() error {
+	err := r.prepare()
+	if err != nil {
+		return err
+	}
+
+	err = r.recover()
+	if err != nil {
+		return errors.Wrap(err, "recover")
+	}
+
+	r.stats.LastGTID, err = r.db.GetGTIDExecuted()
+	if err != nil {
+		return errors.Wrap(err, "get executed gtid set")
+	}
+
+	return nil
+}
+
+// logProgress logs the stats of the binlogs applied so far
+func (r *Recoverer) logProgress() {
+	gtid, err := r.db.GetGTIDExecuted()
+	if err != nil {
+		log.Println("get executed gtid set:", err)
+	} else {
+		r.stats.LastGTID = gtid
+	}
+
+	out, err := json.Marshal(r.stats)
+	if err != nil {
+		log.Println("marshal progress:", err)
+		return
+	}
+	log.Println(progressPrefix + string(out))
+}
+
+// Stats returns the statistics of the recovery made by Run
+func (r *Recoverer) Stats() Stats {
+	return r.stats
+}
+
+// Plan is a description of the recovery that Run would do
+type Plan struct {
+	StartGTID    string   `json:"startGTID,omitempty"`
+	Binlogs      []string `json:"binlogs,omitempty"`
+	BinlogsCount int      `json:"binlogsCount,omitempty"`
+	EndPoint     string   `json:"endPoint,omitempty"`
+}
+
+// Plan calculates the recovery plan without applying anything to the database
+func (r *Recoverer) Plan() (Plan, error) {
+	err := r.prepare()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	binlogs, err := r.binlogsToApply()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{
+		StartGTID:    r.startGTID,
+		Binlogs:      binlogs,
+		BinlogsCount: len(binlogs),
+	}
+
+	switch r.recoverType {
+	case Date:
+		plan.EndPoint = r.recoverEndTime.Format(time.RFC3339)
+	case Transaction:
+		plan.EndPoint = "before " + r.gtid
+	case Skip, Latest:
+		if len(binlogs) == 0 {
+			break
+		}
+		infoObj, err := r.storage.GetObject(binlogs[len(binlogs)-1] + "-gtid-set")
+		if err != nil {
+			return Plan{}, errors.Wrap(err, "get last binlog gtid set")
+		}
+		content, err := ioutil.ReadAll(infoObj)
+		if err != nil {
+			return Plan{}, errors.Wrap(err, "read last binlog gtid set")
+		}
+		plan.EndPoint = string(content)
+		if r.recoverType == Skip {
+			plan.EndPoint += " excluding " + r.gtid
+		}
+	}
+
+	return plan, nil
+}
+
+// Validate checks that the stored binlogs cover the recover point
+// and returns the recovery plan
+func (r *Recoverer) Validate() (Plan, error) {
+	plan, err := r.Plan()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	switch r.recoverType {
+	case Date:
+		// the binlog that starts after the date guarantees that
+		// all transactions up to the date have been collected
+		if len(plan.Binlogs) == len(r.binlogs) {
+			last, err := getBinlogTime(r.binlogs[len(r.binlogs)-1])
+			if err != nil {
+				return Plan{}, err
+			}
+			return Plan{}, errors.Errorf("binlogs don't cover %s yet: the latest binlog starts at %s",
+				r.recoverEndTime.Format(time.RFC3339), time.Unix(last, 0).UTC().Format(time.RFC3339))
+		}
+	case Skip:
+		found := false
+		for _, binlog := range plan.Binlogs {
+			infoObj, err := r.storage.GetObject(binlog + "-gtid-set")
+			if err != nil {
+				return Plan{}, errors.Wrapf(err, "get %s gtid set", binlog)
+			}
+			content, err := ioutil.ReadAll(infoObj)
+			if err != nil {
+				return Plan{}, errors.Wrapf(err, "read %s gtid set", binlog)
+			}
+			sub, err := r.db.SubtractGTIDSet(r.gtid, string(content))
+			if err != nil {
+				return Plan{}, errors.Wrapf(err, "subtract '%s' from '%s'", string(content), r.gtid)
+			}
+			if sub != r.gtid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return Plan{}, errors.Errorf("no binlogs with %s", r.gtid)
+		}
+	}
+
+	return plan, nil
+}
+
+const dateFormat = "2006-01-02 15:04:05"
+
+// parseRecoverTime parses the recover date that is either
+// RFC3339 timestamp or "yyyy-mm-dd hh:mm:ss" in UTC
+func parseRecoverTime(date string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(dateFormat, date)
+}
+
+// binlogsToApply returns binlogs that have to be applied to reach the recover point
+func (r *Recoverer) binlogsToApply() ([]string, error) {
+	binlogs := []string{}
+	for _, binlog := range r.binlogs {
+		if r.recoverType == Date {
+			binlogTime, err := getBinlogTime(binlog)
+			if err != nil {
+				return nil, err
+			}
+			if binlogTime > r.recoverEndTime.Unix() {
+				break
+			}
+		}
+		binlogs = append(binlogs, binlog)
+	}
+
+	return binlogs, nil
+}
+
+// prepare connects to the database, gets binlogs list
+// and sets recover options according to the recover type
+func (r *Recoverer) prepare() error {
 	host, err := pxc.GetPXCFirstHost(r.pxcServiceName)
 	if err != nil {
 		return errors.Wrap(err, "get host")
@@ -232,24 +495,18 @@ func (r *Recoverer) Run() error {
 	case Transaction:
 		r.recoverFlag = " --exclude-gtids=" + r.gtidSet
 	case Date:
-		r.recoverFlag = ` --stop-datetime="` + r.recoverTime + `"`
-
-		const format = "2006-01-02 15:04:05"
-		endTime, err := time.Parse(format, r.recoverTime)
+		endTime, err := parseRecoverTime(r.recoverTime)
 		if err != nil {
 			return errors.Wrap(err, "parse date")
 		}
-		r.recoverEndTime = endTime
+		r.recoverEndTime = endTime.UTC()
+		// mysqlbinlog is run with TZ=UTC, see applyBinlog
+		r.recoverFlag = ` --stop-datetime="` + r.recoverEndTime.Format(dateFormat) + `"`
 	case Latest:
 	default:
 		return errors.New("wrong recover type")
 	}
 
-	err = r.recover()
-	if err != nil {
-		return errors.Wrap(err, "recover")
-	}
-
 	return nil
 }
 
@@ -258,48 +515,119 @@ func (r *Recoverer) recover() (err error) {
 	if err != nil {
 		return errors.Wrap(err, "drop collector funcs")
 	}
-	for _, binlog := range r.binlogs {
+
+	binlogs, err := r.binlogsToApply()
+	if err != nil {
+		return err
+	}
+
+	err = os.Setenv("MYSQL_PWD", os.Getenv("PXC_PASS"))
+	if err != nil {
+		return errors.Wrap(err, "set mysql pwd env var")
+	}
+
+	r.stats.BinlogsPlanned = len(binlogs)
+	for _, binlog := range binlogs {
 		log.Println("working with", binlog)
-		if r.recoverType == Date {
-			binlogArr := strings.Split(binlog, "_")
-			if len(binlogArr) < 2 {
-				return errors.New("get timestamp from binlog name")
-			}
-			binlogTime, err := strconv.ParseInt(binlogArr[1], 10, 64)
-			if err != nil {
-				return errors.Wrap(err, "get binlog time")
-			}
-			if binlogTime > r.recoverEndTime.Unix() {
-				return nil
-			}
+		err = r.applyBinlog(binlog)
+		if err != nil {
+			return errors.Wrapf(err, "apply binlog %s", binlog)
 		}
+		r.stats.BinlogsApplied++
+		r.logProgress()
+	}
+
+	return nil
+}
 
-		binlogObj, err := r.storage.GetObject(binlog)
+func (r *Recoverer) applyBinlog(binlog string) error {
+	m, err := storage.GetManifest(r.storage, binlog)
+	if err != nil {
+		return errors.Wrap(err, "get manifest")
+	}
+
+	obj, err := r.storage.GetObject(binlog)
+	if err != nil {
+		return errors.Wrap(err, "get obj")
+	}
+	var binlogObj io.Reader = &countingReader{r: obj, n: &r.stats.BytesDownloaded}
+
+	compression := storage.CompressionNone
+	if m != nil {
+		compression = m.Compression
+
+		// the object is downloaded once and checked while it's downloaded,
+		// the data piped into mysql is the verified local copy
+		tmp, err := ioutil.TempFile("", binlog)
 		if err != nil {
-			return errors.Wrap(err, "get obj")
+			return errors.Wrap(err, "create temp file")
 		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
 
-		err = os.Setenv("MYSQL_PWD", os.Getenv("PXC_PASS"))
+		_, err = io.Copy(tmp, m.Reader(binlogObj))
 		if err != nil {
-			return errors.Wrap(err, "set mysql pwd env var")
+			return errors.Wrap(err, "download and verify obj")
 		}
-
-		cmdString := "mysqlbinlog --disable-log-bin" + r.recoverFlag + " - | mysql -h" + r.db.GetHost() + " -u" + r.pxcUser
-		cmd := exec.Command("sh", "-c", cmdString)
-
-		cmd.Stdin = binlogObj
-		var outb, errb bytes.Buffer
-		cmd.Stdout = &outb
-		cmd.Stderr = &errb
-		err = cmd.Run()
+		_, err = tmp.Seek(0, io.SeekStart)
 		if err != nil {
-			return errors.Wrapf(err, "cmd run. stderr: %s, stdout: %s", errb.String(), outb.String())
+			return errors.Wrap(err, "seek temp file")
 		}
+		binlogObj = tmp
+	} else {
+		log.Println("no manifest for", binlog, "skipping integrity check")
+	}
+
+	data, err := storage.Decompress(binlogObj, compression)
+	if err != nil {
+		return errors.Wrap(err, "decompress obj")
+	}
+	defer data.Close()
+
+	cmdString := "mysqlbinlog --disable-log-bin" + r.recoverFlag + " - | mysql -h" + r.db.GetHost() + " -u" + r.pxcUser
+	cmd := exec.Command("sh", "-c", cmdString)
+	// --stop-datetime is interpreted in the local timezone
+	cmd.Env = append(os.Environ(), "TZ=UTC")
+
+	cmd.Stdin = data
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	err = cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "cmd run. stderr: %s, stdout: %s", errb.String(), outb.String())
 	}
 
 	return nil
 }
 
+// countingReader counts bytes read through it
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// getBinlogTime returns the timestamp of the first event
+// stored in the binlog object name
+func getBinlogTime(binlog string) (int64, error) {
+	binlogArr := strings.Split(binlog, "_")
+	if len(binlogArr) < 2 {
+		return 0, errors.New("get timestamp from binlog name")
+	}
+	binlogTime, err := strconv.ParseInt(binlogArr[1], 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "get binlog time")
+	}
+
+	return binlogTime, nil
+}
+
 func getLastBackupGTID(sstInfo, xtrabackupInfo io.Reader) (string, error) {
 	sstContent, err := getDecompressedContent(sstInfo, "sst_info")
 	if err != nil {
@@ -403,10 +731,11 @@ func (r *Recoverer) setBinlogs() error {
 	}
 	reverse(list)
 	binlogs := []string{}
+	gtidStarts := make(map[string]int64)
 	sourceID := strings.Split(r.startGTID, ":")[0]
 	log.Println("current gtid set is", r.startGTID)
 	for _, binlog := range list {
-		if strings.Contains(binlog, "-gtid-set") {
+		if strings.Contains(binlog, "-gtid-set") || strings.HasSuffix(binlog, storage.ManifestPostfix) {
 			continue
 		}
 		infoObj, err := r.storage.GetObject(binlog + "-gtid-set")
@@ -442,6 +771,10 @@ func (r *Recoverer) setBinlogs() error {
 			}
 		}
 
+		gtidStarts[binlog], err = gtidSetStart(binlogGTIDSet)
+		if err != nil {
+			return errors.Wrapf(err, "get start of %s gtid set", binlog)
+		}
 		binlogs = append(binlogs, binlog)
 		subResult, err := r.db.SubtractGTIDSet(r.startGTID, binlogGTIDSet)
 		log.Println("Checking sub result", " binlog gtid ", binlogGTIDSet, " sub result ", subResult)
@@ -456,11 +789,32 @@ func (r *Recoverer) setBinlogs() error {
 		return errors.Errorf("no objects for prefix binlog_ or with source_id=%s", sourceID)
 	}
 	reverse(binlogs)
+	// the names are ordered by the time of the first transaction that isn't monotonic,
+	// the transactions of the source are applied in the order of their GTIDs
+	sort.SliceStable(binlogs, func(i, j int) bool {
+		return gtidStarts[binlogs[i]] < gtidStarts[binlogs[j]]
+	})
 	r.binlogs = binlogs
 
 	return nil
 }
 
+// gtidSetStart returns the first transaction number of the source
+// that goes first in the GTID set, e.g. 11 for "uuid:11-20:25,uuid2:1-5"
+func gtidSetStart(set string) (int64, error) {
+	fields := strings.Split(strings.Split(strings.TrimSpace(set), ",")[0], ":")
+	if len(fields) < 2 {
+		return 0, errors.Errorf("no transactions in gtid set '%s'", set)
+	}
+
+	start, err := strconv.ParseInt(strings.Split(fields[1], "-")[0], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse gtid set '%s'", set)
+	}
+
+	return start, nil
+}
+
 func getExtendGTIDSet(gtidSet, gtid string) (string, error) {
 	if gtidSet == gtid {
 		return gtid, nil
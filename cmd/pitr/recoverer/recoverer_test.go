@@ -64,7 +64,7 @@ func TestGetGTIDFromContent(t *testing.T) {
 	c := []byte(`sometext GTID of the last set 'test_set:1-10'
 	`)
 
-	set, err := getGTIDFromContent(c)
+	set, err := getGTIDFromXtrabackup(c)
 	if err != nil {
 		t.Error("get last gtid set", err.Error())
 	}
@@ -103,3 +103,48 @@ func TestGetExtendGTIDSet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetServerVersionFromXtrabackup(t *testing.T) {
+	content := []byte(`uuid = 1b7a0f4e-8e4b-11eb-bc1d-0242ac110004
+tool_name = xtrabackup
+tool_version = 8.0.22-15
+server_version = 8.0.22-13
+binlog_pos = GTID of the last change '9a3e0d5e-8e4a-11eb-a4e5-0242ac110004:1-25'
+`)
+
+	v, err := getServerVersionFromXtrabackup(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "8.0.22-13" {
+		t.Errorf("expected 8.0.22-13, got %s", v)
+	}
+
+	_, err = getServerVersionFromXtrabackup([]byte("tool_name = xtrabackup\n"))
+	if err == nil {
+		t.Error("expected error for xtrabackup_info without server_version")
+	}
+}
+
+func TestGTIDSetStart(t *testing.T) {
+	cases := map[string]int64{
+		"9a3e0d5e-8e4a-11eb-a4e5-0242ac110004:11-20":                                          11,
+		"9a3e0d5e-8e4a-11eb-a4e5-0242ac110004:7:9-12\n":                                       7,
+		"9a3e0d5e-8e4a-11eb-a4e5-0242ac110004:30-41,a1b2c3d4-8e4a-11eb-a4e5-0242ac110004:1-5": 30,
+	}
+	for set, expected := range cases {
+		t.Run(set, func(t *testing.T) {
+			start, err := gtidSetStart(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start != expected {
+				t.Errorf("expected %d, got %d", expected, start)
+			}
+		})
+	}
+
+	if _, err := gtidSetStart("9a3e0d5e-8e4a-11eb-a4e5-0242ac110004"); err == nil {
+		t.Error("expected error for gtid set without transactions")
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// ManifestPostfix is the postfix of the object that describes the binlog object
+const ManifestPostfix = "-manifest"
+
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Manifest describes how the binlog object is stored
+// and is used to check its integrity before applying
+type Manifest struct {
+	Compression string `json:"compression,omitempty"`
+	SHA256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+}
+
+// Compress compresses data with the given algorithm
+func Compress(data []byte, compression string) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionZstd:
+		w, err = zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "new zstd writer")
+		}
+	default:
+		return nil, errors.Errorf("unknown compression %s", compression)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s compress", compression)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s compress", compression)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress returns reader with data decompressed with the given algorithm
+func Decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return ioutil.NopCloser(r), nil
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "new gzip reader")
+		}
+		return gr, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "new zstd reader")
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, errors.Errorf("unknown compression %s", compression)
+	}
+}
+
+// NewManifest returns manifest for the stored object data
+func NewManifest(data []byte, compression string) Manifest {
+	sum := sha256.Sum256(data)
+	return Manifest{
+		Compression: compression,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(data)),
+	}
+}
+
+// PutManifest stores manifest of the object with given name
+func PutManifest(s Storage, name string, m Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "marshal manifest")
+	}
+
+	return s.PutObject(name+ManifestPostfix, bytes.NewReader(data), int64(len(data)))
+}
+
+// GetManifest returns manifest of the object with given name.
+// Objects uploaded before manifests were introduced don't have it,
+// in that case nil is returned.
+func GetManifest(s Storage, name string) (*Manifest, error) {
+	obj, err := s.GetObject(name + ManifestPostfix)
+	if err != nil {
+		return nil, errors.Wrap(err, "get manifest object")
+	}
+	data, err := ioutil.ReadAll(obj)
+	if minio.ToErrorResponse(errors.Cause(err)).Code == "NoSuchKey" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read manifest object")
+	}
+
+	m := &Manifest{}
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal manifest %s", name+ManifestPostfix)
+	}
+
+	return m, nil
+}
+
+// Verify reads the whole object and checks it against the manifest
+func (m *Manifest) Verify(r io.Reader) error {
+	_, err := io.Copy(ioutil.Discard, m.Reader(r))
+	return err
+}
+
+// Reader returns reader that checks the object against the manifest
+// while it's read, the mismatch is returned instead of io.EOF
+func (m *Manifest) Reader(r io.Reader) io.Reader {
+	return &verifyingReader{r: r, h: sha256.New(), m: m}
+}
+
+type verifyingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+	m *Manifest
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	v.n += int64(n)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if v.n != v.m.Size {
+		return n, errors.Errorf("object is truncated or corrupted: size is %d, expected %d", v.n, v.m.Size)
+	}
+	if sum := hex.EncodeToString(v.h.Sum(nil)); sum != v.m.SHA256 {
+		return n, errors.Errorf("object is corrupted: sha256 checksum is %s, expected %s", sum, v.m.SHA256)
+	}
+
+	return n, io.EOF
+}
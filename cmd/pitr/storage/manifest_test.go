@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	data := bytes.Repeat([]byte("binlog event data"), 1024)
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		stored, err := Compress(data, compression)
+		if err != nil {
+			t.Fatalf("%q: compress: %v", compression, err)
+		}
+
+		m := NewManifest(stored, compression)
+		if err := m.Verify(bytes.NewReader(stored)); err != nil {
+			t.Errorf("%q: verify: %v", compression, err)
+		}
+		if err := m.Verify(bytes.NewReader(stored[:len(stored)-1])); err == nil {
+			t.Errorf("%q: truncated object passed verification", compression)
+		}
+
+		corrupted := append([]byte{}, stored...)
+		corrupted[len(corrupted)/2] ^= 0xff
+		if err := m.Verify(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("%q: corrupted object passed verification", compression)
+		}
+
+		r, err := Decompress(bytes.NewReader(stored), compression)
+		if err != nil {
+			t.Fatalf("%q: decompress: %v", compression, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%q: read decompressed: %v", compression, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%q: decompressed data doesn't match the original", compression)
+		}
+	}
+}
@@ -83,3 +83,22 @@ func (s *S3) ListObjects(prefix string) ([]string, error) {
 
 	return list, nil
 }
+
+// ObjectsSize returns the total size of the objects with the given prefix
+func (s *S3) ObjectsSize(prefix string) (int64, error) {
+	opts := minio.ListObjectsOptions{
+		UseV1:     true,
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}
+
+	var size int64
+	for object := range s.minioClient.ListObjects(s.ctx, s.bucketName, opts) {
+		if object.Err != nil {
+			return 0, errors.Wrapf(object.Err, "list object %s", object.Key)
+		}
+		size += object.Size
+	}
+
+	return size, nil
+}
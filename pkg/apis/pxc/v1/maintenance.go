@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+type parsedWindow struct {
+	loc      *time.Location
+	schedule cron.Schedule
+	duration time.Duration
+	days     map[time.Weekday]bool
+	start    time.Duration
+	end      time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func (w MaintenanceWindow) parse() (*parsedWindow, error) {
+	p := &parsedWindow{loc: time.UTC}
+
+	if w.TimeZone != "" {
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return nil, errors.Wrap(err, "timeZone")
+		}
+		p.loc = loc
+	}
+
+	if w.Schedule != "" {
+		if len(w.Days) > 0 || w.Start != "" || w.End != "" {
+			return nil, errors.New("schedule can't be used with days, start and end")
+		}
+		if w.Duration == nil || w.Duration.Duration <= 0 {
+			return nil, errors.New("duration is required with schedule")
+		}
+		sched, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			return nil, errors.Wrap(err, "schedule")
+		}
+		p.schedule = sched
+		p.duration = w.Duration.Duration
+		return p, nil
+	}
+
+	if w.Start == "" || w.End == "" {
+		return nil, errors.New("either schedule and duration or start and end should be set")
+	}
+	var err error
+	p.start, err = parseClock(w.Start)
+	if err != nil {
+		return nil, errors.Wrap(err, "start")
+	}
+	p.end, err = parseClock(w.End)
+	if err != nil {
+		return nil, errors.Wrap(err, "end")
+	}
+
+	p.days = make(map[time.Weekday]bool, len(w.Days))
+	for _, d := range w.Days {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, errors.Errorf("unknown day %s", d)
+		}
+		p.days[wd] = true
+	}
+
+	return p, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.Errorf("%s isn't HH:MM", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// State returns if the window is open at the time and the next time it opens
+func (w MaintenanceWindow) State(now time.Time) (bool, time.Time, error) {
+	p, err := w.parse()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now = now.In(p.loc)
+
+	if p.schedule != nil {
+		// the window is open if it has started within the duration
+		start := p.schedule.Next(now.Add(-p.duration))
+		return !start.After(now), p.schedule.Next(now), nil
+	}
+
+	open := false
+	next := time.Time{}
+	// the window that started yesterday may end today
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, p.loc)
+		if len(p.days) > 0 && !p.days[day.Weekday()] {
+			continue
+		}
+		start := clockTime(day, p.start)
+		end := clockTime(day, p.end)
+		if !end.After(start) {
+			end = clockTime(day.AddDate(0, 0, 1), p.end)
+		}
+		if !now.Before(start) && now.Before(end) {
+			open = true
+		}
+		if start.After(now) && next.IsZero() {
+			next = start
+		}
+	}
+
+	return open, next, nil
+}
+
+func clockTime(day time.Time, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// MaintenanceWindowOpen returns if any of the windows is open at the time
+// and the earliest next start of the windows
+func MaintenanceWindowOpen(windows []MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	open := false
+	next := time.Time{}
+	for i, w := range windows {
+		o, n, err := w.State(now)
+		if err != nil {
+			return false, time.Time{}, errors.Wrapf(err, "maintenance window %d", i)
+		}
+		open = open || o
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+
+	return open, next, nil
+}
@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceWindowState(t *testing.T) {
+	// June 5, 2021 is Saturday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2021, time.June, day, hour, min, 0, 0, time.UTC)
+	}
+	threeHours := &metav1.Duration{Duration: 3 * time.Hour}
+
+	tests := map[string]struct {
+		window MaintenanceWindow
+		now    time.Time
+		open   bool
+		next   time.Time
+		err    bool
+	}{
+		"cron open":            {MaintenanceWindow{Schedule: "0 2 * * 6", Duration: threeHours}, at(5, 3, 0), true, at(12, 2, 0), false},
+		"cron closed":          {MaintenanceWindow{Schedule: "0 2 * * 6", Duration: threeHours}, at(5, 6, 0), false, at(12, 2, 0), false},
+		"over midnight":        {MaintenanceWindow{Days: []string{"Fri"}, Start: "22:00", End: "02:00"}, at(5, 1, 0), true, at(11, 22, 0), false},
+		"time zone":            {MaintenanceWindow{Days: []string{"sunday"}, Start: "01:00", End: "05:00", TimeZone: "Europe/Berlin"}, at(5, 23, 30), true, at(12, 23, 0), false},
+		"every day":            {MaintenanceWindow{Start: "10:00", End: "12:00"}, at(5, 9, 0), false, at(5, 10, 0), false},
+		"no duration":          {MaintenanceWindow{Schedule: "0 2 * * 6"}, at(5, 3, 0), false, time.Time{}, true},
+		"schedule and days":    {MaintenanceWindow{Schedule: "0 2 * * 6", Duration: threeHours, Days: []string{"Sat"}}, at(5, 3, 0), false, time.Time{}, true},
+		"wrong day":            {MaintenanceWindow{Days: []string{"Caturday"}, Start: "01:00", End: "02:00"}, at(5, 3, 0), false, time.Time{}, true},
+		"wrong clock":          {MaintenanceWindow{Start: "1am", End: "02:00"}, at(5, 3, 0), false, time.Time{}, true},
+		"unknown time zone":    {MaintenanceWindow{Start: "01:00", End: "02:00", TimeZone: "Mars/Olympus"}, at(5, 3, 0), false, time.Time{}, true},
+		"end is start of next": {MaintenanceWindow{Days: []string{"Sat"}, Start: "03:00", End: "03:00"}, at(6, 2, 59), true, at(12, 3, 0), false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			open, next, err := tt.window.State(tt.now)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if open != tt.open {
+				t.Errorf("open = %v, want %v", open, tt.open)
+			}
+			if !next.Equal(tt.next) {
+				t.Errorf("next = %v, want %v", next.UTC(), tt.next)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package v1
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -11,7 +14,44 @@ type PerconaXtraDBClusterRestoreSpec struct {
 	PXCCluster   string           `json:"pxcCluster"`
 	BackupName   string           `json:"backupName"`
 	BackupSource *PXCBackupStatus `json:"backupSource,omitempty"`
-	PITR         *PITR            `json:"pitr,omitempty"`
+	// BackupRef refers to a backup in another namespace
+	BackupRef *ObjectRef `json:"backupRef,omitempty"`
+	// StorageClusterRef is the cluster backupSource.storageName is taken from.
+	// The restore cluster is used by default.
+	StorageClusterRef *ObjectRef `json:"storageClusterRef,omitempty"`
+	PITR              *PITR      `json:"pitr,omitempty"`
+	DryRun            bool       `json:"dryRun,omitempty"`
+	// Strategy is either inPlace (default) or blueGreen. The blueGreen restore
+	// goes to a new standby cluster and switches the proxy services over to it.
+	Strategy RestoreStrategy `json:"strategy,omitempty"`
+	// Cancel stops the running restore and brings the cluster back up.
+	// The cluster is rolled back to the pre-restore snapshot if there is one.
+	Cancel   bool             `json:"cancel,omitempty"`
+	Rollback *RestoreRollback `json:"rollback,omitempty"`
+	// DataTimeout limits the time the restore spends in each of the states that copy the data:
+	// restoring the backup, applying the binlogs and restoring the standby cluster. 24h by default.
+	DataTimeout *metav1.Duration `json:"dataTimeout,omitempty"`
+}
+
+type RestoreStrategy string
+
+const (
+	RestoreStrategyInPlace   RestoreStrategy = "inPlace"
+	RestoreStrategyBlueGreen RestoreStrategy = "blueGreen"
+)
+
+// ObjectRef refers to an object that can be in another namespace
+type ObjectRef struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// RestoreRollback enables a VolumeSnapshot of the first PXC PVC taken
+// before its data is replaced, so a failed or cancelled restore
+// returns the cluster to the exact pre-restore data
+type RestoreRollback struct {
+	Enabled                 bool   `json:"enabled,omitempty"`
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
 }
 
 // PerconaXtraDBClusterRestoreStatus defines the observed state of PerconaXtraDBClusterRestore
@@ -20,15 +60,128 @@ type PerconaXtraDBClusterRestoreStatus struct {
 	Comments      string           `json:"comments,omitempty"`
 	CompletedAt   *metav1.Time     `json:"completed,omitempty"`
 	LastScheduled *metav1.Time     `json:"lastscheduled,omitempty"`
+	Plan          *RestorePlan     `json:"plan,omitempty"`
+	PITRTarget    string           `json:"pitrTarget,omitempty"`
+	// StateChangedAt is the time the restore has entered the current state.
+	// It is used to time out the state.
+	StateChangedAt *metav1.Time `json:"stateChangedAt,omitempty"`
+	// Cluster keeps the cluster options that are changed during the restore
+	// so they can be reverted even after the operator restart
+	Cluster *RestoreClusterOptions `json:"cluster,omitempty"`
+	// Snapshot is the name of the pre-restore VolumeSnapshot
+	Snapshot string `json:"snapshot,omitempty"`
+	// StandbyCluster is the cluster the blueGreen restore goes to
+	StandbyCluster string             `json:"standbyCluster,omitempty"`
+	Conditions     []ClusterCondition `json:"conditions,omitempty"`
+	// Phases keeps the time spent in each state
+	Phases   []RestorePhase   `json:"phases,omitempty"`
+	Progress *RestoreProgress `json:"progress,omitempty"`
+}
+
+// RestoreConditionType is the cluster condition type, the restore conditions
+// are ClusterConditions managed by the same helpers as the cluster ones
+type RestoreConditionType = AppState
+
+const (
+	RestoreConditionClusterStopped RestoreConditionType = "ClusterStopped"
+	RestoreConditionDataRestored   RestoreConditionType = "DataRestored"
+	RestoreConditionPITRApplied    RestoreConditionType = "PITRApplied"
+	RestoreConditionClusterReady   RestoreConditionType = "ClusterReady"
+)
+
+// RestorePhase is the time the restore has spent in the state
+type RestorePhase struct {
+	State      BcpRestoreStates `json:"state"`
+	StartedAt  metav1.Time      `json:"startedAt"`
+	FinishedAt *metav1.Time     `json:"finishedAt,omitempty"`
+}
+
+// RestoreProgress describes how much data the restore has processed
+type RestoreProgress struct {
+	// BytesDownloaded is the size of the downloaded backup and binlogs
+	BytesDownloaded int64  `json:"bytesDownloaded,omitempty"`
+	BinlogsPlanned  int    `json:"binlogsPlanned,omitempty"`
+	BinlogsApplied  int    `json:"binlogsApplied,omitempty"`
+	LastGTID        string `json:"lastGTID,omitempty"`
+	// CheckedAt is the last time the job log has been read for the progress
+	CheckedAt *metav1.Time `json:"checkedAt,omitempty"`
+	// LogTime is the time of the last job log line counted in BytesDownloaded,
+	// the log is read from it on the next check
+	LogTime *metav1.MicroTime `json:"logTime,omitempty"`
+}
+
+// SetCondition sets the condition of the given type
+// updating the transition time only if the status has changed
+func (s *PerconaXtraDBClusterRestoreStatus) SetCondition(c ClusterCondition) {
+	s.Conditions = setCondition(s.Conditions, c)
+}
+
+// Condition returns the condition of the given type or nil
+func (s *PerconaXtraDBClusterRestoreStatus) Condition(t RestoreConditionType) *ClusterCondition {
+	return findCondition(s.Conditions, t)
+}
+
+// RestoreClusterOptions are the cluster options the restore changes temporarily
+type RestoreClusterOptions struct {
+	PXCSize           int32 `json:"pxcSize,omitempty"`
+	AllowUnsafeConfig bool  `json:"allowUnsafeConfigurations,omitempty"`
+}
+
+// RestorePlan describes what the restore is going to do.
+// It is filled in after the validation, for dry-run restores it's the result.
+type RestorePlan struct {
+	BackupDestination   string   `json:"backupDestination,omitempty"`
+	BackupServerVersion string   `json:"backupServerVersion,omitempty"`
+	BackupSize          int64    `json:"backupSize,omitempty"`
+	StartGTID           string   `json:"startGTID,omitempty"`
+	Binlogs             []string `json:"binlogs,omitempty"`
+	BinlogsCount        int      `json:"binlogsCount,omitempty"`
+	EndPoint            string   `json:"endPoint,omitempty"`
 }
 
 type PITR struct {
 	BackupSource *PXCBackupStatus `json:"backupSource"`
 	Type         string           `json:"type"`
 	Date         string           `json:"date"`
+	Timezone     string           `json:"timezone,omitempty"`
 	GTID         string           `json:"gtid"`
 }
 
+const (
+	PITRTypeDate = "date"
+
+	// PITRDateFormat is the format of the PITR date without an offset
+	PITRDateFormat = "2006-01-02 15:04:05"
+)
+
+// Time returns the point in time to recover to.
+// Date can be either an RFC3339 timestamp with an offset or
+// "yyyy-mm-dd hh:mm:ss" in the Timezone (UTC if it's empty).
+func (p *PITR) Time() (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, p.Date); err == nil {
+		if p.Timezone != "" {
+			return time.Time{}, fmt.Errorf("date %s already has an offset, timezone can't be used with it", p.Date)
+		}
+		return t, nil
+	}
+
+	loc := time.UTC
+	if p.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(p.Timezone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("load timezone %s: %v", p.Timezone, err)
+		}
+	}
+
+	t, err := time.ParseInLocation(PITRDateFormat, p.Date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %s should be in \"yyyy-mm-dd hh:mm:ss\" or RFC3339 format", p.Date)
+	}
+
+	return t, nil
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // PerconaXtraDBClusterRestore is the Schema for the perconaxtradbclusterrestores API
@@ -50,6 +203,17 @@ type PerconaXtraDBClusterRestoreList struct {
 	Items           []PerconaXtraDBClusterRestore `json:"items"`
 }
 
+// RestoreAllowedNamespacesAnnotation lists namespaces (comma-separated, "*" for all)
+// restores from which can use the annotated backup or the storages of the annotated cluster.
+// The storage credentials secret has to allow the namespace too, it's copied to the restore namespace.
+// The annotation isn't RBAC-aware: the service account of the restore cluster PXC pods
+// has to be allowed to get the annotated objects in their namespace too.
+const RestoreAllowedNamespacesAnnotation = "percona.com/restore-allowed-namespaces"
+
+// DataSourceRestoreLabel marks restores created to bootstrap
+// a new cluster from its spec.dataSource
+const DataSourceRestoreLabel = "percona.com/data-source"
+
 type BcpRestoreStates string
 
 const (
@@ -58,7 +222,15 @@ const (
 	RestoreStopCluster  BcpRestoreStates = "Stopping Cluster"
 	RestoreRestore      BcpRestoreStates = "Restoring"
 	RestoreStartCluster BcpRestoreStates = "Starting Cluster"
+	RestorePreparePITR  BcpRestoreStates = "Preparing point-in-time recovery"
 	RestorePITR         BcpRestoreStates = "Point-in-time recovering"
+	RestoreValidating   BcpRestoreStates = "Validating"
+	RestoreSnapshot     BcpRestoreStates = "Taking snapshot"
+	RestoreCancelling   BcpRestoreStates = "Cancelling"
+	RestoreRollingBack  BcpRestoreStates = "Rolling back"
+	RestoreCancelled    BcpRestoreStates = "Cancelled"
+	RestoreStandby      BcpRestoreStates = "Restoring standby cluster"
+	RestoreSwitchover   BcpRestoreStates = "Switching over"
 	RestoreFailed       BcpRestoreStates = "Failed"
 	RestoreSucceeded    BcpRestoreStates = "Succeeded"
 )
@@ -70,16 +242,67 @@ func (cr *PerconaXtraDBClusterRestore) CheckNsetDefaults() error {
 	if cr.Spec.PITR != nil && cr.Spec.PITR.BackupSource != nil && cr.Spec.PITR.BackupSource.StorageName == "" && cr.Spec.PITR.BackupSource.S3 == nil {
 		return errors.New("PITR.BackupSource.StorageName and PITR.BackupSource.S3 can't be empty simultaneously")
 	}
-	if cr.Spec.BackupName == "" && cr.Spec.BackupSource == nil {
-		return errors.New("backupName and BackupSource can't be empty simultaneously")
+	if cr.Spec.PITR != nil && cr.Spec.PITR.Type == PITRTypeDate {
+		if _, err := cr.Spec.PITR.Time(); err != nil {
+			return fmt.Errorf("PITR.Date: %v", err)
+		}
+	}
+	sources := 0
+	for _, set := range []bool{cr.Spec.BackupName != "", cr.Spec.BackupSource != nil, cr.Spec.BackupRef != nil} {
+		if set {
+			sources++
+		}
 	}
-	if len(cr.Spec.BackupName) > 0 && cr.Spec.BackupSource != nil {
-		return errors.New("backupName and BackupSource can't be specified simultaneously")
+	if sources == 0 {
+		return errors.New("backupName, backupSource and backupRef can't be empty simultaneously")
+	}
+	if sources > 1 {
+		return errors.New("only one of backupName, backupSource and backupRef can be specified")
+	}
+	if cr.Spec.BackupRef != nil && cr.Spec.BackupRef.Name == "" {
+		return errors.New("backupRef.name can't be empty")
+	}
+	if cr.Spec.StorageClusterRef != nil && (cr.Spec.BackupSource == nil || cr.Spec.BackupSource.StorageName == "") {
+		return errors.New("storageClusterRef requires backupSource.storageName")
+	}
+
+	if cr.Spec.Rollback != nil && cr.Spec.Rollback.Enabled && cr.Spec.DryRun {
+		return errors.New("rollback can't be used with dryRun")
+	}
+	switch cr.Spec.Strategy {
+	case "", RestoreStrategyInPlace:
+	case RestoreStrategyBlueGreen:
+		if cr.Spec.Rollback != nil && cr.Spec.Rollback.Enabled {
+			return errors.New("rollback can't be used with blueGreen strategy, the original cluster is kept anyway")
+		}
+	default:
+		return fmt.Errorf("unknown strategy %s", cr.Spec.Strategy)
 	}
 
 	return nil
 }
 
+// IsFinished reports whether the restore is in one of the final states
+func (cr *PerconaXtraDBClusterRestore) IsFinished() bool {
+	switch cr.Status.State {
+	case RestoreSucceeded, RestoreFailed, RestoreCancelled:
+		return true
+	}
+	return false
+}
+
+// NamespaceAllowed reports whether the annotations let restores from the namespace
+// use the object, see RestoreAllowedNamespacesAnnotation
+func NamespaceAllowed(annotations map[string]string, namespace string) bool {
+	for _, ns := range strings.Split(annotations[RestoreAllowedNamespacesAnnotation], ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	SchemeBuilder.Register(&PerconaXtraDBClusterRestore{}, &PerconaXtraDBClusterRestoreList{})
 }
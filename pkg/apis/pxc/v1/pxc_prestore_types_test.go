@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPITRTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		pitr    PITR
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no timezone",
+			pitr: PITR{Date: "2021-03-01 10:00:00"},
+			want: "2021-03-01T10:00:00Z",
+		},
+		{
+			name: "timezone",
+			pitr: PITR{Date: "2021-03-01 10:00:00", Timezone: "Europe/Kiev"},
+			want: "2021-03-01T08:00:00Z",
+		},
+		{
+			name: "rfc3339 with offset",
+			pitr: PITR{Date: "2021-03-01T10:00:00-05:00"},
+			want: "2021-03-01T15:00:00Z",
+		},
+		{
+			name:    "rfc3339 with timezone",
+			pitr:    PITR{Date: "2021-03-01T10:00:00-05:00", Timezone: "UTC"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown timezone",
+			pitr:    PITR{Date: "2021-03-01 10:00:00", Timezone: "Mars/Olympus"},
+			wantErr: true,
+		},
+		{
+			name:    "wrong format",
+			pitr:    PITR{Date: "01.03.2021 10:00"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pitr.Time()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Time() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if s := got.UTC().Format(time.RFC3339); s != tt.want {
+				t.Errorf("Time() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceAllowed(t *testing.T) {
+	tests := []struct {
+		annotation string
+		namespace  string
+		want       bool
+	}{
+		{"", "staging", false},
+		{"staging", "staging", true},
+		{"dev, staging", "staging", true},
+		{"dev", "staging", false},
+		{"*", "staging", true},
+	}
+
+	for _, tt := range tests {
+		annotations := map[string]string{}
+		if tt.annotation != "" {
+			annotations[RestoreAllowedNamespacesAnnotation] = tt.annotation
+		}
+		if got := NamespaceAllowed(annotations, tt.namespace); got != tt.want {
+			t.Errorf("annotation %q, namespace %q: got %v, want %v", tt.annotation, tt.namespace, got, tt.want)
+		}
+	}
+}
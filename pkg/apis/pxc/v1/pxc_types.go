@@ -34,6 +34,7 @@ type PerconaXtraDBClusterSpec struct {
 	PXC                       *PXCSpec                             `json:"pxc,omitempty"`
 	ProxySQL                  *PodSpec                             `json:"proxysql,omitempty"`
 	HAProxy                   *PodSpec                             `json:"haproxy,omitempty"`
+	Replicas                  *PodSpec                             `json:"replicas,omitempty"`
 	PMM                       *PMMSpec                             `json:"pmm,omitempty"`
 	LogCollector              *LogCollectorSpec                    `json:"logcollector,omitempty"`
 	Backup                    *PXCScheduledBackup                  `json:"backup,omitempty"`
@@ -42,6 +43,41 @@ type PerconaXtraDBClusterSpec struct {
 	AllowUnsafeConfig         bool                                 `json:"allowUnsafeConfigurations,omitempty"`
 	InitImage                 string                               `json:"initImage,omitempty"`
 	EnableCRValidationWebhook *bool                                `json:"enableCRValidationWebhook,omitempty"`
+	DataSource                *DataSource                          `json:"dataSource,omitempty"`
+	VolumeExpansion           *VolumeExpansionSpec                 `json:"volumeExpansion,omitempty"`
+	// MaintenanceWindows limit the time the pods are restarted by the operator.
+	// The restarts are deferred until one of the windows opens.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+}
+
+// MaintenanceWindow is defined either by the cron schedule of its start and the duration
+// or by the days of the week with the start and the end time (HH:MM)
+type MaintenanceWindow struct {
+	Schedule string           `json:"schedule,omitempty"`
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	Days     []string         `json:"days,omitempty"`
+	Start    string           `json:"start,omitempty"`
+	End      string           `json:"end,omitempty"`
+	// TimeZone is the IANA time zone name of the window, UTC by default
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// VolumeExpansionSpec allows the operator to resize the data volumes
+// when the requested storage size of the PXC or ProxySQL volumeSpec grows
+type VolumeExpansionSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// RecreateStatefulSet deletes the statefulset with the orphan policy once the volumes
+	// are resized, so it's created again with the new volume claim template
+	RecreateStatefulSet bool `json:"recreateStatefulSet,omitempty"`
+}
+
+// DataSource defines the backup (and optionally the point in time)
+// a new cluster should be bootstrapped from
+type DataSource struct {
+	BackupName   string           `json:"backupName,omitempty"`
+	BackupSource *PXCBackupStatus `json:"backupSource,omitempty"`
+	BackupRef    *ObjectRef       `json:"backupRef,omitempty"`
+	PITR         *PITR            `json:"pitr,omitempty"`
 }
 
 type PXCSpec struct {
@@ -60,11 +96,79 @@ type ServiceExpose struct {
 }
 
 type ReplicationChannel struct {
-	Name       string `json:"name,omitempty"`
-	IsSource   bool   `json:"isSource,omitempty"`
+	Name     string `json:"name,omitempty"`
+	IsSource bool   `json:"isSource,omitempty"`
+	// SecretName is the secret with the password of the replication user
+	// under the "replication" key. It has to be the same on both sites.
 	SecretName string `json:"secretName,omitempty"`
+	// SourcesList is the list of the source cluster nodes the replica channel
+	// connects to. The source with the highest weight is preferred and
+	// the channel fails over to the next one if the source is lost.
+	SourcesList []ReplicationSource `json:"sourcesList,omitempty"`
+}
+
+// checkReplicationChannels validates the channels and sets defaults for their sources
+func (p *PXCSpec) checkReplicationChannels() error {
+	names := make(map[string]struct{}, len(p.ReplicationChannels))
+	for i := range p.ReplicationChannels {
+		v := &p.ReplicationChannels[i]
+		if v.Name == "" {
+			return errors.New("pxc.replicationChannels.Name can't be empty")
+		}
+		if v.SecretName == "" {
+			return errors.New("pxc.replicationChannels.SecretName can't be empty")
+		}
+		if _, ok := names[v.Name]; ok {
+			return errors.Errorf("pxc.replicationChannels.Name %s is used more than once", v.Name)
+		}
+		names[v.Name] = struct{}{}
+		if v.IsSource != p.ReplicationChannels[0].IsSource {
+			return errors.New("pxc.replicationChannels can't mix source and replica channels")
+		}
+
+		if v.IsSource {
+			// all source channels use the same replication user
+			if v.SecretName != p.ReplicationChannels[0].SecretName {
+				return errors.New("pxc.replicationChannels.SecretName should be the same for all source channels")
+			}
+			continue
+		}
+		if len(v.SourcesList) == 0 {
+			return errors.Errorf("pxc.replicationChannels.SourcesList of replica channel %s can't be empty", v.Name)
+		}
+		for j := range v.SourcesList {
+			src := &v.SourcesList[j]
+			if src.Host == "" {
+				return errors.Errorf("pxc.replicationChannels.SourcesList.Host of channel %s can't be empty", v.Name)
+			}
+			if src.Port == 0 {
+				src.Port = 3306
+			}
+			if src.Weight == 0 {
+				src.Weight = 100
+			}
+			if src.Weight < 1 || src.Weight > 100 {
+				return errors.Errorf("pxc.replicationChannels.SourcesList.Weight of %s should be from 1 to 100", src.Host)
+			}
+		}
+	}
+
+	return nil
 }
 
+type ReplicationSource struct {
+	Host   string `json:"host,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// ReplicationUser is the user the replica channels connect to the source with
+const ReplicationUser = "replication"
+
+// ReplicasUser is the user the read replicas replicate from the cluster with,
+// it has only the REPLICATION SLAVE privilege
+const ReplicasUser = "replicas"
+
 type TLSSpec struct {
 	SANs       []string                `json:"SANs,omitempty"`
 	IssuerConf *cmmeta.ObjectReference `json:"issuerConf,omitempty"`
@@ -74,8 +178,66 @@ type UpgradeOptions struct {
 	VersionServiceEndpoint string `json:"versionServiceEndpoint,omitempty"`
 	Apply                  string `json:"apply,omitempty"`
 	Schedule               string `json:"schedule,omitempty"`
+	// Canary stops SmartUpdate after the first updated secondary pod
+	// until the update is approved with the ApproveUpdateAnnotation
+	Canary UpgradeCanary `json:"canary,omitempty"`
+	// PauseBetweenPods is the delay SmartUpdate makes after a pod is updated
+	PauseBetweenPods *metav1.Duration `json:"pauseBetweenPods,omitempty"`
+	// MaxUnavailable is the number of secondary PXC pods SmartUpdate restarts at once
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// Partition keeps the PXC pods with a lower ordinal on the previous revision
+	Partition *int32 `json:"partition,omitempty"`
+	// MajorUpgrade configures the checks and the backup made
+	// before the PXC pods are updated to the next major version
+	MajorUpgrade *MajorUpgradeOptions `json:"majorUpgrade,omitempty"`
+	// VersionMatrix is the offline source of the versions
+	// for the clusters that can't reach the version service
+	VersionMatrix *VersionMatrixSource `json:"versionMatrix,omitempty"`
+}
+
+// VersionMatrixSource is the version matrix in the format of the version service
+type VersionMatrixSource struct {
+	// ConfigMap keeps the matrix, the key is "matrix.json" if it isn't set
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+	// File is the path to the matrix in the operator container
+	File string `json:"file,omitempty"`
+}
+
+type MajorUpgradeOptions struct {
+	// BackupStorageName is the storage the pre-upgrade backup is made to
+	BackupStorageName string `json:"backupStorageName,omitempty"`
+	// SkipBackup upgrades the cluster without the pre-upgrade backup
+	SkipBackup bool `json:"skipBackup,omitempty"`
+	// IgnoreChecks upgrades the cluster even if the pre-upgrade checks have findings
+	IgnoreChecks bool `json:"ignoreChecks,omitempty"`
 }
 
+type UpgradeCanary string
+
+const (
+	UpgradeCanaryNever  UpgradeCanary = "never"
+	UpgradeCanaryAlways UpgradeCanary = "always"
+	// UpgradeCanaryMajor makes a canary only if the major version of the PXC image changes
+	UpgradeCanaryMajor UpgradeCanary = "major"
+)
+
+// ApproveUpdateAnnotation approves the SmartUpdate after the canary pod is updated.
+// Its value has to be the revision from status.smartUpdate.revision.
+const ApproveUpdateAnnotation = "percona.com/approve-update"
+
+// SwitchoverAnnotation moves the writer of the cluster to the PXC pod named in its value.
+// The result is kept in status.switchover while the annotation is set. HAProxy keeps
+// sending the writes to the pod until the annotation is removed.
+const SwitchoverAnnotation = "percona.com/switchover"
+
+// ForceQuorumAnnotation makes the operator restore the quorum on the surviving PXC nodes even if
+// the state of the failed ones is unknown. Each new value of the annotation is used once.
+const ForceQuorumAnnotation = "percona.com/force-quorum"
+
+// ProxyServingLabel is set on the HAProxy and ProxySQL pods the services send the clients to.
+// The operator removes it from the proxy pod before the pod is drained by SmartUpdate.
+const ProxyServingLabel = "percona.com/serving"
+
 const (
 	SmartUpdateStatefulSetStrategyType appsv1.StatefulSetUpdateStrategyType = "SmartUpdate"
 )
@@ -96,6 +258,7 @@ type PITRSpec struct {
 	StorageName        string        `json:"storageName"`
 	Resources          *PodResources `json:"resources,omitempty"`
 	TimeBetweenUploads float64       `json:"timeBetweenUploads,omitempty"`
+	Compression        string        `json:"compression,omitempty"`
 }
 
 type PXCScheduledBackupSchedule struct {
@@ -120,6 +283,7 @@ type PerconaXtraDBClusterStatus struct {
 	PXC                AppStatus          `json:"pxc,omitempty"`
 	ProxySQL           AppStatus          `json:"proxysql,omitempty"`
 	HAProxy            AppStatus          `json:"haproxy,omitempty"`
+	Replicas           AppStatus          `json:"replicas,omitempty"`
 	Backup             AppStatus          `json:"backup,omitempty"`
 	PMM                AppStatus          `json:"pmm,omitempty"`
 	LogCollector       AppStatus          `json:"logcollector,omitempty"`
@@ -130,6 +294,240 @@ type PerconaXtraDBClusterStatus struct {
 	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
 	Size               int32              `json:"size,omitempty"`
 	Ready              int32              `json:"ready,omitempty"`
+	// ReplicationChannels is the status of the replica channels of the cluster
+	ReplicationChannels []ReplicationChannelStatus `json:"replicationChannels,omitempty"`
+	// VolumeExpansion is the progress of the data volumes resize
+	VolumeExpansion []VolumeExpansionStatus `json:"volumeExpansion,omitempty"`
+	// SmartUpdate is the progress of the PXC pods update with the SmartUpdate strategy
+	SmartUpdate *SmartUpdateStatus `json:"smartUpdate,omitempty"`
+	// ProxySmartUpdate is the progress of the HAProxy or ProxySQL pods update
+	ProxySmartUpdate *SmartUpdateStatus `json:"proxySmartUpdate,omitempty"`
+	// UpgradeRollback is set when the PXC update has been rolled back. The PXC statefulset
+	// isn't updated until the PXC spec is changed again.
+	UpgradeRollback *UpgradeRollbackStatus `json:"upgradeRollback,omitempty"`
+	// MajorUpgrade is the progress of the PXC major version upgrade
+	MajorUpgrade *MajorUpgradeStatus `json:"majorUpgrade,omitempty"`
+	// Maintenance is set if the maintenance windows are defined
+	Maintenance *MaintenanceStatus `json:"maintenance,omitempty"`
+	// Switchover is the progress of the primary switchover requested with the SwitchoverAnnotation
+	Switchover *SwitchoverStatus `json:"switchover,omitempty"`
+	// FullCrashRecovery is the decision of the last full cluster crash recovery
+	FullCrashRecovery *FullCrashRecoveryStatus `json:"fullCrashRecovery,omitempty"`
+	// QuorumRecovery is the state of the quorum loss recovery
+	QuorumRecovery *QuorumRecoveryStatus `json:"quorumRecovery,omitempty"`
+	// DataSourceRestore is the restore created to bootstrap the cluster from spec.dataSource,
+	// it's created only once
+	DataSourceRestore string `json:"dataSourceRestore,omitempty"`
+}
+
+// QuorumRecoveryStatus is set when the PXC nodes that are left lost the quorum
+type QuorumRecoveryStatus struct {
+	// DetectedAt is when the nodes were found out of the primary component
+	DetectedAt metav1.Time        `json:"detectedAt"`
+	Nodes      []QuorumNodeStatus `json:"nodes,omitempty"`
+	// Message explains why the quorum isn't restored yet
+	Message string `json:"message,omitempty"`
+	// BootstrapPod is the node the primary component is bootstrapped on
+	BootstrapPod   string       `json:"bootstrapPod,omitempty"`
+	BootstrappedAt *metav1.Time `json:"bootstrappedAt,omitempty"`
+	// Forced is the value of the ForceQuorumAnnotation used last time
+	Forced string `json:"forced,omitempty"`
+}
+
+type QuorumNodeStatus struct {
+	Pod string `json:"pod"`
+	// State is wsrep_cluster_status of the node, Down or Unknown
+	State         string `json:"state"`
+	LastCommitted int64  `json:"lastCommitted,omitempty"`
+}
+
+type FullCrashRecoveryPhase string
+
+const (
+	// FullCrashRecoveryBlocked is set if the pod to bootstrap from can't be chosen
+	FullCrashRecoveryBlocked FullCrashRecoveryPhase = "Blocked"
+	// FullCrashRecoveryBootstrapping is set when the bootstrap pod is signaled to start
+	FullCrashRecoveryBootstrapping FullCrashRecoveryPhase = "Bootstrapping"
+	FullCrashRecoveryDone          FullCrashRecoveryPhase = "Done"
+)
+
+type FullCrashRecoveryStatus struct {
+	Phase FullCrashRecoveryPhase `json:"phase,omitempty"`
+	// Candidates are the positions of the PXC pods waiting for the recovery
+	Candidates []RecoveryCandidate `json:"candidates,omitempty"`
+	// BootstrapPod is the pod the cluster is bootstrapped from
+	BootstrapPod string `json:"bootstrapPod,omitempty"`
+	// SignaledAt is when the bootstrap pod was signaled to start
+	SignaledAt *metav1.Time `json:"signaledAt,omitempty"`
+	// Message is set if the pod to bootstrap from can't be chosen
+	Message string      `json:"message,omitempty"`
+	Time    metav1.Time `json:"time,omitempty"`
+}
+
+// RecoveryCandidate is the position of the node from its grastate.dat or the wsrep recovery
+type RecoveryCandidate struct {
+	Pod             string `json:"pod"`
+	UUID            string `json:"uuid,omitempty"`
+	Seqno           int64  `json:"seqno"`
+	SafeToBootstrap bool   `json:"safeToBootstrap,omitempty"`
+}
+
+type SwitchoverState string
+
+const (
+	// SwitchoverSwitching points the proxies to the new primary
+	SwitchoverSwitching SwitchoverState = "Switching"
+	// SwitchoverDraining waits for the transactions on the old primary to finish
+	SwitchoverDraining SwitchoverState = "Draining"
+	// SwitchoverVerifying waits for the proxies to send the writes to the new primary
+	SwitchoverVerifying SwitchoverState = "Verifying"
+	SwitchoverSucceeded SwitchoverState = "Succeeded"
+	SwitchoverFailed    SwitchoverState = "Failed"
+)
+
+type SwitchoverStatus struct {
+	// Target is the pod that has to become the primary
+	Target string `json:"target"`
+	// From is the primary pod before the switchover
+	From           string          `json:"from,omitempty"`
+	State          SwitchoverState `json:"state"`
+	Message        string          `json:"message,omitempty"`
+	StartedAt      metav1.Time     `json:"startedAt,omitempty"`
+	PhaseStartedAt metav1.Time     `json:"phaseStartedAt,omitempty"`
+	FinishedAt     *metav1.Time    `json:"finishedAt,omitempty"`
+}
+
+type MaintenanceStatus struct {
+	// WindowOpen is true if the pods can be restarted now
+	WindowOpen bool         `json:"windowOpen"`
+	NextWindow *metav1.Time `json:"nextWindow,omitempty"`
+	// Pending are the pod restarts deferred until the next window
+	Pending []string `json:"pending,omitempty"`
+}
+
+type MajorUpgradePhase string
+
+const (
+	// MajorUpgradeChecking runs the pre-upgrade checks against the cluster
+	MajorUpgradeChecking MajorUpgradePhase = "Checking"
+	// MajorUpgradeBlocked keeps the previous version until the findings are fixed
+	MajorUpgradeBlocked MajorUpgradePhase = "Blocked"
+	// MajorUpgradeBackingUp waits for the pre-upgrade backup
+	MajorUpgradeBackingUp MajorUpgradePhase = "BackingUp"
+	// MajorUpgradeUpgrading waits for the pods to be updated
+	MajorUpgradeUpgrading MajorUpgradePhase = "Upgrading"
+	// MajorUpgradeVerifying checks the version and the state of every node
+	MajorUpgradeVerifying MajorUpgradePhase = "Verifying"
+	MajorUpgradeCompleted MajorUpgradePhase = "Completed"
+	// MajorUpgradeFailed is set if the update was rolled back
+	MajorUpgradeFailed MajorUpgradePhase = "Failed"
+)
+
+// MajorUpgradeStatus tracks the upgrade of the PXC major version (5.7 to 8.0).
+// The PXC statefulset isn't updated while the upgrade is being checked and backed up.
+type MajorUpgradeStatus struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Image is the PXC image the cluster is upgraded to
+	Image string            `json:"image"`
+	Phase MajorUpgradePhase `json:"phase"`
+	// Findings are the problems found by the pre-upgrade checks
+	Findings []string `json:"findings,omitempty"`
+	// Warnings are the things found by the pre-upgrade checks that don't block
+	// the upgrade but may break the applications, e.g. unquoted reserved words
+	Warnings []string `json:"warnings,omitempty"`
+	// Backup is the name of the pre-upgrade backup
+	Backup    string       `json:"backup,omitempty"`
+	Message   string       `json:"message,omitempty"`
+	LastCheck *metav1.Time `json:"lastCheck,omitempty"`
+}
+
+type UpgradeRollbackStatus struct {
+	FailedRevision   string `json:"failedRevision"`
+	FailedImage      string `json:"failedImage,omitempty"`
+	RestoredRevision string `json:"restoredRevision"`
+	RestoredImage    string `json:"restoredImage,omitempty"`
+	// SpecHash is the hash of the PXC spec the failed revision was made from
+	SpecHash string      `json:"specHash"`
+	Reason   string      `json:"reason,omitempty"`
+	Time     metav1.Time `json:"time,omitempty"`
+}
+
+type SmartUpdatePhase string
+
+const (
+	// SmartUpdateRestarting waits for the deleted pod to run with the new revision
+	SmartUpdateRestarting SmartUpdatePhase = "Restarting"
+	// SmartUpdateSyncing waits for the node to join the cluster
+	SmartUpdateSyncing SmartUpdatePhase = "Syncing"
+	// SmartUpdateWaitingOnline waits for the proxy to send the traffic to the node
+	SmartUpdateWaitingOnline SmartUpdatePhase = "WaitingOnline"
+	// SmartUpdateLeavingService waits for the proxy to be removed from the service endpoints
+	SmartUpdateLeavingService SmartUpdatePhase = "LeavingService"
+	// SmartUpdateDraining waits for the clients to disconnect from the proxy
+	SmartUpdateDraining SmartUpdatePhase = "Draining"
+	// SmartUpdateHealthCheck waits for the restarted proxy to see the healthy nodes
+	SmartUpdateHealthCheck SmartUpdatePhase = "HealthCheck"
+)
+
+// SmartUpdateStatus keeps the pods that are being updated, so the update
+// continues in the next reconcile instead of waiting for the pods
+type SmartUpdateStatus struct {
+	// Revision is the statefulset revision the pods are updated to
+	Revision string           `json:"revision"`
+	Pods     []SmartUpdatePod `json:"pods,omitempty"`
+	// NeedsApproval is decided when the update of the revision starts,
+	// the update is stopped after the canary pod until it's approved
+	NeedsApproval bool `json:"needsApproval,omitempty"`
+	// Canary is the first updated pod if the update has to be approved
+	Canary          string       `json:"canary,omitempty"`
+	WaitingApproval bool         `json:"waitingApproval,omitempty"`
+	PausedUntil     *metav1.Time `json:"pausedUntil,omitempty"`
+}
+
+type SmartUpdatePod struct {
+	Name           string           `json:"name"`
+	Phase          SmartUpdatePhase `json:"phase,omitempty"`
+	PhaseStartedAt metav1.Time      `json:"phaseStartedAt,omitempty"`
+}
+
+type VolumeExpansionState string
+
+const (
+	VolumeExpansionInProgress VolumeExpansionState = "InProgress"
+	// VolumeExpansionRecreating is set when the statefulset is deleted with the orphan policy
+	// to be created again with the new volume claim template. The pods and PVCs are kept.
+	VolumeExpansionRecreating VolumeExpansionState = "RecreatingStatefulSet"
+	VolumeExpansionDone       VolumeExpansionState = "Done"
+	VolumeExpansionError      VolumeExpansionState = "Error"
+)
+
+type VolumeExpansionStatus struct {
+	// Component is the statefulset the volumes belong to (pxc, proxysql)
+	Component     string               `json:"component"`
+	State         VolumeExpansionState `json:"state"`
+	RequestedSize string               `json:"requestedSize,omitempty"`
+	ResizedPVCs   int32                `json:"resizedPVCs"`
+	TotalPVCs     int32                `json:"totalPVCs"`
+	Message       string               `json:"message,omitempty"`
+}
+
+type ReplicationChannelStatus struct {
+	Name string `json:"name"`
+	// Pod is the PXC pod the channel runs on
+	Pod                 string `json:"pod,omitempty"`
+	Source              string `json:"source,omitempty"`
+	IOThreadState       string `json:"ioThreadState,omitempty"`
+	SQLThreadState      string `json:"sqlThreadState,omitempty"`
+	SecondsBehindSource *int64 `json:"secondsBehindSource,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+	ReceivedGTIDSet     string `json:"receivedGTIDSet,omitempty"`
+	ExecutedGTIDSet     string `json:"executedGTIDSet,omitempty"`
+}
+
+// Healthy is true if both replication threads of the channel are running
+func (s ReplicationChannelStatus) Healthy() bool {
+	return s.IOThreadState == "Yes" && s.SQLThreadState == "Yes"
 }
 
 type ConditionStatus string
@@ -140,6 +538,18 @@ const (
 	ConditionUnknown ConditionStatus = "Unknown"
 )
 
+// ConditionReplicationReady is kept for the clusters with replica channels.
+// It's False when any of the channels is broken.
+const ConditionReplicationReady AppState = "ReplicationReady"
+
+// ConditionUpgradeFailed is set when SmartUpdate rolls the PXC pods back
+// to the previous revision because the updated pod can't join the cluster
+const ConditionUpgradeFailed AppState = "UpgradeFailed"
+
+// ConditionMajorUpgradeBlocked is set while the major version upgrade
+// is blocked by the findings of the pre-upgrade checks
+const ConditionMajorUpgradeBlocked AppState = "MajorUpgradeBlocked"
+
 type ClusterCondition struct {
 	Status             ConditionStatus `json:"status,omitempty"`
 	Type               AppState        `json:"type,omitempty"`
@@ -189,13 +599,9 @@ func (cr *PerconaXtraDBCluster) Validate() error {
 		return errors.New("pxc.Image can't be empty")
 	}
 
-	for _, v := range c.PXC.ReplicationChannels {
-		if v.Name == "" {
-			return errors.New("pxc.replicationChannels.Name can't be empty")
-		}
-		if v.SecretName == "" {
-			return errors.New("pxc.replicationChannels.SecretName can't be empty")
-		}
+	err := c.PXC.checkReplicationChannels()
+	if err != nil {
+		return err
 	}
 
 	if c.PMM != nil && c.PMM.Enabled {
@@ -223,6 +629,18 @@ func (cr *PerconaXtraDBCluster) Validate() error {
 		}
 	}
 
+	if c.Replicas != nil && c.Replicas.Enabled {
+		if c.Replicas.VolumeSpec == nil {
+			return errors.New("replicas: volumeSpec should be specified")
+		}
+		if err := c.Replicas.VolumeSpec.validate(); err != nil {
+			return errors.Wrap(err, "replicas: validate volume spec")
+		}
+		if c.Backup == nil {
+			return errors.New("replicas require backup section to be specified, its image is used to copy data")
+		}
+	}
+
 	if c.ProxySQL != nil && c.ProxySQL.Enabled {
 		if c.ProxySQL.Image == "" {
 			return errors.New("proxysql.Image can't be empty")
@@ -248,6 +666,11 @@ func (cr *PerconaXtraDBCluster) Validate() error {
 			if !ok {
 				return errors.Errorf("pitr storage %s doesn't exist", cr.Spec.Backup.PITR.StorageName)
 			}
+			switch cr.Spec.Backup.PITR.Compression {
+			case "", "gzip", "zstd":
+			default:
+				return errors.Errorf("backup.PITR.Compression %s is not supported, use gzip or zstd", cr.Spec.Backup.PITR.Compression)
+			}
 		}
 		for _, sch := range c.Backup.Schedule {
 			strg, ok := cr.Spec.Backup.Storages[sch.StorageName]
@@ -266,15 +689,94 @@ func (cr *PerconaXtraDBCluster) Validate() error {
 		}
 	}
 
+	if c.DataSource != nil {
+		if c.Backup == nil {
+			return errors.New("dataSource requires backup section to be specified")
+		}
+		sources := 0
+		for _, set := range []bool{c.DataSource.BackupName != "", c.DataSource.BackupSource != nil, c.DataSource.BackupRef != nil} {
+			if set {
+				sources++
+			}
+		}
+		if sources == 0 {
+			return errors.New("dataSource.backupName, dataSource.backupSource and dataSource.backupRef can't be empty simultaneously")
+		}
+		if sources > 1 {
+			return errors.New("only one of dataSource.backupName, dataSource.backupSource and dataSource.backupRef can be specified")
+		}
+		if c.DataSource.PITR != nil && c.DataSource.PITR.BackupSource == nil {
+			return errors.New("dataSource.pitr.backupSource can't be empty")
+		}
+	}
+
 	if c.UpdateStrategy == SmartUpdateStatefulSetStrategyType &&
 		(c.ProxySQL == nil || !c.ProxySQL.Enabled) &&
 		(c.HAProxy == nil || !c.HAProxy.Enabled) {
 		return errors.Errorf("ProxySQL or HAProxy should be enabled if SmartUpdate set")
 	}
 
+	if err := c.UpgradeOptions.validate(c.PXC.Size); err != nil {
+		return errors.Wrap(err, "upgradeOptions")
+	}
+
+	for i, w := range c.MaintenanceWindows {
+		if _, err := w.parse(); err != nil {
+			return errors.Wrapf(err, "maintenanceWindows[%d]", i)
+		}
+	}
+
+	return nil
+}
+
+func (o *UpgradeOptions) validate(size int32) error {
+	switch o.Canary {
+	case "", UpgradeCanaryNever, UpgradeCanaryAlways, UpgradeCanaryMajor:
+	default:
+		return errors.Errorf("canary should be one of %s, %s, %s", UpgradeCanaryNever, UpgradeCanaryAlways, UpgradeCanaryMajor)
+	}
+
+	if o.Partition != nil && *o.Partition < 0 {
+		return errors.New("partition can't be negative")
+	}
+
+	if m := o.VersionMatrix; m != nil {
+		if (m.ConfigMap == nil) == (m.File == "") {
+			return errors.New("versionMatrix should have either configMap or file")
+		}
+		if m.ConfigMap != nil && m.ConfigMap.Name == "" {
+			return errors.New("versionMatrix.configMap.name can't be empty")
+		}
+	}
+
+	if o.MaxUnavailable != nil {
+		n, err := intstr.GetValueFromIntOrPercent(o.MaxUnavailable, int(size), false)
+		if err != nil {
+			return errors.Wrap(err, "maxUnavailable")
+		}
+		// the rest of the nodes has to keep the quorum
+		if n > 1 && n > int(size-1)/2 {
+			return errors.Errorf("maxUnavailable %d breaks the quorum of %d nodes", n, size)
+		}
+	}
+
 	return nil
 }
 
+// MaxUnavailablePods is the number of pods SmartUpdate restarts at once
+func (o *UpgradeOptions) MaxUnavailablePods(size int32) int {
+	if o.MaxUnavailable == nil {
+		return 1
+	}
+
+	n, err := intstr.GetValueFromIntOrPercent(o.MaxUnavailable, int(size), false)
+	if err != nil || n < 1 {
+		return 1
+	}
+
+	return n
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // PerconaXtraDBClusterList contains a list of PerconaXtraDBCluster
@@ -617,6 +1119,41 @@ func (cr *PerconaXtraDBCluster) CheckNSetDefaults(serverVersion *version.ServerV
 		}
 	}
 
+	if c.Replicas != nil && c.Replicas.Enabled {
+		if c.Replicas.Image == "" {
+			c.Replicas.Image = c.PXC.Image
+		}
+		if len(c.Replicas.ImagePullPolicy) == 0 {
+			c.Replicas.ImagePullPolicy = c.PXC.ImagePullPolicy
+		}
+
+		changed = c.Replicas.VolumeSpec.reconcileOpts()
+
+		c.Replicas.SSLSecretName = c.PXC.SSLSecretName
+		c.Replicas.SSLInternalSecretName = c.PXC.SSLInternalSecretName
+		c.Replicas.VaultSecretName = c.PXC.VaultSecretName
+		c.Replicas.EnvVarsSecretName = c.PXC.EnvVarsSecretName
+
+		if c.Replicas.PodDisruptionBudget == nil {
+			defaultMaxUnavailable := intstr.FromInt(1)
+			c.Replicas.PodDisruptionBudget = &PodDisruptionBudgetSpec{MaxUnavailable: &defaultMaxUnavailable}
+		}
+
+		if c.Replicas.TerminationGracePeriodSeconds == nil {
+			c.Replicas.TerminationGracePeriodSeconds = &defaultPXCGracePeriodSec
+		}
+
+		if len(c.Replicas.ServiceAccountName) == 0 {
+			c.Replicas.ServiceAccountName = workloadSA
+		}
+
+		c.Replicas.reconcileAffinityOpts()
+
+		if c.Pause {
+			c.Replicas.Size = 0
+		}
+	}
+
 	if c.ProxySQL != nil && c.ProxySQL.Enabled {
 		if len(c.ProxySQL.ImagePullPolicy) == 0 {
 			c.ProxySQL.ImagePullPolicy = corev1.PullAlways
@@ -893,6 +1430,18 @@ func (cr *PerconaXtraDBCluster) ProxySQLUnreadyServiceNamespacedName() types.Nam
 	}
 }
 
+// ServingClusterAnnotation points the proxy services of the cluster to the pods
+// of another cluster, e.g. the standby cluster a blue-green restore has switched over to
+const ServingClusterAnnotation = "percona.com/serving-cluster"
+
+// ServingCluster returns the name of the cluster the proxy services of the cluster point to
+func (cr *PerconaXtraDBCluster) ServingCluster() string {
+	if name := cr.Annotations[ServingClusterAnnotation]; name != "" {
+		return name
+	}
+	return cr.Name
+}
+
 func (cr *PerconaXtraDBCluster) ProxySQLServiceNamespacedName() types.NamespacedName {
 	return types.NamespacedName{
 		Name:      cr.Name + "-proxysql",
@@ -918,6 +1467,15 @@ func (cr *PerconaXtraDBCluster) HAProxyEnabled() bool {
 	return cr.Spec.HAProxy != nil && cr.Spec.HAProxy.Enabled
 }
 
+func (cr *PerconaXtraDBCluster) ReplicasEnabled() bool {
+	return cr.Spec.Replicas != nil && cr.Spec.Replicas.Enabled
+}
+
+// RestartsDeferred is true if the pods can't be restarted until the next maintenance window
+func (cr *PerconaXtraDBCluster) RestartsDeferred() bool {
+	return cr.Status.Maintenance != nil && !cr.Status.Maintenance.WindowOpen
+}
+
 func (cr *PerconaXtraDBCluster) ProxySQLEnabled() bool {
 	return cr.Spec.ProxySQL != nil && cr.Spec.ProxySQL.Enabled
 }
@@ -943,17 +1501,94 @@ func (s *PerconaXtraDBClusterStatus) ClusterStatus(inProgress, deleted bool) App
 
 const maxStatusesQuantity = 20
 
+// AddCondition adds the condition of the cluster state if the state has changed.
+// Only the last states are kept, the conditions set with SetCondition are left as is.
 func (s *PerconaXtraDBClusterStatus) AddCondition(c ClusterCondition) {
-	if len(s.Conditions) == 0 {
-		s.Conditions = append(s.Conditions, c)
+	states := 0
+	last := -1
+	for i := range s.Conditions {
+		if isStateCondition(s.Conditions[i].Type) {
+			states++
+			last = i
+		}
+	}
+	if last >= 0 && s.Conditions[last].Type == c.Type {
 		return
 	}
+	s.Conditions = append(s.Conditions, c)
+	states++
 
-	if s.Conditions[len(s.Conditions)-1].Type != c.Type {
-		s.Conditions = append(s.Conditions, c)
+	if states <= maxStatusesQuantity {
+		return
 	}
+	conditions := s.Conditions[:0]
+	for _, cond := range s.Conditions {
+		if states > maxStatusesQuantity && isStateCondition(cond.Type) {
+			states--
+			continue
+		}
+		conditions = append(conditions, cond)
+	}
+	s.Conditions = conditions
+}
+
+// isStateCondition checks if the condition type is the cluster state
+// and the condition is a part of the states history
+func isStateCondition(t AppState) bool {
+	switch t {
+	case AppStateUnknown, AppStateInit, AppStatePaused, AppStateStopping, AppStateReady, AppStateError:
+		return true
+	}
+	return false
+}
+
+// SetCondition updates the condition of the same type in place
+// or adds it if there is no such condition yet.
+// It's meant for the conditions that aren't cluster states.
+func (s *PerconaXtraDBClusterStatus) SetCondition(c ClusterCondition) {
+	s.Conditions = setCondition(s.Conditions, c)
+}
+
+// RemoveCondition removes the conditions of the type
+func (s *PerconaXtraDBClusterStatus) RemoveCondition(t AppState) {
+	s.Conditions = removeCondition(s.Conditions, t)
+}
+
+// setCondition updates the condition of the same type in place or adds it.
+// The transition time is kept if the status hasn't changed.
+func setCondition(conditions []ClusterCondition, c ClusterCondition) []ClusterCondition {
+	if c.LastTransitionTime.IsZero() {
+		c.LastTransitionTime = metav1.Now()
+	}
+	for i := range conditions {
+		if conditions[i].Type != c.Type {
+			continue
+		}
+		if conditions[i].Status == c.Status {
+			c.LastTransitionTime = conditions[i].LastTransitionTime
+		}
+		conditions[i] = c
+		return conditions
+	}
+
+	return append(conditions, c)
+}
 
-	if len(s.Conditions) > maxStatusesQuantity {
-		s.Conditions = s.Conditions[len(s.Conditions)-maxStatusesQuantity:]
+func removeCondition(conditions []ClusterCondition, t AppState) []ClusterCondition {
+	kept := conditions[:0]
+	for _, c := range conditions {
+		if c.Type != t {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func findCondition(conditions []ClusterCondition, t AppState) *ClusterCondition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
 	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestReconcileAffinity(t *testing.T) {
@@ -75,3 +76,57 @@ func TestReconcileAffinity(t *testing.T) {
 		}
 	}
 }
+
+func TestUpgradeOptionsValidate(t *testing.T) {
+	intOrStr := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	negative := int32(-1)
+
+	tests := map[string]struct {
+		opts    UpgradeOptions
+		size    int32
+		wantErr bool
+	}{
+		"empty":                {UpgradeOptions{}, 3, false},
+		"canary":               {UpgradeOptions{Canary: UpgradeCanaryMajor}, 3, false},
+		"wrong canary":         {UpgradeOptions{Canary: "sometimes"}, 3, true},
+		"negative partition":   {UpgradeOptions{Partition: &negative}, 3, true},
+		"one of three":         {UpgradeOptions{MaxUnavailable: intOrStr(intstr.FromInt(1))}, 3, false},
+		"two of three":         {UpgradeOptions{MaxUnavailable: intOrStr(intstr.FromInt(2))}, 3, true},
+		"two of five":          {UpgradeOptions{MaxUnavailable: intOrStr(intstr.FromInt(2))}, 5, false},
+		"percent keeps quorum": {UpgradeOptions{MaxUnavailable: intOrStr(intstr.FromString("40%"))}, 5, false},
+		"percent breaks":       {UpgradeOptions{MaxUnavailable: intOrStr(intstr.FromString("60%"))}, 5, true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.opts.validate(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddConditionKeepsSetConditions(t *testing.T) {
+	s := &PerconaXtraDBClusterStatus{}
+	s.AddCondition(ClusterCondition{Type: AppStateInit, Status: ConditionTrue})
+	s.SetCondition(ClusterCondition{Type: ConditionReplicationReady, Status: ConditionTrue})
+	s.AddCondition(ClusterCondition{Type: AppStateInit, Status: ConditionTrue})
+	if len(s.Conditions) != 2 {
+		t.Fatalf("unchanged state is added again: %+v", s.Conditions)
+	}
+
+	for i := 0; i < maxStatusesQuantity+5; i++ {
+		state := AppStateReady
+		if i%2 == 1 {
+			state = AppStateInit
+		}
+		s.AddCondition(ClusterCondition{Type: state, Status: ConditionTrue})
+	}
+	if len(s.Conditions) != maxStatusesQuantity+1 {
+		t.Errorf("expected %d conditions, got %d", maxStatusesQuantity+1, len(s.Conditions))
+	}
+	if findCondition(s.Conditions, ConditionReplicationReady) == nil {
+		t.Errorf("ReplicationReady condition is removed with the old states: %+v", s.Conditions)
+	}
+}
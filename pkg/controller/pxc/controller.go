@@ -24,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -73,12 +74,14 @@ func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 
 	return &ReconcilePerconaXtraDBCluster{
 		client:        mgr.GetClient(),
+		apiReader:     mgr.GetAPIReader(),
 		scheme:        mgr.GetScheme(),
 		crons:         NewCronRegistry(),
 		serverVersion: sv,
 		clientcmd:     cli,
 		lockers:       newLockStore(),
 		log:           zapr.NewLogger(zapLog),
+		recorder:      mgr.GetEventRecorderFor("perconaxtradbcluster-controller"),
 	}, nil
 }
 
@@ -113,6 +116,12 @@ type ReconcilePerconaXtraDBCluster struct {
 	serverVersion  *version.ServerVersion
 	lockers        lockStore
 	log            logr.Logger
+	// apiReader reads objects directly from the apiserver, e.g. the cluster-wide
+	// ones the namespaced operator has no permissions to watch
+	apiReader client.Reader
+	// versionCache keeps the last answers of the version service
+	versionCache sync.Map
+	recorder     record.EventRecorder
 }
 
 func (r *ReconcilePerconaXtraDBCluster) logger(name, namespace string) logr.Logger {
@@ -234,10 +243,14 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 	}
 
 	if o.CompareVersionWith("1.7.0") >= 0 && *o.Spec.PXC.AutoRecovery {
-		err = r.recoverFullClusterCrashIfNeeded(o)
+		res, err := r.recoverFullClusterCrashIfNeeded(o)
 		if err != nil {
 			reqLogger.Error(err, "Failed to check if cluster needs to recover")
 		}
+		// the cluster is reconciled once the bootstrap pod has started
+		if res.RequeueAfter > 0 {
+			return res, nil
+		}
 	}
 
 	if o.ObjectMeta.DeletionTimestamp != nil {
@@ -299,10 +312,43 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 		}
 	}
 
-	err = r.deploy(o)
+	err = r.reconcileDataSource(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "reconcile data source")
+	}
+	hold, err := r.dataSourceHold(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "check data source restore")
+	}
+
+	err = r.reconcileMajorUpgrade(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "reconcile major upgrade")
+	}
+
+	err = r.reconcileMaintenance(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "reconcile maintenance windows")
+	}
+
+	err = r.reconcileQuorum(o)
+	if err != nil {
+		reqLogger.Error(err, "Failed to check the quorum of the PXC nodes")
+	}
+
+	err = r.reconcileSwitchover(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "reconcile primary switchover")
+	}
+
+	err = r.deploy(o, hold)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if hold.pxc {
+		reqLogger.Info("waiting for the data source to be restored", "restore", o.Status.DataSourceRestore)
+		return rr, nil
+	}
 
 	operatorPod, err := k8s.OperatorPod(r.client)
 	if err != nil {
@@ -365,12 +411,21 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 		}
 	}
 
+	if hold.proxies {
+		return rr, nil
+	}
+
 	if o.Spec.HAProxy != nil && o.Spec.HAProxy.Enabled {
 		err = r.updatePod(statefulset.NewHAProxy(o), o.Spec.HAProxy, o, nil)
 		if err != nil {
 			return reconcile.Result{}, errors.Wrap(err, "HAProxy upgrade error")
 		}
 
+		err = r.labelServingProxies(o, statefulset.NewHAProxy(o))
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "label serving HAProxy pods")
+		}
+
 		haProxyService := pxc.NewServiceHAProxy(o, crOwnerRef)
 		ports := []corev1.ServicePort{
 			{
@@ -460,6 +515,11 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 			return reconcile.Result{}, errors.Wrap(err, "ProxySQL upgrade error")
 		}
 
+		err = r.labelServingProxies(o, proxysqlSet)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "label serving ProxySQL pods")
+		}
+
 		currentService := &corev1.Service{}
 		err := r.client.Get(context.TODO(), types.NamespacedName{Name: o.ProxySQLServiceNamespacedName().Name, Namespace: o.ProxySQLServiceNamespacedName().Namespace}, currentService)
 		if err != nil {
@@ -503,6 +563,9 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 			)
 		}
 
+		// the service follows the serving cluster after a blue-green restore
+		currentService.Spec.Selector = pxc.NewServiceProxySQL(o).Spec.Selector
+
 		if o.CompareVersionWith("1.9.0") >= 0 {
 			currentService.ObjectMeta.Labels["app.kubernetes.io/component"] = "proxysql"
 			currentService.ObjectMeta.Labels["app.kubernetes.io/managed-by"] = "percona-xtradb-cluster-operator"
@@ -534,11 +597,61 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 		}
 	}
 
+	if o.ReplicasEnabled() {
+		rInits, err := replicaInits(o, inits)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		err = r.updatePod(statefulset.NewReplica(o), o.Spec.Replicas, o, rInits)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "replicas upgrade error")
+		}
+
+		for _, svc := range []*corev1.Service{pxc.NewServiceReplicas(o), pxc.NewServiceReplicasUnready(o)} {
+			err := setControllerReference(o, svc, r.scheme)
+			if err != nil {
+				return reconcile.Result{}, errors.Wrap(err, "setControllerReference")
+			}
+
+			err = r.createOrUpdate(svc)
+			if err != nil {
+				return reconcile.Result{}, errors.Wrap(err, "replicas service upgrade error")
+			}
+		}
+	} else {
+		err = r.deleteStatefulSet(o, statefulset.NewReplica(o), false)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "delete replicas stateful set")
+		}
+		err = r.deleteServices(pxc.NewServiceReplicas(o), pxc.NewServiceReplicasUnready(o))
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "delete replicas services")
+		}
+	}
+
 	err = r.reconcileBackups(o)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	err = r.reconcilePersistentVolumes(o)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "reconcile persistent volumes")
+	}
+
+	// replication problems shouldn't put the whole cluster into the error state,
+	// they are reported with the ReplicationReady condition
+	replErr := r.reconcileReplication(o)
+	if replErr != nil {
+		reqLogger.Error(replErr, "reconcile replication")
+	}
+	setReplicationCondition(o, replErr)
+
+	replicasErr := r.reconcileReplicas(o)
+	if replicasErr != nil {
+		reqLogger.Error(replicasErr, "reconcile read replicas")
+	}
+
 	if err := r.fetchVersionFromPXC(o, pxcSet); err != nil {
 		return rr, errors.Wrap(err, "update CR version")
 	}
@@ -551,7 +664,7 @@ func (r *ReconcilePerconaXtraDBCluster) Reconcile(request reconcile.Request) (re
 	return rr, nil
 }
 
-func (r *ReconcilePerconaXtraDBCluster) deploy(cr *api.PerconaXtraDBCluster) error {
+func (r *ReconcilePerconaXtraDBCluster) deploy(cr *api.PerconaXtraDBCluster, hold dataSourceHold) error {
 	stsApp := statefulset.NewNode(cr)
 	err := r.reconcileConfigMap(cr)
 	if err != nil {
@@ -637,14 +750,33 @@ func (r *ReconcilePerconaXtraDBCluster) deploy(cr *api.PerconaXtraDBCluster) err
 		nodeSet.Spec.Template.Annotations["percona.com/vault-config-hash"] = vaultConfigHash
 	}
 	nodeSet.Spec.Template.Spec.Tolerations = cr.Spec.PXC.Tolerations
+	if volumeExpansionEnabled(cr) {
+		err = r.keepVolumeClaimTemplates(nodeSet)
+		if err != nil {
+			return errors.Wrap(err, "keep pxc volume claim templates")
+		}
+	}
 	err = setControllerReference(cr, nodeSet, r.scheme)
 	if err != nil {
 		return err
 	}
 
-	err = r.createOrUpdate(nodeSet)
-	if err != nil {
-		return errors.Wrap(err, "create newStatefulSetNode")
+	if hold.pxc {
+		return nil
+	}
+
+	if pxcUpdateFrozen(cr) || majorUpgradeHeld(cr) {
+		// the rolled back template is kept until the spec is changed,
+		// the previous major version is kept until the upgrade is checked and backed up
+		err = r.client.Create(context.TODO(), nodeSet)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "create newStatefulSetNode")
+		}
+	} else {
+		err = r.createOrUpdate(nodeSet)
+		if err != nil {
+			return errors.Wrap(err, "create newStatefulSetNode")
+		}
 	}
 
 	err = r.createService(cr, pxc.NewServicePXCUnready(cr))
@@ -667,6 +799,10 @@ func (r *ReconcilePerconaXtraDBCluster) deploy(cr *api.PerconaXtraDBCluster) err
 		return errors.Wrap(err, "get PXC stateful set")
 	}
 
+	if hold.proxies {
+		return nil
+	}
+
 	// HAProxy StatefulSet
 	if cr.Spec.HAProxy != nil && cr.Spec.HAProxy.Enabled {
 		sfsHAProxy := statefulset.NewHAProxy(cr)
@@ -798,9 +934,73 @@ func (r *ReconcilePerconaXtraDBCluster) deploy(cr *api.PerconaXtraDBCluster) err
 		}
 	}
 
+	if cr.ReplicasEnabled() {
+		rInits, err := replicaInits(cr, inits)
+		if err != nil {
+			return err
+		}
+		sfsReplica := statefulset.NewReplica(cr)
+		replicaSet, err := pxc.StatefulSet(sfsReplica, cr.Spec.Replicas, cr, rInits, logger, r.getConfigVolume)
+		if err != nil {
+			return errors.Wrap(err, "create replicas StatefulSet")
+		}
+		err = setControllerReference(cr, replicaSet, r.scheme)
+		if err != nil {
+			return err
+		}
+
+		if replicaSet.Spec.Template.Annotations == nil {
+			replicaSet.Spec.Template.Annotations = make(map[string]string)
+		}
+		replicaSet.Spec.Template.Annotations["percona.com/configuration-hash"] = r.getConfigHash(cr, sfsReplica)
+		if sslHash != "" {
+			replicaSet.Spec.Template.Annotations["percona.com/ssl-hash"] = sslHash
+		}
+		if sslInternalHash != "" {
+			replicaSet.Spec.Template.Annotations["percona.com/ssl-internal-hash"] = sslInternalHash
+		}
+		if vaultConfigHash != "" {
+			replicaSet.Spec.Template.Annotations["percona.com/vault-config-hash"] = vaultConfigHash
+		}
+		err = r.client.Create(context.TODO(), replicaSet)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "create newStatefulSetReplicas")
+		}
+
+		err = r.createService(cr, pxc.NewServiceReplicasUnready(cr))
+		if err != nil {
+			return errors.Wrap(err, "create replicas ServiceUnready")
+		}
+		err = r.createService(cr, pxc.NewServiceReplicas(cr))
+		if err != nil {
+			return errors.Wrap(err, "create replicas Service")
+		}
+
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: replicaSet.Name, Namespace: replicaSet.Namespace}, replicaSet)
+		if err == nil {
+			err := r.reconcilePDB(cr.Spec.Replicas.PodDisruptionBudget, sfsReplica, cr.Namespace, replicaSet)
+			if err != nil {
+				return errors.Wrapf(err, "PodDisruptionBudget for %s", replicaSet.Name)
+			}
+		} else if !k8serrors.IsNotFound(err) {
+			return errors.Wrap(err, "get replicas stateful set")
+		}
+	}
+
 	return nil
 }
 
+// replicaInits adds the container that copies the data from the cluster
+// to the init containers of the PXC pods
+func replicaInits(cr *api.PerconaXtraDBCluster, pxcInits []corev1.Container) ([]corev1.Container, error) {
+	bootstrapC, err := statefulset.NewReplica(cr).BootstrapContainer(cr.Spec.Replicas, "internal-"+cr.Name, cr)
+	if err != nil {
+		return nil, errors.Wrap(err, "replica bootstrap container")
+	}
+
+	return append(append([]corev1.Container{}, pxcInits...), bootstrapC), nil
+}
+
 func (r *ReconcilePerconaXtraDBCluster) createService(cr *api.PerconaXtraDBCluster, svc *corev1.Service) error {
 	err := setControllerReference(cr, svc, r.scheme)
 	if err != nil {
@@ -0,0 +1,121 @@
+package pxc
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+// reconcileDataSource creates the restore that bootstraps the cluster from spec.dataSource.
+// It is done only once on the cluster creation: the restore is kept in the status,
+// the PXC statefulset and its volumes don't exist yet. The statefulset isn't created
+// until the restore has filled the volume of the first PXC pod, see dataSourceHold.
+func (r *ReconcilePerconaXtraDBCluster) reconcileDataSource(cr *api.PerconaXtraDBCluster) error {
+	if cr.Spec.DataSource == nil || cr.Status.DataSourceRestore != "" {
+		return nil
+	}
+
+	app := statefulset.NewNode(cr)
+	// the statefulset is missing while it's recreated for the volume expansion
+	if recreatingStatefulSet(cr, app.Name()) != nil {
+		return nil
+	}
+
+	sts := app.StatefulSet()
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: sts.Name, Namespace: sts.Namespace}, &appsv1.StatefulSet{})
+	if err == nil {
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return errors.Wrap(err, "get pxc statefulset")
+	}
+
+	// the data left by the deleted statefulset isn't overwritten
+	pvcs, err := r.statefulSetPVCs(sts, statefulset.DataVolumeName, app.Labels())
+	if err != nil {
+		return errors.Wrap(err, "get pxc volumes")
+	}
+	if len(pvcs) > 0 {
+		return nil
+	}
+
+	restore := &api.PerconaXtraDBClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dataSourceRestoreName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				api.DataSourceRestoreLabel: cr.Name,
+			},
+		},
+		Spec: api.PerconaXtraDBClusterRestoreSpec{
+			PXCCluster:   cr.Name,
+			BackupName:   cr.Spec.DataSource.BackupName,
+			BackupSource: cr.Spec.DataSource.BackupSource,
+			BackupRef:    cr.Spec.DataSource.BackupRef,
+			PITR:         cr.Spec.DataSource.PITR,
+		},
+	}
+
+	err = setControllerReference(cr, restore, r.scheme)
+	if err != nil {
+		return errors.Wrap(err, "set controller reference")
+	}
+
+	err = r.client.Create(context.TODO(), restore)
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "create restore %s", restore.Name)
+	}
+
+	cr.Status.DataSourceRestore = restore.Name
+	r.logger(cr.Name, cr.Namespace).Info("cluster is going to be bootstrapped from the data source", "restore", restore.Name)
+
+	return nil
+}
+
+// dataSourceHold is the part of the cluster that isn't deployed while
+// the cluster is bootstrapped from the data source
+type dataSourceHold struct {
+	pxc     bool
+	proxies bool
+}
+
+// dataSourceHold returns what isn't deployed until the data source restore is finished.
+// The PXC statefulset is created once the restore has filled the volume of the first PXC pod,
+// the proxies are created once the restore is finished, so the clients can't write
+// into the cluster before its data is restored.
+func (r *ReconcilePerconaXtraDBCluster) dataSourceHold(cr *api.PerconaXtraDBCluster) (dataSourceHold, error) {
+	if cr.Status.DataSourceRestore == "" {
+		return dataSourceHold{}, nil
+	}
+
+	restore := &api.PerconaXtraDBClusterRestore{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Status.DataSourceRestore, Namespace: cr.Namespace}, restore)
+	if k8serrors.IsNotFound(err) {
+		return dataSourceHold{}, nil
+	}
+	if err != nil {
+		return dataSourceHold{}, errors.Wrapf(err, "get restore %s", cr.Status.DataSourceRestore)
+	}
+	if restore.IsFinished() {
+		return dataSourceHold{}, nil
+	}
+
+	switch restore.Status.State {
+	case api.RestorePreparePITR, api.RestorePITR, api.RestoreStartCluster:
+		// the data is restored, the nodes are started to apply binlogs and to join the cluster
+		return dataSourceHold{proxies: true}, nil
+	}
+
+	return dataSourceHold{pxc: true, proxies: true}, nil
+}
+
+func dataSourceRestoreName(cr *api.PerconaXtraDBCluster) string {
+	return cr.Name + "-datasource"
+}
@@ -0,0 +1,115 @@
+package pxc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+func TestReconcileDataSource(t *testing.T) {
+	newCluster := func() *api.PerconaXtraDBCluster {
+		cr := newCR("cluster1", "ns")
+		cr.Spec.DataSource = &api.DataSource{BackupName: "backup1"}
+		return cr
+	}
+
+	tests := map[string]struct {
+		cr      func() *api.PerconaXtraDBCluster
+		objs    []runtime.Object
+		restore bool
+	}{
+		"new cluster": {newCluster, nil, true},
+		"restore created": {func() *api.PerconaXtraDBCluster {
+			cr := newCluster()
+			cr.Status.DataSourceRestore = dataSourceRestoreName(cr)
+			return cr
+		}, nil, false},
+		"statefulset recreated": {func() *api.PerconaXtraDBCluster {
+			cr := newCluster()
+			cr.Status.VolumeExpansion = []api.VolumeExpansionStatus{{Component: "pxc", State: api.VolumeExpansionRecreating}}
+			return cr
+		}, nil, false},
+		"volumes exist": {newCluster, []runtime.Object{&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      statefulset.DataVolumeName + "-cluster1-pxc-0",
+				Namespace: "ns",
+				Labels:    statefulset.NewNode(newCluster()).Labels(),
+			},
+		}}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := tt.cr()
+			r := buildFakeClient(tt.objs)
+			r.scheme.AddKnownTypes(api.SchemeGroupVersion, &api.PerconaXtraDBClusterRestore{})
+			r.log = zapr.NewLogger(zap.NewNop())
+
+			err := r.reconcileDataSource(cr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: dataSourceRestoreName(cr), Namespace: "ns"}, &api.PerconaXtraDBClusterRestore{})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if created := err == nil; created != tt.restore {
+				t.Errorf("restore created = %v, want %v", created, tt.restore)
+			}
+			if tt.restore && cr.Status.DataSourceRestore != dataSourceRestoreName(cr) {
+				t.Errorf("restore isn't kept in the status")
+			}
+		})
+	}
+}
+
+func TestDataSourceHold(t *testing.T) {
+	restore := func(state api.BcpRestoreStates) []runtime.Object {
+		return []runtime.Object{&api.PerconaXtraDBClusterRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1-datasource", Namespace: "ns"},
+			Status:     api.PerconaXtraDBClusterRestoreStatus{State: state},
+		}}
+	}
+
+	tests := map[string]struct {
+		restore string
+		objs    []runtime.Object
+		want    dataSourceHold
+	}{
+		"no data source":   {"", nil, dataSourceHold{}},
+		"restore deleted":  {"cluster1-datasource", nil, dataSourceHold{}},
+		"restore started":  {"cluster1-datasource", restore(api.RestoreNew), dataSourceHold{pxc: true, proxies: true}},
+		"restore job runs": {"cluster1-datasource", restore(api.RestoreRestore), dataSourceHold{pxc: true, proxies: true}},
+		"binlogs applied":  {"cluster1-datasource", restore(api.RestorePITR), dataSourceHold{proxies: true}},
+		"restore finished": {"cluster1-datasource", restore(api.RestoreSucceeded), dataSourceHold{}},
+		"restore failed":   {"cluster1-datasource", restore(api.RestoreFailed), dataSourceHold{}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Status.DataSourceRestore = tt.restore
+			r := buildFakeClient(tt.objs)
+			r.scheme.AddKnownTypes(api.SchemeGroupVersion, &api.PerconaXtraDBClusterRestore{})
+
+			got, err := r.dataSourceHold(cr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("dataSourceHold() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var (
@@ -22,29 +24,85 @@ var (
 
 const logPrefix = `#####################################################LAST_LINE`
 
-func (r *ReconcilePerconaXtraDBCluster) recoverFullClusterCrashIfNeeded(cr *v1.PerconaXtraDBCluster) error {
+// recoveryStateCmd prints the recovery-case file the entrypoint creates while the node waits
+// for the recovery and grastate.dat of the node
+const recoveryStateCmd = `test -f /tmp/recovery-case || exit 0
+echo "waiting: 1"
+sed 's/^/recovered_/' /tmp/recovery-case
+cat /var/lib/mysql/grastate.dat 2>/dev/null || :`
+
+// zeroUUID is in grastate.dat of the node that hasn't joined the cluster
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// fullCrashRecoveryWait is the time the bootstrap pod is given to start
+// before the pods are checked for the recovery again
+const fullCrashRecoveryWait = 30 * time.Second
+
+// recoverFullClusterCrashIfNeeded bootstraps the cluster from the pod with the most recent data
+// if all the PXC pods wait for the recovery. The returned result is set while the bootstrap pod starts.
+func (r *ReconcilePerconaXtraDBCluster) recoverFullClusterCrashIfNeeded(cr *v1.PerconaXtraDBCluster) (reconcile.Result, error) {
 	if cr.Spec.PXC.Size <= 0 {
-		return nil
+		return reconcile.Result{}, nil
+	}
+
+	// don't send a lot of signals to the same pod while it starts
+	st := cr.Status.FullCrashRecovery
+	if st != nil && st.Phase == v1.FullCrashRecoveryBootstrapping && st.SignaledAt != nil {
+		if wait := fullCrashRecoveryWait - time.Since(st.SignaledAt.Time); wait > 0 {
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
 	}
 
 	err := r.checkIfPodsRunning(cr)
 	if err != nil {
 		if err == ErrNotAllPXCPodsRunning {
-			return nil
+			return reconcile.Result{}, nil
 		}
-		return err
+		return reconcile.Result{}, err
 	}
 
-	isWaiting, _, err := r.isPodWaitingForRecovery(cr.Namespace, cr.Name+"-pxc-0")
+	isWaiting, _, err := r.podRecoveryState(cr.Namespace, cr.Name+"-pxc-0")
 	if err != nil {
-		return errors.Wrap(err, "failed to check if pxc pod 0 is waiting for recovery")
+		return reconcile.Result{}, errors.Wrap(err, "failed to check if pxc pod 0 is waiting for recovery")
 	}
 
 	if isWaiting {
-		return r.doFullCrashRecovery(cr.Name, cr.Namespace, int(cr.Spec.PXC.Size))
+		return r.doFullCrashRecovery(cr)
 	}
 
-	return nil
+	if st != nil && st.Phase == v1.FullCrashRecoveryBootstrapping {
+		st.Phase = v1.FullCrashRecoveryDone
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// podRecoveryState returns the position of the pod if it waits for the full crash recovery.
+// The entrypoint writes the position from grastate.dat or the wsrep recovery to the recovery-case
+// file, grastate.dat is read too for the pods started with the older entrypoint.
+func (r *ReconcilePerconaXtraDBCluster) podRecoveryState(namespace, podName string) (bool, v1.RecoveryCandidate, error) {
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: podName}, pod)
+	if err != nil {
+		return false, v1.RecoveryCandidate{}, errors.Wrap(err, "get pod")
+	}
+
+	var outb, errb bytes.Buffer
+	err = r.clientcmd.Exec(pod, "pxc", []string{"/bin/sh", "-c", recoveryStateCmd}, nil, &outb, &errb, false)
+	if err != nil {
+		return false, v1.RecoveryCandidate{}, errors.Errorf("exec: %v / %s", err, errb.String())
+	}
+
+	waiting, c := parseRecoveryState(podName, outb.String())
+	if waiting && c.Seqno < 0 {
+		// the older entrypoint prints the recovered seqno to the log only
+		ok, seq, err := r.isPodWaitingForRecovery(namespace, podName)
+		if err == nil && ok {
+			c.Seqno = seq
+		}
+	}
+
+	return waiting, c, nil
 }
 
 func (r *ReconcilePerconaXtraDBCluster) isPodWaitingForRecovery(namespace, podName string) (bool, int64, error) {
@@ -81,54 +139,145 @@ func parseSequence(log string) (int64, error) {
 	return seq, nil
 }
 
-func (r *ReconcilePerconaXtraDBCluster) doFullCrashRecovery(crName, namespace string, pxcSize int) error {
-	maxSeq := int64(-100)
-	maxSeqPod := ""
+// parseRecoveryState parses the recovery-case file with the "recovered_" prefix and grastate.dat.
+// The recovered position is preferred, the older entrypoint leaves the file empty.
+func parseRecoveryState(podName, out string) (bool, v1.RecoveryCandidate) {
+	c := v1.RecoveryCandidate{Pod: podName, Seqno: -1}
+
+	kv := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		s := strings.SplitN(line, ":", 2)
+		if len(s) != 2 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv[strings.TrimSpace(s[0])] = strings.TrimSpace(s[1])
+	}
+
+	if kv["waiting"] != "1" {
+		return false, c
+	}
+
+	get := func(key string) string {
+		if v := kv["recovered_"+key]; v != "" {
+			return v
+		}
+		return kv[key]
+	}
+
+	c.UUID = get("uuid")
+	if seq, err := strconv.ParseInt(get("seqno"), 10, 64); err == nil {
+		c.Seqno = seq
+	}
+	c.SafeToBootstrap = get("safe_to_bootstrap") == "1"
+
+	return true, c
+}
+
+// chooseBootstrapPod returns the pod with the most recent data. All pods have to belong
+// to the same cluster. If the seqnos are equal the pod safe to bootstrap from is preferred,
+// it's the last one that left the cluster.
+func chooseBootstrapPod(candidates []v1.RecoveryCandidate) (string, error) {
+	var uuidPod *v1.RecoveryCandidate
+	best := -1
+	for i := range candidates {
+		c := &candidates[i]
+		if c.UUID == "" || c.UUID == zeroUUID {
+			continue
+		}
+		if uuidPod == nil {
+			uuidPod = c
+		} else if c.UUID != uuidPod.UUID {
+			return "", errors.Errorf("pods have different cluster UUIDs: %s has %s, %s has %s", uuidPod.Pod, uuidPod.UUID, c.Pod, c.UUID)
+		}
+
+		if c.Seqno < 0 {
+			continue
+		}
+		if best < 0 || c.Seqno > candidates[best].Seqno ||
+			c.Seqno == candidates[best].Seqno && c.SafeToBootstrap && !candidates[best].SafeToBootstrap {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return "", errors.New("no pod has the known seqno")
+	}
+
+	return candidates[best].Pod, nil
+}
 
-	for i := 0; i < pxcSize; i++ {
-		podName := fmt.Sprintf("%s-pxc-%d", crName, i)
-		isPodWaitingForRecovery, seq, err := r.isPodWaitingForRecovery(namespace, podName)
+func describeCandidates(candidates []v1.RecoveryCandidate) string {
+	s := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		d := fmt.Sprintf("%s seqno %d", c.Pod, c.Seqno)
+		if c.SafeToBootstrap {
+			d += " safe_to_bootstrap"
+		}
+		s = append(s, d)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func (r *ReconcilePerconaXtraDBCluster) doFullCrashRecovery(cr *v1.PerconaXtraDBCluster) (reconcile.Result, error) {
+	candidates := make([]v1.RecoveryCandidate, 0, cr.Spec.PXC.Size)
+	for i := 0; i < int(cr.Spec.PXC.Size); i++ {
+		podName := fmt.Sprintf("%s-pxc-%d", cr.Name, i)
+		isPodWaitingForRecovery, c, err := r.podRecoveryState(cr.Namespace, podName)
 		if err != nil {
-			return errors.Wrapf(err, "parse %s pod logs", podName)
+			return reconcile.Result{}, errors.Wrapf(err, "get %s pod recovery state", podName)
 		}
 
 		if !isPodWaitingForRecovery {
-			return nil
+			return reconcile.Result{}, nil
 		}
 
-		if seq > maxSeq {
-			maxSeq = seq
-			maxSeqPod = podName
-		}
+		candidates = append(candidates, c)
 	}
-	logger := r.logger(crName, namespace)
+	logger := r.logger(cr.Name, cr.Namespace)
 	logger.Info("We are in full cluster crash, starting recovery")
-	logger.Info("Results of scanning sequences", "pod", maxSeqPod, "maxSeq", maxSeq)
+
+	st := &v1.FullCrashRecoveryStatus{Candidates: candidates, Time: metav1.Now()}
+	cr.Status.FullCrashRecovery = st
+
+	podName, err := chooseBootstrapPod(candidates)
+	if err != nil {
+		st.Phase = v1.FullCrashRecoveryBlocked
+		st.Message = err.Error()
+		r.recorder.Eventf(cr, corev1.EventTypeWarning, "FullCrashRecoveryBlocked",
+			"Can't choose the pod to bootstrap the cluster from: %v. Candidates: %s", err, describeCandidates(candidates))
+		return reconcile.Result{}, errors.Wrap(err, "choose the pod to bootstrap the cluster from")
+	}
+	st.BootstrapPod = podName
+
+	logger.Info("Results of scanning sequences", "pod", podName, "candidates", describeCandidates(candidates))
+	r.recorder.Eventf(cr, corev1.EventTypeNormal, "FullCrashRecovery",
+		"Bootstrap the cluster from %s. Candidates: %s", podName, describeCandidates(candidates))
 
 	pod := &corev1.Pod{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{
-		Namespace: namespace,
-		Name:      maxSeqPod,
+	err = r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: cr.Namespace,
+		Name:      podName,
 	}, pod)
 	if err != nil {
-		return errors.Wrap(err, "get pods defenition")
+		return reconcile.Result{}, errors.Wrap(err, "get pods defenition")
 	}
 
 	stderrBuf := &bytes.Buffer{}
 	err = r.clientcmd.Exec(pod, "pxc", []string{"/bin/sh", "-c", "kill -s USR1 1"}, nil, nil, stderrBuf, false)
 	if err != nil {
-		return errors.Wrap(err, "exec command in pod")
+		return reconcile.Result{}, errors.Wrap(err, "exec command in pod")
 	}
 
 	if stderrBuf.Len() != 0 {
-		return errors.New("invalid exec command return: " + stderrBuf.String())
+		return reconcile.Result{}, errors.New("invalid exec command return: " + stderrBuf.String())
 	}
 
-	// sleep there a little to start script and do not send
-	// a lot of signals to the same pod
-	time.Sleep(30 * time.Second)
+	now := metav1.Now()
+	st.Phase = v1.FullCrashRecoveryBootstrapping
+	st.SignaledAt = &now
 
-	return nil
+	return reconcile.Result{RequeueAfter: fullCrashRecoveryWait}, nil
 }
 
 func (r *ReconcilePerconaXtraDBCluster) checkIfPodsRunning(cr *v1.PerconaXtraDBCluster) error {
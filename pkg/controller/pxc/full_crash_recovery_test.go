@@ -0,0 +1,99 @@
+package pxc
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestParseRecoveryState(t *testing.T) {
+	const uuid = "7e2a3ed1-5e0c-11eb-8f1b-8b0b6a3bb4e3"
+	grastate := "# GALERA saved state\nversion: 2.1\nuuid:    " + uuid + "\nseqno:   -1\nsafe_to_bootstrap: 0\n"
+
+	tests := map[string]struct {
+		out     string
+		waiting bool
+		want    api.RecoveryCandidate
+	}{
+		"not waiting": {"", false, api.RecoveryCandidate{Pod: "pod", Seqno: -1}},
+		"recovered position": {"waiting: 1\nrecovered_uuid: " + uuid + "\nrecovered_seqno: 1234\nrecovered_safe_to_bootstrap: 0\n" + grastate,
+			true, api.RecoveryCandidate{Pod: "pod", UUID: uuid, Seqno: 1234}},
+		"older entrypoint": {"waiting: 1\n" + grastate, true, api.RecoveryCandidate{Pod: "pod", UUID: uuid, Seqno: -1}},
+		"safe to bootstrap": {"waiting: 1\nrecovered_uuid: " + uuid + "\nrecovered_seqno: 99\nrecovered_safe_to_bootstrap: 1\n",
+			true, api.RecoveryCandidate{Pod: "pod", UUID: uuid, Seqno: 99, SafeToBootstrap: true}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			waiting, c := parseRecoveryState("pod", tt.out)
+			if waiting != tt.waiting || c != tt.want {
+				t.Errorf("got %v %+v, want %v %+v", waiting, c, tt.waiting, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseBootstrapPod(t *testing.T) {
+	const (
+		uuid  = "7e2a3ed1-5e0c-11eb-8f1b-8b0b6a3bb4e3"
+		other = "0c9bd0a4-5e0d-11eb-a1c2-3f6e3a1a8c55"
+	)
+	node := func(pod, uuid string, seqno int64, safe bool) api.RecoveryCandidate {
+		return api.RecoveryCandidate{Pod: pod, UUID: uuid, Seqno: seqno, SafeToBootstrap: safe}
+	}
+
+	tests := map[string]struct {
+		candidates []api.RecoveryCandidate
+		pod        string
+		err        bool
+	}{
+		"highest seqno":            {[]api.RecoveryCandidate{node("pxc-0", uuid, 10, false), node("pxc-1", uuid, 12, false), node("pxc-2", uuid, 11, false)}, "pxc-1", false},
+		"safe to bootstrap on tie": {[]api.RecoveryCandidate{node("pxc-0", uuid, 12, false), node("pxc-1", uuid, 12, true), node("pxc-2", uuid, 11, false)}, "pxc-1", false},
+		"unknown seqno":            {[]api.RecoveryCandidate{node("pxc-0", uuid, -1, false), node("pxc-1", uuid, 5, false), node("pxc-2", "", -1, false)}, "pxc-1", false},
+		"empty node":               {[]api.RecoveryCandidate{node("pxc-0", zeroUUID, 20, false), node("pxc-1", uuid, 5, false)}, "pxc-1", false},
+		"different uuids":          {[]api.RecoveryCandidate{node("pxc-0", uuid, 10, false), node("pxc-1", other, 12, false)}, "", true},
+		"no seqno":                 {[]api.RecoveryCandidate{node("pxc-0", uuid, -1, false), node("pxc-1", uuid, -1, false)}, "", true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pod, err := chooseBootstrapPod(tt.candidates)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %s", pod)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pod != tt.pod {
+				t.Errorf("got %s, want %s", pod, tt.pod)
+			}
+		})
+	}
+}
+
+func TestFullCrashRecoveryWait(t *testing.T) {
+	r := &ReconcilePerconaXtraDBCluster{}
+	cr := newCR("cluster1", "ns")
+	cr.Spec.PXC = &api.PXCSpec{PodSpec: &api.PodSpec{Size: 3}}
+
+	signaled := metav1.NewTime(time.Now().Add(-10 * time.Second))
+	cr.Status.FullCrashRecovery = &api.FullCrashRecoveryStatus{
+		Phase:      api.FullCrashRecoveryBootstrapping,
+		SignaledAt: &signaled,
+	}
+
+	// the pods aren't checked while the bootstrap pod starts
+	res, err := r.recoverFullClusterCrashIfNeeded(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequeueAfter <= 0 || res.RequeueAfter > fullCrashRecoveryWait-10*time.Second {
+		t.Errorf("unexpected requeue after %v", res.RequeueAfter)
+	}
+}
@@ -0,0 +1,84 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+// reconcileMaintenance checks if the pods can be restarted now. Outside of the maintenance
+// windows the statefulsets are updated, but the rolling updates are stopped with the partition
+// and SmartUpdate doesn't restart the pods, so the restarts are shown as pending.
+func (r *ReconcilePerconaXtraDBCluster) reconcileMaintenance(cr *api.PerconaXtraDBCluster) error {
+	if cr.CompareVersionWith("1.9.0") < 0 || len(cr.Spec.MaintenanceWindows) == 0 {
+		cr.Status.Maintenance = nil
+		return nil
+	}
+
+	open, next, err := api.MaintenanceWindowOpen(cr.Spec.MaintenanceWindows, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "check maintenance windows")
+	}
+
+	st := &api.MaintenanceStatus{WindowOpen: open}
+	if !next.IsZero() {
+		t := metav1.NewTime(next)
+		st.NextWindow = &t
+	}
+
+	if !open {
+		st.Pending, err = r.pendingRestarts(cr)
+		if err != nil {
+			return errors.Wrap(err, "get pending restarts")
+		}
+	}
+
+	if prev := cr.Status.Maintenance; prev == nil || prev.WindowOpen != open {
+		r.logger(cr.Name, cr.Namespace).Info("maintenance window state is changed", "open", open, "next window", next)
+	}
+	cr.Status.Maintenance = st
+
+	return nil
+}
+
+// pendingRestarts returns the statefulsets that have pods not running the update revision
+func (r *ReconcilePerconaXtraDBCluster) pendingRestarts(cr *api.PerconaXtraDBCluster) ([]string, error) {
+	apps := []api.StatefulApp{statefulset.NewNode(cr)}
+	if cr.HAProxyEnabled() {
+		apps = append(apps, statefulset.NewHAProxy(cr))
+	}
+	if cr.ProxySQLEnabled() {
+		apps = append(apps, statefulset.NewProxy(cr))
+	}
+	if cr.ReplicasEnabled() {
+		apps = append(apps, statefulset.NewReplica(cr))
+	}
+
+	pending := []string{}
+	for _, a := range apps {
+		sfs := &appsv1.StatefulSet{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: a.StatefulSet().Name, Namespace: cr.Namespace}, sfs)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %s statefulset", a.Name())
+		}
+
+		if sfs.Status.UpdatedReplicas < sfs.Status.Replicas {
+			pending = append(pending, fmt.Sprintf("%s: %d of %d pods wait for revision %s",
+				a.Name(), sfs.Status.Replicas-sfs.Status.UpdatedReplicas, sfs.Status.Replicas, sfs.Status.UpdateRevision))
+		}
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,340 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+)
+
+const (
+	// majorUpgradeRecheckPeriod is how often the checks of the blocked upgrade are repeated
+	majorUpgradeRecheckPeriod = 5 * time.Minute
+	// maxMajorUpgradeFindings limits the findings kept in the status
+	maxMajorUpgradeFindings = 20
+)
+
+// majorUpgradeHeld is true while the PXC statefulset keeps the previous major version
+func majorUpgradeHeld(cr *api.PerconaXtraDBCluster) bool {
+	if cr.Status.MajorUpgrade == nil {
+		return false
+	}
+
+	switch cr.Status.MajorUpgrade.Phase {
+	case api.MajorUpgradeChecking, api.MajorUpgradeBlocked, api.MajorUpgradeBackingUp:
+		return true
+	}
+
+	return false
+}
+
+// reconcileMajorUpgrade guides the upgrade of the PXC major version. The new image, set by
+// the user or by the version service, isn't applied until the pre-upgrade checks pass
+// and the backup is made. The nodes are verified after all of them are updated.
+func (r *ReconcilePerconaXtraDBCluster) reconcileMajorUpgrade(cr *api.PerconaXtraDBCluster) error {
+	if cr.CompareVersionWith("1.9.0") < 0 {
+		cr.Status.MajorUpgrade = nil
+		return nil
+	}
+	if cr.Spec.Pause {
+		return nil
+	}
+
+	sfs := &appsv1.StatefulSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: statefulset.NewNode(cr).StatefulSet().Name, Namespace: cr.Namespace}, sfs)
+	if k8serrors.IsNotFound(err) {
+		cr.Status.MajorUpgrade = nil
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get pxc statefulset")
+	}
+
+	st := cr.Status.MajorUpgrade
+	if st == nil || st.Image != cr.Spec.PXC.Image {
+		from := imageMajorVersion(containerImage(sfs.Spec.Template.Spec.Containers, "pxc"))
+		to := imageMajorVersion(cr.Spec.PXC.Image)
+		cr.Status.RemoveCondition(api.ConditionMajorUpgradeBlocked)
+		if from == to {
+			cr.Status.MajorUpgrade = nil
+			return nil
+		}
+
+		r.logger(cr.Name, cr.Namespace).Info("major version upgrade is requested", "from", from, "to", to)
+		st = &api.MajorUpgradeStatus{
+			From:  from,
+			To:    to,
+			Image: cr.Spec.PXC.Image,
+			Phase: api.MajorUpgradeChecking,
+		}
+		cr.Status.MajorUpgrade = st
+	}
+
+	switch st.Phase {
+	case api.MajorUpgradeChecking, api.MajorUpgradeBlocked:
+		return r.checkMajorUpgrade(cr, st)
+	case api.MajorUpgradeBackingUp:
+		return r.backupBeforeMajorUpgrade(cr, st)
+	case api.MajorUpgradeUpgrading, api.MajorUpgradeFailed:
+		return r.watchMajorUpgrade(cr, st, sfs)
+	case api.MajorUpgradeVerifying:
+		return r.verifyMajorUpgrade(cr, st)
+	}
+
+	return nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) checkMajorUpgrade(cr *api.PerconaXtraDBCluster, st *api.MajorUpgradeStatus) error {
+	if st.From != "5.7" || st.To != "8.0" {
+		blockMajorUpgrade(cr, st, "UnsupportedUpgrade", fmt.Sprintf("upgrade from %s to %s isn't supported", st.From, st.To))
+		return nil
+	}
+
+	if st.Phase == api.MajorUpgradeBlocked && st.LastCheck != nil && time.Since(st.LastCheck.Time) < majorUpgradeRecheckPeriod {
+		return nil
+	}
+
+	opts := cr.Spec.UpgradeOptions.MajorUpgrade
+	if opts == nil {
+		opts = &api.MajorUpgradeOptions{}
+	}
+
+	if !opts.SkipBackup {
+		if msg := majorUpgradeStorageProblem(cr, opts.BackupStorageName); msg != "" {
+			blockMajorUpgrade(cr, st, "BackupStorage", msg)
+			return nil
+		}
+	}
+
+	findings, warnings, err := r.majorUpgradeFindings(cr)
+	now := metav1.Now()
+	st.LastCheck = &now
+	if err != nil {
+		// the checks are repeated until the cluster is reachable
+		st.Message = "pre-upgrade checks failed: " + err.Error()
+		r.logger(cr.Name, cr.Namespace).Info("pre-upgrade checks failed", "error", err.Error())
+		return nil
+	}
+
+	st.Findings = limitFindings(findings)
+	st.Warnings = limitFindings(warnings)
+	if len(findings) > 0 && !opts.IgnoreChecks {
+		blockMajorUpgrade(cr, st, "PreUpgradeChecks", fmt.Sprintf("pre-upgrade checks found %d problems", len(findings)))
+		return nil
+	}
+
+	cr.Status.RemoveCondition(api.ConditionMajorUpgradeBlocked)
+	st.Message = ""
+	st.Phase = api.MajorUpgradeBackingUp
+	if opts.SkipBackup {
+		st.Phase = api.MajorUpgradeUpgrading
+	}
+	r.logger(cr.Name, cr.Namespace).Info("pre-upgrade checks passed", "findings", len(findings), "warnings", len(warnings), "next phase", st.Phase)
+
+	return nil
+}
+
+// limitFindings keeps the first maxMajorUpgradeFindings findings for the status
+func limitFindings(findings []string) []string {
+	if len(findings) <= maxMajorUpgradeFindings {
+		return findings
+	}
+	return append(findings[:maxMajorUpgradeFindings], fmt.Sprintf("and %d more", len(findings)-maxMajorUpgradeFindings))
+}
+
+func blockMajorUpgrade(cr *api.PerconaXtraDBCluster, st *api.MajorUpgradeStatus, reason, msg string) {
+	st.Phase = api.MajorUpgradeBlocked
+	st.Message = msg
+	cr.Status.SetCondition(api.ClusterCondition{
+		Type:               api.ConditionMajorUpgradeBlocked,
+		Status:             api.ConditionTrue,
+		Reason:             reason,
+		Message:            msg,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	})
+}
+
+// majorUpgradeStorageProblem returns the reason the pre-upgrade backup can't be made or empty string
+func majorUpgradeStorageProblem(cr *api.PerconaXtraDBCluster, storageName string) string {
+	if storageName == "" {
+		return "upgradeOptions.majorUpgrade.backupStorageName is required for the pre-upgrade backup, set skipBackup to upgrade without it"
+	}
+	if cr.Spec.Backup == nil || cr.Spec.Backup.Storages[storageName] == nil {
+		return "backup storage " + storageName + " isn't defined"
+	}
+
+	return ""
+}
+
+// majorUpgradeFindings runs the pre-upgrade checks on one of the nodes,
+// the schema is the same on all of them
+func (r *ReconcilePerconaXtraDBCluster) majorUpgradeFindings(cr *api.PerconaXtraDBCluster) ([]string, []string, error) {
+	host := statefulset.NewNode(cr).Service() + "." + cr.Namespace
+	database, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", host, 33062)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "connect to pxc")
+	}
+	defer database.Close()
+
+	return database.UpgradeCheck()
+}
+
+func majorUpgradeBackupName(cr *api.PerconaXtraDBCluster, image string) string {
+	return fmt.Sprintf("pre-upgrade-%s-%08x", trimNameRight(cr.Name, 16), crc32.ChecksumIEEE([]byte(image)))
+}
+
+func (r *ReconcilePerconaXtraDBCluster) backupBeforeMajorUpgrade(cr *api.PerconaXtraDBCluster, st *api.MajorUpgradeStatus) error {
+	st.Backup = majorUpgradeBackupName(cr, st.Image)
+
+	bcp := &api.PerconaXtraDBClusterBackup{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: st.Backup, Namespace: cr.Namespace}, bcp)
+	if k8serrors.IsNotFound(err) {
+		storageName := ""
+		if cr.Spec.UpgradeOptions.MajorUpgrade != nil {
+			storageName = cr.Spec.UpgradeOptions.MajorUpgrade.BackupStorageName
+		}
+		if msg := majorUpgradeStorageProblem(cr, storageName); msg != "" {
+			blockMajorUpgrade(cr, st, "BackupStorage", msg)
+			return nil
+		}
+
+		bcp = &api.PerconaXtraDBClusterBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      st.Backup,
+				Namespace: cr.Namespace,
+				Labels: map[string]string{
+					"cluster": cr.Name,
+					"type":    "pre-upgrade",
+				},
+			},
+			Spec: api.PXCBackupSpec{
+				PXCCluster:  cr.Name,
+				StorageName: storageName,
+			},
+		}
+		err = r.client.Create(context.TODO(), bcp)
+		if err != nil {
+			return errors.Wrapf(err, "create backup %s", st.Backup)
+		}
+		r.logger(cr.Name, cr.Namespace).Info("pre-upgrade backup is started", "backup", st.Backup)
+		st.Message = "waiting for the pre-upgrade backup"
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "get backup %s", st.Backup)
+	}
+
+	switch bcp.Status.State {
+	case api.BackupSucceeded:
+		r.logger(cr.Name, cr.Namespace).Info("pre-upgrade backup is done", "backup", st.Backup)
+		st.Phase = api.MajorUpgradeUpgrading
+		st.Message = ""
+	case api.BackupFailed:
+		blockMajorUpgrade(cr, st, "BackupFailed", "pre-upgrade backup "+st.Backup+" failed, delete it to make a new one")
+	default:
+		st.Message = "waiting for the pre-upgrade backup"
+	}
+
+	return nil
+}
+
+// watchMajorUpgrade waits until all the PXC pods run the new image
+func (r *ReconcilePerconaXtraDBCluster) watchMajorUpgrade(cr *api.PerconaXtraDBCluster, st *api.MajorUpgradeStatus, sfs *appsv1.StatefulSet) error {
+	if rb := cr.Status.UpgradeRollback; rb != nil {
+		st.Phase = api.MajorUpgradeFailed
+		st.Message = "update was rolled back: " + rb.Reason
+		return nil
+	}
+	// the rollback is cleared when the spec is changed, the update is applied again
+	st.Phase = api.MajorUpgradeUpgrading
+
+	if containerImage(sfs.Spec.Template.Spec.Containers, "pxc") != st.Image {
+		st.Message = "waiting for the statefulset to be updated"
+		return nil
+	}
+
+	replicas := cr.Spec.PXC.Size
+	if sfs.Status.ObservedGeneration < sfs.Generation || sfs.Status.UpdatedReplicas < replicas || sfs.Status.ReadyReplicas < replicas {
+		st.Message = fmt.Sprintf("%d of %d pods are updated", sfs.Status.UpdatedReplicas, replicas)
+		return nil
+	}
+
+	st.Phase = api.MajorUpgradeVerifying
+	st.Message = ""
+
+	return r.verifyMajorUpgrade(cr, st)
+}
+
+// verifyMajorUpgrade checks that every node runs the new version and is synced with the cluster
+func (r *ReconcilePerconaXtraDBCluster) verifyMajorUpgrade(cr *api.PerconaXtraDBCluster, st *api.MajorUpgradeStatus) error {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labels.SelectorFromSet(statefulset.NewNode(cr).Labels()),
+	})
+	if err != nil {
+		return errors.Wrap(err, "get pod list")
+	}
+
+	problems := []string{}
+	if int32(len(pods.Items)) != cr.Spec.PXC.Size {
+		problems = append(problems, fmt.Sprintf("%d of %d pods exist", len(pods.Items), cr.Spec.PXC.Size))
+	}
+	for _, pod := range pods.Items {
+		version, state, err := r.pxcNodeState(cr, pod.Name+"."+cr.Name+"-pxc."+cr.Namespace)
+		if err != nil {
+			problems = append(problems, pod.Name+": "+err.Error())
+			continue
+		}
+		if !strings.HasPrefix(version, st.To+".") {
+			problems = append(problems, pod.Name+" runs "+version)
+		}
+		if state != "Synced" {
+			problems = append(problems, pod.Name+" is "+state)
+		}
+	}
+
+	if len(problems) > 0 {
+		st.Message = "post-upgrade verification: " + strings.Join(problems, "; ")
+		return nil
+	}
+
+	r.logger(cr.Name, cr.Namespace).Info("major version upgrade is completed", "version", st.To)
+	st.Phase = api.MajorUpgradeCompleted
+	st.Message = ""
+
+	return nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) pxcNodeState(cr *api.PerconaXtraDBCluster, host string) (version, state string, err error) {
+	database, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", host, 33062)
+	if err != nil {
+		return "", "", errors.Wrap(err, "connect")
+	}
+	defer database.Close()
+
+	version, err = database.Version()
+	if err != nil {
+		return "", "", errors.Wrap(err, "get version")
+	}
+	state, err = database.WsrepLocalStateComment()
+	if err != nil {
+		return "", "", errors.Wrap(err, "get wsrep local state")
+	}
+
+	return version, state, nil
+}
@@ -0,0 +1,117 @@
+package pxc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+func TestReconcileMajorUpgrade(t *testing.T) {
+	const (
+		image57 = "percona/percona-xtradb-cluster:5.7.33-31.49"
+		image80 = "percona/percona-xtradb-cluster:8.0.22-13.1"
+	)
+
+	scheme.Scheme.AddKnownTypes(api.SchemeGroupVersion, &api.PerconaXtraDBClusterBackup{})
+
+	newBackup := func(cr *api.PerconaXtraDBCluster, state api.PXCBackupState) runtime.Object {
+		bcp := &api.PerconaXtraDBClusterBackup{}
+		bcp.Name = majorUpgradeBackupName(cr, image80)
+		bcp.Namespace = cr.Namespace
+		bcp.Status.State = state
+		return bcp
+	}
+
+	tests := map[string]struct {
+		sfsImage  string
+		specImage string
+		status    *api.MajorUpgradeStatus
+		backup    api.PXCBackupState
+		phase     api.MajorUpgradePhase
+		blocked   bool
+	}{
+		"same major":        {image80, "percona/percona-xtradb-cluster:8.0.23-14.1", nil, "", "", false},
+		"downgrade":         {image80, image57, nil, "", api.MajorUpgradeBlocked, true},
+		"no backup storage": {image57, image80, nil, "", api.MajorUpgradeBlocked, true},
+		"backup started": {image57, image80,
+			&api.MajorUpgradeStatus{From: "5.7", To: "8.0", Image: image80, Phase: api.MajorUpgradeBackingUp}, "", api.MajorUpgradeBackingUp, false},
+		"backup failed": {image57, image80,
+			&api.MajorUpgradeStatus{From: "5.7", To: "8.0", Image: image80, Phase: api.MajorUpgradeBackingUp}, api.BackupFailed, api.MajorUpgradeBlocked, true},
+		"backup done": {image57, image80,
+			&api.MajorUpgradeStatus{From: "5.7", To: "8.0", Image: image80, Phase: api.MajorUpgradeBackingUp}, api.BackupSucceeded, api.MajorUpgradeUpgrading, false},
+		"pods updating": {image80, image80,
+			&api.MajorUpgradeStatus{From: "5.7", To: "8.0", Image: image80, Phase: api.MajorUpgradeUpgrading}, "", api.MajorUpgradeUpgrading, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Spec.CRVersion = "1.9.0"
+			cr.Spec.PXC.Image = tt.specImage
+			cr.Spec.UpgradeOptions.MajorUpgrade = &api.MajorUpgradeOptions{BackupStorageName: "s3"}
+			if tt.status != nil {
+				cr.Spec.Backup = &api.PXCScheduledBackup{Storages: map[string]*api.BackupStorageSpec{"s3": {}}}
+			}
+			cr.Status.MajorUpgrade = tt.status
+
+			sfs := statefulset.NewNode(cr).StatefulSet().DeepCopy()
+			sfs.Spec.Template.Spec.Containers = []corev1.Container{{Name: "pxc", Image: tt.sfsImage}}
+			objs := []runtime.Object{sfs}
+			if tt.backup != "" {
+				objs = append(objs, newBackup(cr, tt.backup))
+			}
+
+			r := buildFakeClient(objs)
+			r.log = zapr.NewLogger(zap.NewNop())
+
+			err := r.reconcileMajorUpgrade(cr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			st := cr.Status.MajorUpgrade
+			if tt.phase == "" {
+				if st != nil {
+					t.Fatalf("expected no major upgrade, got %+v", st)
+				}
+				return
+			}
+			if st == nil || st.Phase != tt.phase {
+				t.Fatalf("expected %s phase, got %+v", tt.phase, st)
+			}
+
+			blocked := false
+			for _, c := range cr.Status.Conditions {
+				if c.Type == api.ConditionMajorUpgradeBlocked {
+					blocked = true
+				}
+			}
+			if blocked != tt.blocked {
+				t.Errorf("blocked condition = %v, want %v: %s", blocked, tt.blocked, st.Message)
+			}
+			if held := majorUpgradeHeld(cr); held != (tt.phase != api.MajorUpgradeUpgrading) {
+				t.Errorf("held = %v in %s phase", held, tt.phase)
+			}
+
+			if name == "backup started" {
+				bcp := &api.PerconaXtraDBClusterBackup{}
+				err = r.client.Get(context.TODO(), types.NamespacedName{Name: st.Backup, Namespace: cr.Namespace}, bcp)
+				if err != nil {
+					t.Fatalf("pre-upgrade backup: %v", err)
+				}
+				if bcp.Spec.StorageName != "s3" || bcp.Spec.PXCCluster != cr.Name {
+					t.Errorf("unexpected backup spec %+v", bcp.Spec)
+				}
+			}
+		})
+	}
+}
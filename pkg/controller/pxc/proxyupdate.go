@@ -0,0 +1,370 @@
+package pxc
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+)
+
+const (
+	// proxyDrainTimeout is how long the clients can stay on the draining proxy before it's restarted anyway
+	proxyDrainTimeout = 2 * time.Minute
+	// proxyRestartTimeout is how long the restarted proxy can stay not ready before the update is stopped
+	proxyRestartTimeout = 10 * time.Minute
+	// proxyLeaveServiceDelay is how long kube-proxy has to remove the proxy from the service endpoints
+	proxyLeaveServiceDelay = 15 * time.Second
+
+	haproxySocket = "/etc/haproxy/pxc/haproxy.sock"
+)
+
+// proxySmartUpdate restarts the HAProxy or ProxySQL pods one by one. The proxy is taken out of the
+// services first, then it stops sending the new queries to the PXC nodes and is deleted when the current
+// clients are gone or the drain timeout is reached. The next proxy is taken out only after the restarted
+// one is ready and sees the online PXC nodes.
+func (r *ReconcilePerconaXtraDBCluster) proxySmartUpdate(sfs api.StatefulApp, cr *api.PerconaXtraDBCluster) error {
+	logger := r.logger(cr.Name, cr.Namespace)
+	set := sfs.StatefulSet()
+	opts := cr.Spec.UpgradeOptions
+
+	// the pod taken out of the services is restarted even if the revision has changed since then
+	if st := cr.Status.ProxySmartUpdate; st != nil && len(st.Pods) > 0 {
+		pod := &st.Pods[0]
+		done, err := r.proxyUpdateStep(cr, sfs, pod)
+		if err != nil {
+			return errors.Wrapf(err, "update proxy pod %s", pod.Name)
+		}
+		if !done {
+			return nil
+		}
+		logger.Info("proxy pod is updated", "pod name", pod.Name)
+		st.Pods = nil
+		if opts.PauseBetweenPods != nil && opts.PauseBetweenPods.Duration > 0 {
+			until := metav1.NewTime(time.Now().Add(opts.PauseBetweenPods.Duration))
+			st.PausedUntil = &until
+		}
+	}
+
+	if set.Status.UpdatedReplicas >= set.Status.Replicas {
+		if cr.Status.ProxySmartUpdate != nil {
+			logger.Info("proxy smart update finished", "statefulset", set.Name)
+			cr.Status.ProxySmartUpdate = nil
+		}
+		return nil
+	}
+
+	if cr.Status.ProxySmartUpdate == nil || cr.Status.ProxySmartUpdate.Revision != set.Status.UpdateRevision {
+		logger.Info("proxy statefulSet was changed, run smart update", "statefulset", set.Name)
+		cr.Status.ProxySmartUpdate = &api.SmartUpdateStatus{Revision: set.Status.UpdateRevision}
+	}
+	st := cr.Status.ProxySmartUpdate
+
+	if st.PausedUntil != nil {
+		if time.Now().Before(st.PausedUntil.Time) {
+			return nil
+		}
+		st.PausedUntil = nil
+	}
+
+	// the proxies are restarted after the PXC pods, so they don't lose the backends in the middle of the drain
+	if pxcPodsUpdating(cr) {
+		return nil
+	}
+
+	if cr.RestartsDeferred() {
+		logger.Info("can't start/continue proxy 'SmartUpdate': waiting for the maintenance window")
+		return nil
+	}
+
+	if switchoverInProgress(cr) {
+		logger.Info("can't start/continue proxy 'SmartUpdate': primary switchover is running")
+		return nil
+	}
+
+	if set.Status.ReadyReplicas < set.Status.Replicas {
+		logger.Info("can't start/continue proxy 'SmartUpdate': waiting for all replicas are ready", "statefulset", set.Name)
+		return nil
+	}
+
+	list := corev1.PodList{}
+	if err := r.client.List(context.TODO(),
+		&list,
+		&client.ListOptions{
+			Namespace:     set.Namespace,
+			LabelSelector: labels.SelectorFromSet(sfs.Labels()),
+		},
+	); err != nil {
+		return errors.Wrap(err, "get pod list")
+	}
+
+	pods := podsToUpdate(list.Items, "", set.Status.UpdateRevision, 0, 1)
+	if len(pods) == 0 {
+		return nil
+	}
+	pod := pods[0]
+
+	logger.Info("take proxy pod out of the services", "pod name", pod.Name)
+	delete(pod.Labels, api.ProxyServingLabel)
+	if err := r.client.Update(context.TODO(), &pod); err != nil {
+		return errors.Wrapf(err, "remove %s label from pod %s", api.ProxyServingLabel, pod.Name)
+	}
+	st.Pods = []api.SmartUpdatePod{{Name: pod.Name, Phase: api.SmartUpdateLeavingService, PhaseStartedAt: metav1.Now()}}
+
+	return nil
+}
+
+// pxcPodsUpdating is true while SmartUpdate restarts the PXC pods. The update waiting
+// for the approval or held by the partition doesn't restart them.
+func pxcPodsUpdating(cr *api.PerconaXtraDBCluster) bool {
+	return cr.Status.SmartUpdate != nil && len(cr.Status.SmartUpdate.Pods) > 0
+}
+
+// labelServingProxies puts the api.ProxyServingLabel back on the proxy pods
+// that aren't being updated, e.g. the pods created before the label was added.
+// It's done before the services select the pods by the label.
+func (r *ReconcilePerconaXtraDBCluster) labelServingProxies(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp) error {
+	if cr.CompareVersionWith("1.9.0") < 0 {
+		return nil
+	}
+
+	list := corev1.PodList{}
+	err := r.client.List(context.TODO(),
+		&list,
+		&client.ListOptions{
+			Namespace:     cr.Namespace,
+			LabelSelector: labels.SelectorFromSet(sfs.Labels()),
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "get pod list")
+	}
+
+	updating := make(map[string]bool)
+	if st := cr.Status.ProxySmartUpdate; st != nil {
+		for _, pod := range st.Pods {
+			updating[pod.Name] = true
+		}
+	}
+
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if updating[pod.Name] || pod.DeletionTimestamp != nil || pod.Labels[api.ProxyServingLabel] == "true" {
+			continue
+		}
+		pod.Labels[api.ProxyServingLabel] = "true"
+		err = r.client.Update(context.TODO(), pod)
+		if err != nil {
+			return errors.Wrapf(err, "add %s label to pod %s", api.ProxyServingLabel, pod.Name)
+		}
+	}
+
+	return nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) proxyUpdateStep(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp, pod *api.SmartUpdatePod) (bool, error) {
+	logger := r.logger(cr.Name, cr.Namespace)
+	set := sfs.StatefulSet()
+
+	for {
+		next := pod.Phase
+		switch pod.Phase {
+		case api.SmartUpdateLeavingService:
+			if time.Since(pod.PhaseStartedAt.Time) < proxyLeaveServiceDelay {
+				return false, nil
+			}
+			logger.Info("drain proxy pod", "pod name", pod.Name)
+			if err := r.drainProxy(cr, sfs, pod.Name); err != nil {
+				logger.Error(err, "failed to drain proxy, it will be restarted after the drain timeout", "pod name", pod.Name)
+			}
+			next = api.SmartUpdateDraining
+		case api.SmartUpdateDraining:
+			conns, err := r.proxyClientConnections(cr, sfs, pod.Name)
+			if err != nil {
+				logger.Info("can't get proxy client connections", "pod name", pod.Name, "error", err.Error())
+			}
+			if err == nil && conns > 0 && time.Since(pod.PhaseStartedAt.Time) < proxyDrainTimeout {
+				return false, nil
+			}
+			logger.Info("restart proxy pod", "pod name", pod.Name, "client connections", conns)
+			err = r.client.Delete(context.TODO(), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: cr.Namespace},
+			})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return false, errors.Wrap(err, "delete pod")
+			}
+			next = api.SmartUpdateRestarting
+		case api.SmartUpdateRestarting:
+			ready, err := r.isProxyPodReady(set.Status.UpdateRevision, cr.Namespace, pod.Name)
+			if err != nil {
+				return false, err
+			}
+			if !ready {
+				if time.Since(pod.PhaseStartedAt.Time) > proxyRestartTimeout {
+					return false, errors.Errorf("pod isn't ready for %s", proxyRestartTimeout)
+				}
+				return false, nil
+			}
+			next = api.SmartUpdateHealthCheck
+		case api.SmartUpdateHealthCheck:
+			return r.proxyBackendsHealthy(cr, sfs, pod.Name)
+		default:
+			return true, nil
+		}
+
+		pod.Phase = next
+		pod.PhaseStartedAt = metav1.Now()
+	}
+}
+
+func (r *ReconcilePerconaXtraDBCluster) isProxyPodReady(updateRevision, namespace, podName string) (bool, error) {
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: namespace}, pod)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if pod.Labels["controller-revision-hash"] != updateRevision || pod.Status.Phase != corev1.PodRunning {
+		return false, nil
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue, nil
+		}
+	}
+
+	return false, nil
+}
+
+// drainProxy stops sending the new queries to the PXC nodes through the proxy
+// that is out of the services already.
+// ProxySQL is paused instead of setting the servers OFFLINE_SOFT, because the
+// ProxySQL cluster would sync the servers state to the other proxies too.
+func (r *ReconcilePerconaXtraDBCluster) drainProxy(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp, podName string) error {
+	if isProxySQL(sfs) {
+		db, err := r.proxySQLPodDB(cr, sfs, podName)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return errors.Wrap(db.PauseProxySQL(), "pause proxysql")
+	}
+
+	_, err := r.haproxyExec(cr, podName, "echo 'show servers state' | socat stdio unix-connect:"+haproxySocket+
+		" | awk 'NR > 2 && NF > 3 {print $2 \"/\" $4}'"+
+		" | while read srv; do echo \"set server $srv state drain\" | socat stdio unix-connect:"+haproxySocket+"; done")
+	return errors.Wrap(err, "drain haproxy servers")
+}
+
+func (r *ReconcilePerconaXtraDBCluster) proxyClientConnections(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp, podName string) (int, error) {
+	if isProxySQL(sfs) {
+		db, err := r.proxySQLPodDB(cr, sfs, podName)
+		if err != nil {
+			return 0, err
+		}
+		defer db.Close()
+		return db.ProxySQLClientConnections()
+	}
+
+	out, err := r.haproxyExec(cr, podName, "echo 'show info' | socat stdio unix-connect:"+haproxySocket)
+	if err != nil {
+		return 0, err
+	}
+	return haproxyCurrConns(out)
+}
+
+// proxyBackendsHealthy checks that the restarted proxy sees the online PXC nodes.
+// HAProxy that can't be asked relies on its readiness probe, which checks the backends too.
+func (r *ReconcilePerconaXtraDBCluster) proxyBackendsHealthy(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp, podName string) (bool, error) {
+	if isProxySQL(sfs) {
+		db, err := r.proxySQLPodDB(cr, sfs, podName)
+		if err != nil {
+			return false, nil
+		}
+		defer db.Close()
+		online, err := db.ProxySQLOnlineServers()
+		if err != nil {
+			return false, errors.Wrap(err, "get online servers")
+		}
+		return online > 0, nil
+	}
+
+	out, err := r.haproxyExec(cr, podName, "echo 'show stat' | socat stdio unix-connect:"+haproxySocket)
+	if err != nil {
+		r.logger(cr.Name, cr.Namespace).Info("can't check haproxy backends, rely on the readiness probe", "pod name", podName, "error", err.Error())
+		return true, nil
+	}
+	return haproxyUpServers(out, "galera-nodes") > 0, nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) proxySQLPodDB(cr *api.PerconaXtraDBCluster, sfs api.StatefulApp, podName string) (queries.Database, error) {
+	secrets := cr.Spec.SecretsName
+	if cr.CompareVersionWith("1.6.0") >= 0 {
+		secrets = "internal-" + cr.Name
+	}
+
+	return queries.New(r.client, cr.Namespace, secrets, "proxyadmin", podName+"."+sfs.Service()+"."+cr.Namespace, 6032)
+}
+
+func (r *ReconcilePerconaXtraDBCluster) haproxyExec(cr *api.PerconaXtraDBCluster, podName, script string) (string, error) {
+	if r.clientcmd == nil {
+		return "", errors.New("exec isn't available")
+	}
+
+	pod := corev1.Pod{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: cr.Namespace}, &pod)
+	if err != nil {
+		return "", errors.Wrap(err, "get pod")
+	}
+
+	var outb, errb bytes.Buffer
+	err = r.clientcmd.Exec(&pod, "haproxy", []string{"/bin/sh", "-c", script}, nil, &outb, &errb, false)
+	if err != nil {
+		return "", errors.Errorf("exec: %v / %s / %s", err, outb.String(), errb.String())
+	}
+
+	return outb.String(), nil
+}
+
+// haproxyCurrConns parses the current connections from the 'show info' output
+func haproxyCurrConns(info string) (int, error) {
+	for _, line := range strings.Split(info, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "CurrConns" {
+			return strconv.Atoi(strings.TrimSpace(kv[1]))
+		}
+	}
+
+	return 0, errors.New("no CurrConns in haproxy info")
+}
+
+// haproxyUpServers counts the UP servers of the backend in the 'show stat' CSV output
+func haproxyUpServers(stat, backend string) int {
+	up := 0
+	for _, line := range strings.Split(stat, "\n") {
+		f := strings.Split(line, ",")
+		// pxname,svname,...,status is the 18th field
+		if len(f) < 18 || f[0] != backend || f[1] == "BACKEND" || f[1] == "FRONTEND" {
+			continue
+		}
+		if strings.HasPrefix(f[17], "UP") {
+			up++
+		}
+	}
+
+	return up
+}
@@ -0,0 +1,81 @@
+package pxc
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+func TestHAProxyStats(t *testing.T) {
+	info := "Name: HAProxy\nVersion: 2.3.10\nCurrConns: 7\nCumConns: 120\n"
+	conns, err := haproxyCurrConns(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conns != 7 {
+		t.Errorf("CurrConns = %d, want 7", conns)
+	}
+	if _, err := haproxyCurrConns("Name: HAProxy\n"); err == nil {
+		t.Error("expected error without CurrConns")
+	}
+
+	status := func(px, sv, st string) string {
+		return px + "," + sv + ",0,0,0,0,,0,0,0,,0,,0,0,0,0," + st + ",1,0,0"
+	}
+	tests := map[string]struct {
+		stat string
+		up   int
+	}{
+		"all up": {status("galera-nodes", "cluster1-pxc-0", "UP") + "\n" + status("galera-nodes", "cluster1-pxc-1", "UP 1/3"), 2},
+		"down":   {status("galera-nodes", "cluster1-pxc-0", "DOWN") + "\n" + status("galera-nodes", "cluster1-pxc-1", "MAINT"), 0},
+		"backend and other proxies": {
+			status("galera-nodes", "BACKEND", "UP") + "\n" + status("galera-in", "FRONTEND", "OPEN") + "\n" +
+				status("galera-replica-nodes", "cluster1-pxc-2", "UP") + "\n" + status("galera-nodes", "cluster1-pxc-2", "UP"), 1,
+		},
+		"empty": {"", 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if up := haproxyUpServers(tt.stat, "galera-nodes"); up != tt.up {
+				t.Errorf("up servers = %d, want %d", up, tt.up)
+			}
+		})
+	}
+}
+
+func TestLabelServingProxies(t *testing.T) {
+	cr := newCR("cluster1", "ns")
+	cr.Spec.CRVersion = "1.9.0"
+	cr.Status.ProxySmartUpdate = &api.SmartUpdateStatus{Pods: []api.SmartUpdatePod{{Name: "cluster1-haproxy-1", Phase: api.SmartUpdateDraining}}}
+
+	sfs := statefulset.NewHAProxy(cr)
+	pod := func(name string) *corev1.Pod {
+		labels := make(map[string]string)
+		for k, v := range sfs.Labels() {
+			labels[k] = v
+		}
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels}}
+	}
+
+	r := buildFakeClient([]runtime.Object{pod("cluster1-haproxy-0"), pod("cluster1-haproxy-1")})
+	if err := r.labelServingProxies(cr, sfs); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"cluster1-haproxy-0": "true", "cluster1-haproxy-1": ""} {
+		p := &corev1.Pod{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "ns"}, p); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Labels[api.ProxyServingLabel]; got != want {
+			t.Errorf("%s: %s label = %q, want %q", name, api.ProxyServingLabel, got, want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+)
+
+// quorumLossGracePeriod is how long the nodes have to stay out of the primary component
+// before the quorum is restored automatically, the network partitions may heal on their own
+const quorumLossGracePeriod = 2 * time.Minute
+
+const (
+	quorumNodeDown    = "Down"
+	quorumNodeUnknown = "Unknown"
+)
+
+type quorumNode struct {
+	pod string
+	// down is set if kubelet reports that mysqld of the pod isn't running
+	down bool
+	// status is wsrep_cluster_status of the node, it's empty if the node can't be checked
+	status        string
+	uuid          string
+	lastCommitted int64
+}
+
+func (n quorumNode) state() string {
+	switch {
+	case n.status != "":
+		return n.status
+	case n.down:
+		return quorumNodeDown
+	}
+	return quorumNodeUnknown
+}
+
+// reconcileQuorum restores the primary component when the majority of the PXC nodes failed and
+// the nodes that are left are out of it. The most advanced of them is bootstrapped with pc.bootstrap,
+// the others join it. It's done automatically only if kubelet reports the failed pods are down:
+// the pods that can't be checked may run the primary component in the other network partition.
+// The ForceQuorumAnnotation makes the operator restore the quorum in that case too.
+func (r *ReconcilePerconaXtraDBCluster) reconcileQuorum(cr *api.PerconaXtraDBCluster) error {
+	if cr.CompareVersionWith("1.9.0") < 0 || cr.Spec.Pause || cr.Spec.PXC.Size <= 0 {
+		return nil
+	}
+
+	sfs := &appsv1.StatefulSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: statefulset.NewNode(cr).StatefulSet().Name, Namespace: cr.Namespace}, sfs)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get pxc statefulset")
+	}
+
+	st := cr.Status.QuorumRecovery
+	// the nodes out of the primary component fail the readiness probe
+	if sfs.Status.ReadyReplicas >= cr.Spec.PXC.Size {
+		r.quorumRestored(cr)
+		return nil
+	}
+
+	nodes, err := r.quorumNodes(cr)
+	if err != nil {
+		return errors.Wrap(err, "get nodes state")
+	}
+
+	forced := ""
+	if st != nil {
+		forced = st.Forced
+	}
+	forceValue := cr.Annotations[api.ForceQuorumAnnotation]
+	force := forceValue != "" && forceValue != forced
+
+	lost, pod, reason := decideQuorum(nodes, force)
+	if !lost {
+		r.quorumRestored(cr)
+		return nil
+	}
+
+	logger := r.logger(cr.Name, cr.Namespace)
+	if st == nil || st.BootstrappedAt != nil {
+		st = &api.QuorumRecoveryStatus{DetectedAt: metav1.Now(), Forced: forced}
+		cr.Status.QuorumRecovery = st
+		logger.Info("PXC nodes lost the quorum", "nodes", describeQuorumNodes(nodes))
+		r.recorder.Eventf(cr, corev1.EventTypeWarning, "QuorumLost", "PXC nodes lost the quorum: %s", describeQuorumNodes(nodes))
+	}
+
+	st.Nodes = make([]api.QuorumNodeStatus, 0, len(nodes))
+	for _, n := range nodes {
+		st.Nodes = append(st.Nodes, api.QuorumNodeStatus{Pod: n.pod, State: n.state(), LastCommitted: n.lastCommitted})
+	}
+
+	switch {
+	case pod == "":
+		st.Message = reason
+	case force:
+		st.Message = ""
+	case cr.Spec.PXC.AutoRecovery != nil && !*cr.Spec.PXC.AutoRecovery:
+		st.Message = fmt.Sprintf("autoRecovery is disabled, set the %s annotation to restore the quorum on %s", api.ForceQuorumAnnotation, pod)
+	case time.Since(st.DetectedAt.Time) < quorumLossGracePeriod:
+		st.Message = fmt.Sprintf("waiting %s for the nodes to recover", quorumLossGracePeriod)
+	default:
+		st.Message = ""
+	}
+	if st.Message != "" {
+		logger.Info("quorum isn't restored: "+st.Message, "nodes", describeQuorumNodes(nodes))
+		return nil
+	}
+
+	host := pod + "." + sfs.Name + "." + cr.Namespace
+	database, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", host, 33062)
+	if err != nil {
+		return errors.Wrapf(err, "connect to %s", pod)
+	}
+	defer database.Close()
+
+	err = database.BootstrapPrimaryComponent()
+	if err != nil {
+		return errors.Wrapf(err, "bootstrap primary component on %s", pod)
+	}
+
+	now := metav1.Now()
+	st.BootstrapPod = pod
+	st.BootstrappedAt = &now
+	if force {
+		st.Forced = forceValue
+	}
+
+	logger.Info("primary component is bootstrapped", "pod", pod, "forced", force, "nodes", describeQuorumNodes(nodes))
+	r.recorder.Eventf(cr, corev1.EventTypeNormal, "QuorumRestored", "Primary component is bootstrapped on %s. Nodes: %s", pod, describeQuorumNodes(nodes))
+
+	return nil
+}
+
+// quorumRestored drops the quorum loss that healed on its own,
+// the result of the bootstrap is kept in the status
+func (r *ReconcilePerconaXtraDBCluster) quorumRestored(cr *api.PerconaXtraDBCluster) {
+	st := cr.Status.QuorumRecovery
+	if st == nil || st.BootstrappedAt != nil {
+		return
+	}
+
+	r.logger(cr.Name, cr.Namespace).Info("PXC nodes are back in the primary component")
+	cr.Status.QuorumRecovery = nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) quorumNodes(cr *api.PerconaXtraDBCluster) ([]quorumNode, error) {
+	sfsName := statefulset.NewNode(cr).StatefulSet().Name
+
+	nodes := make([]quorumNode, 0, cr.Spec.PXC.Size)
+	for i := 0; i < int(cr.Spec.PXC.Size); i++ {
+		n := quorumNode{pod: fmt.Sprintf("%s-%d", sfsName, i)}
+
+		pod := &corev1.Pod{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: n.pod, Namespace: cr.Namespace}, pod)
+		if k8serrors.IsNotFound(err) {
+			n.down = true
+			nodes = append(nodes, n)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get pod %s", n.pod)
+		}
+
+		if !pxcContainerRunning(pod) {
+			n.down = true
+			nodes = append(nodes, n)
+			continue
+		}
+
+		database, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", n.pod+"."+sfsName+"."+cr.Namespace, 33062)
+		if err != nil {
+			nodes = append(nodes, n)
+			continue
+		}
+		status, err := database.WsrepStatus()
+		database.Close()
+		if err != nil {
+			nodes = append(nodes, n)
+			continue
+		}
+
+		n.status = status["wsrep_cluster_status"]
+		n.uuid = status["wsrep_cluster_state_uuid"]
+		n.lastCommitted, _ = strconv.ParseInt(status["wsrep_last_committed"], 10, 64)
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+// pxcContainerRunning is false if kubelet reports that the pxc container isn't running
+func pxcContainerRunning(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Name == "pxc" {
+			return c.State.Running != nil
+		}
+	}
+
+	return false
+}
+
+// decideQuorum returns if the quorum is lost and the node to bootstrap the primary component on.
+// If the node can't be chosen safely the reason is returned.
+func decideQuorum(nodes []quorumNode, force bool) (bool, string, string) {
+	survivors := []quorumNode{}
+	unknown := []string{}
+	down := 0
+	for _, n := range nodes {
+		switch {
+		case n.status == "Primary":
+			return false, "", ""
+		case n.status != "":
+			survivors = append(survivors, n)
+		case n.down:
+			down++
+		default:
+			unknown = append(unknown, n.pod)
+		}
+	}
+
+	if len(survivors) == 0 {
+		return false, "", ""
+	}
+
+	for _, s := range survivors[1:] {
+		if s.uuid != survivors[0].uuid {
+			return true, "", fmt.Sprintf("nodes have different cluster state UUIDs: %s has %s, %s has %s", survivors[0].pod, survivors[0].uuid, s.pod, s.uuid)
+		}
+	}
+
+	if !force {
+		if len(unknown) > 0 {
+			return true, "", fmt.Sprintf("state of %s is unknown, they may run the primary component; set the %s annotation to restore the quorum anyway",
+				strings.Join(unknown, ", "), api.ForceQuorumAnnotation)
+		}
+		if down == 0 {
+			return true, "", "all nodes are running, waiting for the network to recover"
+		}
+	}
+
+	best := survivors[0]
+	for _, s := range survivors[1:] {
+		if s.lastCommitted > best.lastCommitted {
+			best = s
+		}
+	}
+
+	return true, best.pod, ""
+}
+
+func describeQuorumNodes(nodes []quorumNode) string {
+	s := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		d := n.pod + " " + n.state()
+		if n.status != "" {
+			d += fmt.Sprintf(" last committed %d", n.lastCommitted)
+		}
+		s = append(s, d)
+	}
+
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,43 @@
+package pxc
+
+import "testing"
+
+func TestDecideQuorum(t *testing.T) {
+	const uuid = "7e2a3ed1-5e0c-11eb-8f1b-8b0b6a3bb4e3"
+	alive := func(pod, status string, lastCommitted int64) quorumNode {
+		return quorumNode{pod: pod, status: status, uuid: uuid, lastCommitted: lastCommitted}
+	}
+	down := func(pod string) quorumNode { return quorumNode{pod: pod, down: true} }
+	unknown := func(pod string) quorumNode { return quorumNode{pod: pod} }
+
+	tests := map[string]struct {
+		nodes []quorumNode
+		force bool
+		lost  bool
+		pod   string
+	}{
+		"healthy":              {[]quorumNode{alive("pxc-0", "Primary", 10), alive("pxc-1", "Primary", 10), alive("pxc-2", "Primary", 10)}, false, false, ""},
+		"minority failed":      {[]quorumNode{alive("pxc-0", "Primary", 10), down("pxc-1"), alive("pxc-2", "Primary", 10)}, false, false, ""},
+		"full crash":           {[]quorumNode{unknown("pxc-0"), unknown("pxc-1"), unknown("pxc-2")}, false, false, ""},
+		"majority down":        {[]quorumNode{alive("pxc-0", "non-Primary", 10), down("pxc-1"), down("pxc-2")}, false, true, "pxc-0"},
+		"most advanced":        {[]quorumNode{alive("pxc-0", "non-Primary", 10), alive("pxc-1", "non-Primary", 12), down("pxc-2"), down("pxc-3"), down("pxc-4")}, false, true, "pxc-1"},
+		"disconnected":         {[]quorumNode{down("pxc-0"), alive("pxc-1", "Disconnected", 7), down("pxc-2")}, false, true, "pxc-1"},
+		"unknown node":         {[]quorumNode{alive("pxc-0", "non-Primary", 10), unknown("pxc-1"), down("pxc-2")}, false, true, ""},
+		"unknown node forced":  {[]quorumNode{alive("pxc-0", "non-Primary", 10), unknown("pxc-1"), down("pxc-2")}, true, true, "pxc-0"},
+		"network partition":    {[]quorumNode{alive("pxc-0", "non-Primary", 10), alive("pxc-1", "non-Primary", 10), alive("pxc-2", "non-Primary", 10)}, false, true, ""},
+		"different cluster":    {[]quorumNode{alive("pxc-0", "non-Primary", 10), {pod: "pxc-1", status: "non-Primary", uuid: "other"}, down("pxc-2")}, true, true, ""},
+		"primary in partition": {[]quorumNode{alive("pxc-0", "non-Primary", 10), alive("pxc-1", "Primary", 12), alive("pxc-2", "Primary", 12)}, true, false, ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			lost, pod, reason := decideQuorum(tt.nodes, tt.force)
+			if lost != tt.lost || pod != tt.pod {
+				t.Errorf("got lost %v pod %q (%s), want lost %v pod %q", lost, pod, reason, tt.lost, tt.pod)
+			}
+			if lost && pod == "" && reason == "" {
+				t.Error("reason isn't set")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replicasChannel is the channel the read replicas use to replicate from the cluster
+const replicasChannel = "pxc_replicas"
+
+// reconcileReplicas starts the replication from the cluster nodes on the read replicas.
+// The replicas become ready only when the channel is running, so all the running
+// replica pods are checked.
+func (r *ReconcilePerconaXtraDBCluster) reconcileReplicas(cr *api.PerconaXtraDBCluster) error {
+	if !cr.ReplicasEnabled() || cr.Spec.Pause || cr.Status.PXC.Ready < 1 {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: "internal-" + cr.Name, Namespace: cr.Namespace}, secret)
+	if err != nil {
+		return errors.Wrap(err, "get internal secret")
+	}
+
+	pods := &corev1.PodList{}
+	err = r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labels.SelectorFromSet(statefulset.NewReplica(cr).Labels()),
+	})
+	if err != nil {
+		return errors.Wrap(err, "get replica pods")
+	}
+
+	channel := replicasChannelSpec(cr)
+	if len(channel.SourcesList) == 0 {
+		return nil
+	}
+	pass, ok := secret.Data[api.ReplicasUser]
+	if !ok {
+		// the user is created with the other system users
+		return errors.Errorf("no %s user in the internal secret yet", api.ReplicasUser)
+	}
+	log := r.logger(cr.Name, cr.Namespace)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		host := pod.Name + "." + cr.Name + "-" + statefulset.ReplicaName + "-unready." + cr.Namespace
+		db, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", host, 33062)
+		if err != nil {
+			// mysqld is starting or still applies the bootstrap data
+			log.Info("replica isn't available", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+
+		err = manageReplicationChannel(log, db, channel, api.ReplicasUser, string(pass))
+		db.Close()
+		if err != nil {
+			return errors.Wrapf(err, "manage replication on pod %s", pod.Name)
+		}
+	}
+
+	return nil
+}
+
+// replicasChannelSpec returns the channel that replicates from the PXC nodes,
+// the node with the lowest ordinal is preferred
+func replicasChannelSpec(cr *api.PerconaXtraDBCluster) api.ReplicationChannel {
+	channel := api.ReplicationChannel{Name: replicasChannel}
+	for i := 0; i < int(cr.Spec.PXC.Size); i++ {
+		weight := 100 - i
+		if weight < 1 {
+			weight = 1
+		}
+		channel.SourcesList = append(channel.SourcesList, api.ReplicationSource{
+			Host:   fmt.Sprintf("%s-pxc-%d.%s-pxc.%s", cr.Name, i, cr.Name, cr.Namespace),
+			Port:   3306,
+			Weight: weight,
+		})
+	}
+
+	return channel
+}
@@ -0,0 +1,37 @@
+package pxc
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestReplicasChannelSpec(t *testing.T) {
+	cr := &api.PerconaXtraDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Namespace: "ns"},
+		Spec: api.PerconaXtraDBClusterSpec{
+			PXC: &api.PXCSpec{PodSpec: &api.PodSpec{Size: 3}},
+		},
+	}
+
+	channel := replicasChannelSpec(cr)
+	if channel.Name != replicasChannel {
+		t.Errorf("channel name = %s, want %s", channel.Name, replicasChannel)
+	}
+
+	want := []api.ReplicationSource{
+		{Host: "cluster1-pxc-0.cluster1-pxc.ns", Port: 3306, Weight: 100},
+		{Host: "cluster1-pxc-1.cluster1-pxc.ns", Port: 3306, Weight: 99},
+		{Host: "cluster1-pxc-2.cluster1-pxc.ns", Port: 3306, Weight: 98},
+	}
+	if len(channel.SourcesList) != len(want) {
+		t.Fatalf("got %d sources, want %d", len(channel.SourcesList), len(want))
+	}
+	for i := range want {
+		if channel.SourcesList[i] != want[i] {
+			t.Errorf("source %d = %+v, want %+v", i, channel.SourcesList[i], want[i])
+		}
+	}
+}
@@ -2,14 +2,23 @@ package pxc
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/users"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -162,3 +171,429 @@ func NewExposedPXCService(svcName string, cr *api.PerconaXtraDBCluster) *corev1.
 
 	return svc
 }
+
+// replicationPodLabel marks the PXC pod that runs the replica channels
+const replicationPodLabel = "percona.com/replicationPod"
+
+// reconcileReplication manages the replication channels of the cluster.
+// The source cluster gets the replication user. The replica cluster runs
+// its channels on one of the ready PXC pods and moves them to another pod
+// if that one is lost.
+func (r *ReconcilePerconaXtraDBCluster) reconcileReplication(cr *api.PerconaXtraDBCluster) error {
+	if cr.Spec.Pause || cr.Status.PXC.Ready < 1 || cr.CompareVersionWith("1.9.0") < 0 {
+		return nil
+	}
+
+	isRestoreRunning, err := r.isRestoreRunning(cr.Name, cr.Namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to check if restore is running")
+	}
+	if isRestoreRunning {
+		return nil
+	}
+
+	channels := cr.Spec.PXC.ReplicationChannels
+	if len(channels) > 0 && channels[0].IsSource {
+		cr.Status.ReplicationChannels = nil
+		return r.ensureReplicationUser(cr, channels[0].SecretName)
+	}
+
+	pods := &corev1.PodList{}
+	err = r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labels.SelectorFromSet(statefulset.NewNode(cr).Labels()),
+	})
+	if err != nil {
+		return errors.Wrap(err, "get pxc pods")
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	var replicaPod *corev1.Pod
+	labeled := false
+	ready := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		_, ok := pod.Labels[replicationPodLabel]
+		labeled = labeled || ok
+		if !isPodReady(pod) {
+			continue
+		}
+		ready = append(ready, pod)
+		if ok && replicaPod == nil && len(channels) > 0 {
+			replicaPod = pod
+		}
+	}
+	if len(channels) == 0 && !labeled {
+		return nil
+	}
+	if replicaPod == nil && len(channels) > 0 && len(ready) > 0 {
+		replicaPod = ready[0]
+	}
+
+	// channels are kept in the node data, so the pods that ran them before
+	// would start them again after a restart
+	for _, pod := range ready {
+		if pod == replicaPod {
+			continue
+		}
+		err = r.removeReplicationChannels(cr, pod)
+		if err != nil {
+			return errors.Wrapf(err, "remove replication channels from pod %s", pod.Name)
+		}
+	}
+
+	if replicaPod == nil {
+		cr.Status.ReplicationChannels = nil
+		return nil
+	}
+
+	db, err := r.pxcPodDB(cr, replicaPod)
+	if err != nil {
+		return errors.Wrapf(err, "connect to pod %s", replicaPod.Name)
+	}
+	defer db.Close()
+
+	err = removeOutdatedChannels(db, channels)
+	if err != nil {
+		return errors.Wrap(err, "remove outdated channels")
+	}
+
+	statuses := make([]api.ReplicationChannelStatus, 0, len(channels))
+	for _, channel := range channels {
+		pass, err := r.replicationPass(cr, channel.SecretName)
+		if err != nil {
+			return err
+		}
+		err = manageReplicationChannel(r.logger(cr.Name, cr.Namespace), db, channel, api.ReplicationUser, pass)
+		if err != nil {
+			return errors.Wrapf(err, "manage channel %s", channel.Name)
+		}
+
+		status, err := db.ReplicaStatus(channel.Name)
+		if err != nil {
+			return errors.Wrapf(err, "get channel %s status", channel.Name)
+		}
+		statuses = append(statuses, channelStatus(channel.Name, replicaPod.Name, status))
+	}
+	cr.Status.ReplicationChannels = statuses
+
+	if _, ok := replicaPod.Labels[replicationPodLabel]; !ok {
+		replicaPod.Labels[replicationPodLabel] = "true"
+		err = r.client.Update(context.TODO(), replicaPod)
+		if err != nil {
+			return errors.Wrapf(err, "label pod %s", replicaPod.Name)
+		}
+	}
+
+	return nil
+}
+
+func channelStatus(name, pod string, s queries.ReplicaStatus) api.ReplicationChannelStatus {
+	lastErr := s.LastIOError
+	if s.LastSQLError != "" {
+		if lastErr != "" {
+			lastErr += "; "
+		}
+		lastErr += s.LastSQLError
+	}
+
+	return api.ReplicationChannelStatus{
+		Name:                name,
+		Pod:                 pod,
+		Source:              fmt.Sprintf("%s:%d", s.SourceHost, s.SourcePort),
+		IOThreadState:       s.IORunning,
+		SQLThreadState:      s.SQLRunning,
+		SecondsBehindSource: s.SecondsBehindSource,
+		LastError:           lastErr,
+		ReceivedGTIDSet:     s.RetrievedGTIDSet,
+		ExecutedGTIDSet:     s.ExecutedGTIDSet,
+	}
+}
+
+// setReplicationCondition sets the ReplicationReady condition of the replica cluster
+// from the channels status or the error of the channels reconciliation
+func setReplicationCondition(cr *api.PerconaXtraDBCluster, reconcileErr error) {
+	channels := cr.Spec.PXC.ReplicationChannels
+	if len(channels) == 0 || channels[0].IsSource {
+		cr.Status.ReplicationChannels = nil
+		cr.Status.RemoveCondition(api.ConditionReplicationReady)
+		return
+	}
+
+	c := api.ClusterCondition{
+		Type:               api.ConditionReplicationReady,
+		Status:             api.ConditionTrue,
+		Reason:             "ChannelsRunning",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	}
+
+	broken := []string{}
+	for _, s := range cr.Status.ReplicationChannels {
+		if s.Healthy() {
+			continue
+		}
+		msg := fmt.Sprintf("%s: io thread %s, sql thread %s", s.Name, s.IOThreadState, s.SQLThreadState)
+		if s.LastError != "" {
+			msg += ": " + s.LastError
+		}
+		broken = append(broken, msg)
+	}
+
+	switch {
+	case reconcileErr != nil:
+		c.Status, c.Reason, c.Message = api.ConditionFalse, "ReconcileError", reconcileErr.Error()
+	case len(cr.Status.ReplicationChannels) < len(channels):
+		c.Status, c.Reason, c.Message = api.ConditionFalse, "ChannelsNotConfigured", "no ready pod to run the channels"
+	case len(broken) > 0:
+		c.Status, c.Reason, c.Message = api.ConditionFalse, "ChannelsBroken", strings.Join(broken, "\n")
+	}
+
+	cr.Status.SetCondition(c)
+}
+
+// manageReplicationChannel makes the channel replicate from the highest weight source.
+// Since 8.0.22 MySQL fails the channel over to the other sources by itself, the channel
+// is switched to the next source only if MySQL gave up after the connection retries.
+func manageReplicationChannel(log logr.Logger, db queries.Database, channel api.ReplicationChannel, user, pass string) error {
+	failover, err := db.ReplicaSyntaxSupported()
+	if err != nil {
+		return errors.Wrap(err, "check replication syntax")
+	}
+	status, err := db.ReplicationStatus(channel.Name)
+	if err != nil && err != queries.ErrNotFound {
+		return errors.Wrap(err, "get channel status")
+	}
+	exists := err == nil
+
+	sources := replicationSources(channel.SourcesList)
+	// the older servers keep only the current source of the channel
+	current := []queries.ReplicationSource{}
+	configured := exists && hasSource(sources, status.Host, status.Port)
+	if failover {
+		current, err = db.ReplicationChannelSources(channel.Name)
+		if err != nil {
+			return errors.Wrap(err, "get channel sources")
+		}
+		configured = exists && sourcesEqual(current, sources)
+	}
+
+	src := sources[0]
+	switch {
+	case !configured:
+		log.Info("configure replication channel", "channel", channel.Name, "source", src.Host)
+	case status.State == "ON", status.State == "CONNECTING":
+		// the connection is retried, MySQL fails it over if it can
+		return nil
+	case status.LastErrorNum != 0:
+		src = nextSource(sources, status.Host, status.Port)
+		log.Info("replication source is lost, switch to the next one", "channel", channel.Name,
+			"lost", status.Host, "source", src.Host, "error", status.LastError)
+	default:
+		// the channel was stopped but the source is fine
+		src = queries.ReplicationSource{Host: status.Host, Port: status.Port}
+	}
+
+	if exists {
+		err = db.StopReplication(channel.Name)
+		if err != nil {
+			return errors.Wrap(err, "stop replication")
+		}
+	}
+
+	if failover && !sourcesEqual(current, sources) {
+		for _, s := range current {
+			err = db.DeleteReplicationSource(channel.Name, s)
+			if err != nil {
+				return errors.Wrapf(err, "delete source %s", s.Host)
+			}
+		}
+		for _, s := range sources {
+			err = db.AddReplicationSource(channel.Name, s)
+			if err != nil {
+				return errors.Wrapf(err, "add source %s", s.Host)
+			}
+		}
+	}
+
+	err = db.StartReplication(channel.Name, user, pass, src)
+	return errors.Wrap(err, "start replication")
+}
+
+// removeOutdatedChannels deletes the channels that aren't in the cluster spec anymore
+func removeOutdatedChannels(db queries.Database, channels []api.ReplicationChannel) error {
+	current, err := db.ReplicationChannels()
+	if err != nil {
+		return errors.Wrap(err, "get channels")
+	}
+
+	for _, name := range current {
+		// the default channel isn't managed by the operator
+		if name == "" || hasChannel(channels, name) {
+			continue
+		}
+		err = db.DeleteReplicationChannel(name)
+		if err != nil {
+			return errors.Wrapf(err, "delete channel %s", name)
+		}
+	}
+
+	return nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) removeReplicationChannels(cr *api.PerconaXtraDBCluster, pod *corev1.Pod) error {
+	db, err := r.pxcPodDB(cr, pod)
+	if err != nil {
+		return errors.Wrap(err, "connect to pod")
+	}
+	defer db.Close()
+
+	err = removeOutdatedChannels(db, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := pod.Labels[replicationPodLabel]; ok {
+		delete(pod.Labels, replicationPodLabel)
+		err = r.client.Update(context.TODO(), pod)
+		if err != nil {
+			return errors.Wrap(err, "remove pod label")
+		}
+	}
+
+	return nil
+}
+
+// ensureReplicationUser creates the replication user on the source cluster
+// and keeps its password in sync with the secret
+func (r *ReconcilePerconaXtraDBCluster) ensureReplicationUser(cr *api.PerconaXtraDBCluster, secretName string) error {
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: cr.Namespace}, secret)
+	if err != nil {
+		return errors.Wrapf(err, "get secret %s", secretName)
+	}
+	pass, ok := secret.Data[api.ReplicationUser]
+	if !ok {
+		return errors.Errorf("no %s key in secret %s", api.ReplicationUser, secretName)
+	}
+
+	annotationName := "percona.com/replication-user-hash"
+	hash := fmt.Sprintf("%x", sha256.Sum256(pass))
+	if secret.Annotations[annotationName] == hash {
+		return nil
+	}
+
+	internalSecret := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: "internal-" + cr.Name, Namespace: cr.Namespace}, internalSecret)
+	if err != nil {
+		return errors.Wrap(err, "get internal secret")
+	}
+
+	um, err := users.NewManager(cr.Name+"-pxc-unready."+cr.Namespace+":33062", "root", string(internalSecret.Data["root"]))
+	if err != nil {
+		return errors.Wrap(err, "new users manager")
+	}
+	defer um.Close()
+
+	err = um.CreateReplicationUser(api.ReplicationUser, string(pass))
+	if err != nil {
+		return errors.Wrap(err, "create replication user")
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[annotationName] = hash
+	err = r.client.Update(context.TODO(), secret)
+	return errors.Wrap(err, "update replication secret annotation")
+}
+
+func (r *ReconcilePerconaXtraDBCluster) replicationPass(cr *api.PerconaXtraDBCluster, secretName string) (string, error) {
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: cr.Namespace}, secret)
+	if err != nil {
+		return "", errors.Wrapf(err, "get secret %s", secretName)
+	}
+	pass, ok := secret.Data[api.ReplicationUser]
+	if !ok {
+		return "", errors.Errorf("no %s key in secret %s", api.ReplicationUser, secretName)
+	}
+
+	return string(pass), nil
+}
+
+// pxcPodDB connects to the PXC node of the pod
+func (r *ReconcilePerconaXtraDBCluster) pxcPodDB(cr *api.PerconaXtraDBCluster, pod *corev1.Pod) (queries.Database, error) {
+	host := pod.Name + "." + cr.Name + "-pxc." + cr.Namespace
+	return queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root", host, 33062)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func hasChannel(channels []api.ReplicationChannel, name string) bool {
+	for _, c := range channels {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replicationSources returns the channel sources ordered by weight, the highest first
+func replicationSources(list []api.ReplicationSource) []queries.ReplicationSource {
+	sources := make([]queries.ReplicationSource, 0, len(list))
+	for _, s := range list {
+		sources = append(sources, queries.ReplicationSource{Host: s.Host, Port: s.Port, Weight: s.Weight})
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Weight > sources[j].Weight })
+
+	return sources
+}
+
+func sourcesEqual(a, b []queries.ReplicationSource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[queries.ReplicationSource]struct{}, len(a))
+	for _, s := range a {
+		set[s] = struct{}{}
+	}
+	for _, s := range b {
+		if _, ok := set[s]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasSource(sources []queries.ReplicationSource, host string, port int) bool {
+	for _, s := range sources {
+		if s.Host == host && s.Port == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nextSource returns the source that follows the given one by weight.
+// It starts over from the highest weight source after the last one.
+func nextSource(sources []queries.ReplicationSource, host string, port int) queries.ReplicationSource {
+	for i, s := range sources {
+		if s.Host == host && s.Port == port {
+			return sources[(i+1)%len(sources)]
+		}
+	}
+
+	return sources[0]
+}
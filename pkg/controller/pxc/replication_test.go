@@ -0,0 +1,91 @@
+package pxc
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+)
+
+func TestNextSource(t *testing.T) {
+	sources := []queries.ReplicationSource{
+		{Host: "a", Port: 3306, Weight: 100},
+		{Host: "b", Port: 3306, Weight: 90},
+		{Host: "c", Port: 3306, Weight: 80},
+	}
+
+	tests := map[string]struct {
+		host string
+		port int
+		want string
+	}{
+		"first":   {"a", 3306, "b"},
+		"middle":  {"b", 3306, "c"},
+		"last":    {"c", 3306, "a"},
+		"unknown": {"d", 3306, "a"},
+		"port":    {"b", 3307, "a"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := nextSource(sources, tt.host, tt.port); got.Host != tt.want {
+				t.Errorf("nextSource() = %s, want %s", got.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetReplicationCondition(t *testing.T) {
+	replica := []api.ReplicationChannel{{Name: "ch1", SourcesList: []api.ReplicationSource{{Host: "a"}}}}
+	running := api.ReplicationChannelStatus{Name: "ch1", IOThreadState: "Yes", SQLThreadState: "Yes"}
+	broken := api.ReplicationChannelStatus{Name: "ch1", IOThreadState: "Connecting", SQLThreadState: "Yes", LastError: "can't connect"}
+
+	tests := map[string]struct {
+		channels []api.ReplicationChannel
+		statuses []api.ReplicationChannelStatus
+		err      error
+		want     api.ConditionStatus
+		reason   string
+	}{
+		"no channels": {nil, []api.ReplicationChannelStatus{running}, nil, "", ""},
+		"source":      {[]api.ReplicationChannel{{Name: "ch1", IsSource: true}}, nil, nil, "", ""},
+		"running":     {replica, []api.ReplicationChannelStatus{running}, nil, api.ConditionTrue, "ChannelsRunning"},
+		"broken":      {replica, []api.ReplicationChannelStatus{broken}, nil, api.ConditionFalse, "ChannelsBroken"},
+		"no status":   {replica, nil, nil, api.ConditionFalse, "ChannelsNotConfigured"},
+		"error":       {replica, []api.ReplicationChannelStatus{running}, errors.New("connect"), api.ConditionFalse, "ReconcileError"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := &api.PerconaXtraDBCluster{
+				Spec: api.PerconaXtraDBClusterSpec{
+					PXC: &api.PXCSpec{ReplicationChannels: tt.channels},
+				},
+				Status: api.PerconaXtraDBClusterStatus{
+					ReplicationChannels: tt.statuses,
+					Conditions:          []api.ClusterCondition{{Type: api.AppStateReady, Status: api.ConditionTrue}},
+				},
+			}
+
+			setReplicationCondition(cr, tt.err)
+
+			var got *api.ClusterCondition
+			for i, c := range cr.Status.Conditions {
+				if c.Type == api.ConditionReplicationReady {
+					got = &cr.Status.Conditions[i]
+				}
+			}
+			if tt.want == "" {
+				if got != nil || cr.Status.ReplicationChannels != nil {
+					t.Errorf("unexpected replication status: %v, %v", got, cr.Status.ReplicationChannels)
+				}
+				return
+			}
+			if got == nil || got.Status != tt.want || got.Reason != tt.reason {
+				t.Errorf("got condition %v, want %s with reason %s", got, tt.want, tt.reason)
+			}
+		})
+	}
+}
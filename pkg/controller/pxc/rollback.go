@@ -0,0 +1,204 @@
+package pxc
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+// upgradeFailure is the error of the updated pod that isn't going to join the cluster
+type upgradeFailure struct {
+	reason string
+	msg    string
+}
+
+func (f upgradeFailure) Error() string {
+	return f.reason + ": " + f.msg
+}
+
+// pxcUpdateFrozen is true when the update was rolled back and the PXC spec the failed
+// revision was made from hasn't been changed since then, so it isn't applied again
+func pxcUpdateFrozen(cr *api.PerconaXtraDBCluster) bool {
+	return cr.Status.UpgradeRollback != nil && cr.Status.UpgradeRollback.SpecHash == pxcSpecHash(cr)
+}
+
+// pxcSpecHash returns the hash of the PXC spec. The changes of the other parts
+// of the cluster spec don't affect the PXC statefulset template.
+func pxcSpecHash(cr *api.PerconaXtraDBCluster) string {
+	data, err := json.Marshal(cr.Spec.PXC)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", md5.Sum(data))
+}
+
+// rollbackUpdate reverts the template of the PXC statefulset to the current revision,
+// the one the pods were running before the update, and restarts the updated pods.
+// The major version upgrade isn't rolled back if mysqld of the new version has already
+// run on the data: the data directory may be upgraded and the previous version can't use it.
+func (r *ReconcilePerconaXtraDBCluster) rollbackUpdate(cr *api.PerconaXtraDBCluster, sfs *appsv1.StatefulSet, failure upgradeFailure) error {
+	failedRevision := sfs.Status.UpdateRevision
+	restoredRevision := sfs.Status.CurrentRevision
+	if restoredRevision == "" || restoredRevision == failedRevision {
+		return errors.Wrap(failure, "no revision to roll back to")
+	}
+
+	rev := &appsv1.ControllerRevision{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: restoredRevision, Namespace: sfs.Namespace}, rev)
+	if err != nil {
+		return errors.Wrapf(err, "get controller revision %s", restoredRevision)
+	}
+	tmpl, err := revisionTemplate(rev)
+	if err != nil {
+		return errors.Wrapf(err, "controller revision %s", restoredRevision)
+	}
+
+	current := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: sfs.Name, Namespace: sfs.Namespace}, current)
+	if err != nil {
+		return errors.Wrap(err, "get statefulset")
+	}
+	failedImage := containerImage(current.Spec.Template.Spec.Containers, "pxc")
+	restoredImage := containerImage(tmpl.Spec.Containers, "pxc")
+
+	list := &corev1.PodList{}
+	err = r.client.List(context.TODO(), list, &client.ListOptions{
+		Namespace:     sfs.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{"controller-revision-hash": failedRevision}),
+	})
+	if err != nil {
+		return errors.Wrap(err, "get updated pods")
+	}
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	for _, pod := range list.Items {
+		if metav1.IsControlledBy(&pod, sfs) {
+			pods = append(pods, pod)
+		}
+	}
+
+	if imageMajorVersion(failedImage) != imageMajorVersion(restoredImage) {
+		for _, pod := range pods {
+			if mysqldStarted(&pod) {
+				r.majorUpgradeFailed(cr, failure, pod.Name)
+				return nil
+			}
+		}
+	}
+
+	current.Spec.Template = tmpl
+	err = r.client.Update(context.TODO(), current)
+	if err != nil {
+		return errors.Wrap(err, "restore statefulset template")
+	}
+
+	// the pods are restarted with the restored template
+	for i := range pods {
+		err = r.client.Delete(context.TODO(), &pods[i])
+		if err != nil {
+			return errors.Wrapf(err, "delete pod %s", pods[i].Name)
+		}
+	}
+
+	r.logger(cr.Name, cr.Namespace).Info("update is rolled back", "reason", failure.Error(),
+		"failed revision", failedRevision, "restored revision", restoredRevision)
+
+	cr.Status.PXC.Image = restoredImage
+	cr.Status.SmartUpdate = nil
+	cr.Status.UpgradeRollback = &api.UpgradeRollbackStatus{
+		FailedRevision:   failedRevision,
+		FailedImage:      failedImage,
+		RestoredRevision: restoredRevision,
+		RestoredImage:    restoredImage,
+		SpecHash:         pxcSpecHash(cr),
+		Reason:           failure.Error(),
+		Time:             metav1.Now(),
+	}
+	cr.Status.SetCondition(api.ClusterCondition{
+		Type:               api.ConditionUpgradeFailed,
+		Status:             api.ConditionTrue,
+		Reason:             failure.reason,
+		Message:            "rolled back to revision " + restoredRevision + ": " + failure.msg,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	})
+
+	return nil
+}
+
+// majorUpgradeFailed stops the major version upgrade that can't be rolled back.
+// The update doesn't go on, the user has to fix the pod or restore the pre-upgrade backup.
+func (r *ReconcilePerconaXtraDBCluster) majorUpgradeFailed(cr *api.PerconaXtraDBCluster, failure upgradeFailure, podName string) {
+	backup := "a backup made before the upgrade"
+	if st := cr.Status.MajorUpgrade; st != nil && st.Backup != "" {
+		backup = "the pre-upgrade backup " + st.Backup
+	}
+	msg := fmt.Sprintf("%s; %s has started the new major version on its data, so the update isn't rolled back."+
+		" Fix the pod or restore %s", failure.msg, podName, backup)
+
+	for _, c := range cr.Status.Conditions {
+		if c.Type == api.ConditionUpgradeFailed && c.Message == msg {
+			return
+		}
+	}
+
+	r.logger(cr.Name, cr.Namespace).Info("major version upgrade failed", "reason", failure.Error(), "pod", podName)
+	cr.Status.SetCondition(api.ClusterCondition{
+		Type:               api.ConditionUpgradeFailed,
+		Status:             api.ConditionTrue,
+		Reason:             failure.reason,
+		Message:            msg,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	})
+}
+
+// mysqldStarted is true if the pxc container of the pod has ever been started
+func mysqldStarted(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Name == "pxc" {
+			return c.RestartCount > 0 || c.State.Running != nil || c.State.Terminated != nil || c.LastTerminationState.Terminated != nil
+		}
+	}
+
+	return false
+}
+
+// revisionTemplate returns the pod template kept in the statefulset controller revision
+func revisionTemplate(rev *appsv1.ControllerRevision) (corev1.PodTemplateSpec, error) {
+	data := struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}{}
+
+	err := json.Unmarshal(rev.Data.Raw, &data)
+	if err != nil {
+		return data.Spec.Template, errors.Wrap(err, "unmarshal revision data")
+	}
+	if len(data.Spec.Template.Spec.Containers) == 0 {
+		return data.Spec.Template, errors.New("revision has no pod template")
+	}
+
+	return data.Spec.Template, nil
+}
+
+func containerImage(containers []corev1.Container, name string) string {
+	for _, c := range containers {
+		if c.Name == name {
+			return c.Image
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,179 @@
+package pxc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestRollbackUpdate(t *testing.T) {
+	cr := newCR("cluster1", "ns")
+	cr.Spec.PXC.Image = "pxc:8.0.23"
+
+	template := func(image string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "pxc", Image: image}}}}
+	}
+
+	sfs := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1-pxc", Namespace: "ns", UID: "sfs-uid"},
+		Spec:       appsv1.StatefulSetSpec{Template: template("pxc:8.0.23")},
+		Status:     appsv1.StatefulSetStatus{CurrentRevision: "cluster1-pxc-old", UpdateRevision: "cluster1-pxc-new"},
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"template": template("pxc:8.0.22")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1-pxc-old", Namespace: "ns"},
+		Data:       runtime.RawExtension{Raw: data},
+	}
+
+	isController := true
+	pod := func(name, revision string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "ns",
+			Labels:          map[string]string{"controller-revision-hash": revision},
+			OwnerReferences: []metav1.OwnerReference{{Name: sfs.Name, UID: sfs.UID, Controller: &isController}},
+		}}
+	}
+
+	r := buildFakeClient([]runtime.Object{sfs, rev, pod("cluster1-pxc-0", "cluster1-pxc-old"), pod("cluster1-pxc-2", "cluster1-pxc-new")})
+	r.log = zapr.NewLogger(zap.NewNop())
+
+	err = r.rollbackUpdate(cr, sfs, upgradeFailure{reason: "CrashLoopBackOff", msg: "pod cluster1-pxc-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: sfs.Name, Namespace: "ns"}, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := containerImage(restored.Spec.Template.Spec.Containers, "pxc"); image != "pxc:8.0.22" {
+		t.Errorf("statefulset image = %s, want pxc:8.0.22", image)
+	}
+
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster1-pxc-2", Namespace: "ns"}, &corev1.Pod{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("updated pod isn't deleted: %v", err)
+	}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster1-pxc-0", Namespace: "ns"}, &corev1.Pod{})
+	if err != nil {
+		t.Errorf("pod with the previous revision is deleted: %v", err)
+	}
+
+	rb := cr.Status.UpgradeRollback
+	if rb == nil || rb.FailedImage != "pxc:8.0.23" || rb.RestoredImage != "pxc:8.0.22" || rb.SpecHash == "" {
+		t.Errorf("unexpected rollback status %+v", rb)
+	}
+	if cr.Status.PXC.Image != "pxc:8.0.22" {
+		t.Errorf("status image = %s, want pxc:8.0.22", cr.Status.PXC.Image)
+	}
+	if !pxcUpdateFrozen(cr) {
+		t.Error("update isn't frozen after the rollback")
+	}
+
+	found := false
+	for _, c := range cr.Status.Conditions {
+		if c.Type == api.ConditionUpgradeFailed && c.Status == api.ConditionTrue {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("no UpgradeFailed condition")
+	}
+
+	cr.Spec.HAProxy.Size++
+	if !pxcUpdateFrozen(cr) {
+		t.Error("update isn't frozen after the HAProxy spec change")
+	}
+
+	cr.Spec.PXC.Image = "pxc:8.0.24"
+	if pxcUpdateFrozen(cr) {
+		t.Error("update is frozen after the PXC spec change")
+	}
+}
+
+func TestRollbackMajorUpgrade(t *testing.T) {
+	cr := newCR("cluster1", "ns")
+	cr.Status.MajorUpgrade = &api.MajorUpgradeStatus{From: "5.7", To: "8.0", Backup: "pre-upgrade-cluster1"}
+
+	template := func(image string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "pxc", Image: image}}}}
+	}
+
+	sfs := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1-pxc", Namespace: "ns", UID: "sfs-uid"},
+		Spec:       appsv1.StatefulSetSpec{Template: template("pxc:8.0.23")},
+		Status:     appsv1.StatefulSetStatus{CurrentRevision: "cluster1-pxc-old", UpdateRevision: "cluster1-pxc-new"},
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"template": template("pxc:5.7.33")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1-pxc-old", Namespace: "ns"},
+		Data:       runtime.RawExtension{Raw: data},
+	}
+
+	isController := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "cluster1-pxc-2",
+			Namespace:       "ns",
+			Labels:          map[string]string{"controller-revision-hash": "cluster1-pxc-new"},
+			OwnerReferences: []metav1.OwnerReference{{Name: sfs.Name, UID: sfs.UID, Controller: &isController}},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "pxc", RestartCount: 3}}},
+	}
+
+	r := buildFakeClient([]runtime.Object{sfs, rev, pod})
+	r.log = zapr.NewLogger(zap.NewNop())
+
+	err = r.rollbackUpdate(cr, sfs, upgradeFailure{reason: "CrashLoopBackOff", msg: "pod cluster1-pxc-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := &appsv1.StatefulSet{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: sfs.Name, Namespace: "ns"}, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := containerImage(current.Spec.Template.Spec.Containers, "pxc"); image != "pxc:8.0.23" {
+		t.Errorf("statefulset image = %s, want pxc:8.0.23", image)
+	}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: "ns"}, &corev1.Pod{})
+	if err != nil {
+		t.Errorf("updated pod is deleted: %v", err)
+	}
+	if cr.Status.UpgradeRollback != nil {
+		t.Errorf("update is rolled back: %+v", cr.Status.UpgradeRollback)
+	}
+
+	found := false
+	for _, c := range cr.Status.Conditions {
+		if c.Type == api.ConditionUpgradeFailed && strings.Contains(c.Message, "pre-upgrade-cluster1") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no UpgradeFailed condition with the pre-upgrade backup: %+v", cr.Status.Conditions)
+	}
+}
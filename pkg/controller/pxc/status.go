@@ -82,7 +82,7 @@ func (r *ReconcilePerconaXtraDBCluster) updateStatus(cr *api.PerconaXtraDBCluste
 	cr.Status.Size = 0
 	cr.Status.Ready = 0
 	for _, a := range apps {
-		status, err := r.appStatus(a.app, cr.Namespace, a.spec, cr.CompareVersionWith("1.7.0") == -1, cr.Spec.Pause)
+		status, err := r.appStatus(a.app, cr.Namespace, a.spec, cr.CompareVersionWith("1.7.0") == -1, cr.Spec.Pause, cr.RestartsDeferred())
 		if err != nil {
 			return errors.Wrapf(err, "get %s status", a.app.Name())
 		}
@@ -111,6 +111,19 @@ func (r *ReconcilePerconaXtraDBCluster) updateStatus(cr *api.PerconaXtraDBCluste
 		}
 	}
 
+	// read replicas don't affect the cluster state, they are reported on their own
+	cr.Status.Replicas = api.AppStatus{}
+	if cr.ReplicasEnabled() {
+		status, err := r.appStatus(statefulset.NewReplica(cr), cr.Namespace, cr.Spec.Replicas, false, cr.Spec.Pause, cr.RestartsDeferred())
+		if err != nil {
+			return errors.Wrap(err, "get replicas status")
+		}
+		cr.Status.Replicas = status
+		if status.Message != "" {
+			cr.Status.Messages = append(cr.Status.Messages, statefulset.ReplicaName+": "+status.Message)
+		}
+	}
+
 	cr.Status.Status = cr.Status.ClusterStatus(inProgress, cr.ObjectMeta.DeletionTimestamp != nil)
 	clusterCondition.Type = cr.Status.Status
 	cr.Status.AddCondition(clusterCondition)
@@ -139,14 +152,36 @@ func (r *ReconcilePerconaXtraDBCluster) upgradeInProgress(cr *api.PerconaXtraDBC
 	if err != nil {
 		return false, err
 	}
-	return sfsObj.Status.Replicas > sfsObj.Status.UpdatedReplicas, nil
+	// the pods aren't updated until the maintenance window opens
+	if cr.RestartsDeferred() {
+		return false, nil
+	}
+
+	replicas := sfsObj.Status.Replicas
+	if appName == "pxc" && cr.Spec.UpdateStrategy == api.SmartUpdateStatefulSetStrategyType {
+		// the pods aren't updated until the canary is approved
+		if st := cr.Status.SmartUpdate; st != nil && st.WaitingApproval {
+			return false, nil
+		}
+		// the pods with the ordinal lower than the partition keep the previous revision
+		if p := cr.Spec.UpgradeOptions.Partition; p != nil && *p > 0 {
+			held := *p
+			if held > replicas {
+				held = replicas
+			}
+			replicas -= held
+		}
+	}
+
+	return replicas > sfsObj.Status.UpdatedReplicas, nil
 }
 
 // appStatus counts the ready pods in statefulset (PXC, HAProxy, ProxySQL).
 // If ready pods are equal to the size of the statefulset, we consider them ready.
 // If a pod is in the unschedulable state for more than 1 min, we consider the statefulset in an error state.
 // Otherwise, we consider the statefulset is initializing.
-func (r *ReconcilePerconaXtraDBCluster) appStatus(app api.StatefulApp, namespace string, podSpec *api.PodSpec, crLt170, paused bool) (api.AppStatus, error) {
+// The PXC pods waiting for the maintenance window are ready with the previous revision.
+func (r *ReconcilePerconaXtraDBCluster) appStatus(app api.StatefulApp, namespace string, podSpec *api.PodSpec, crLt170, paused, restartsDeferred bool) (api.AppStatus, error) {
 	list := corev1.PodList{}
 	err := r.client.List(context.TODO(),
 		&list,
@@ -194,7 +229,7 @@ func (r *ReconcilePerconaXtraDBCluster) appStatus(app api.StatefulApp, namespace
 					return api.AppStatus{}, errors.Wrapf(err, "parse %s pod logs", pod.Name)
 				}
 
-				if !isPodWaitingForRecovery && pod.ObjectMeta.Labels["controller-revision-hash"] == sfs.Status.UpdateRevision {
+				if !isPodWaitingForRecovery && (restartsDeferred || pod.ObjectMeta.Labels["controller-revision-hash"] == sfs.Status.UpdateRevision) {
 					status.Ready++
 				}
 			case corev1.PodScheduled:
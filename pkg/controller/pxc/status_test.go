@@ -13,6 +13,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake" // nolint
 )
 
@@ -75,7 +76,7 @@ func buildFakeClient(objs []runtime.Object) *ReconcilePerconaXtraDBCluster {
 
 	cl := fake.NewFakeClientWithScheme(s, objs...)
 
-	return &ReconcilePerconaXtraDBCluster{client: cl, scheme: s}
+	return &ReconcilePerconaXtraDBCluster{client: cl, scheme: s, recorder: record.NewFakeRecorder(100)}
 }
 
 func TestAppStatusInit(t *testing.T) {
@@ -86,7 +87,7 @@ func TestAppStatusInit(t *testing.T) {
 
 	r := buildFakeClient([]runtime.Object{cr, pxcSfs})
 
-	status, err := r.appStatus(pxc, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false)
+	status, err := r.appStatus(pxc, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -110,7 +111,7 @@ func TestPXCAppStatusReady(t *testing.T) {
 
 	r := buildFakeClient(objs)
 
-	status, err := r.appStatus(pxc, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false)
+	status, err := r.appStatus(pxc, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -138,7 +139,7 @@ func TestHAProxyAppStatusReady(t *testing.T) {
 
 	r := buildFakeClient(objs)
 
-	status, err := r.appStatus(haproxy, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false)
+	status, err := r.appStatus(haproxy, cr.Namespace, cr.Spec.PXC.PodSpec, cr.CompareVersionWith("1.7.0") == -1, false, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -377,7 +378,7 @@ func TestClusterStatus(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(tt *testing.T) {
-			got := test.status.ClusterStatus(false)
+			got := test.status.ClusterStatus(false, false)
 
 			if got != test.want {
 				t.Errorf("AppState got %#v, want %#v", got, test.want)
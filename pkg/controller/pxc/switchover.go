@@ -0,0 +1,380 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/queries"
+)
+
+const (
+	// switchoverVerifyTimeout is how long the proxies have to start sending the writes to the new primary
+	switchoverVerifyTimeout = 2 * time.Minute
+	// switchoverDrainTimeout is how long the transactions on the old primary are waited for
+	switchoverDrainTimeout = time.Minute
+)
+
+// reconcileSwitchover moves the writer to the pod named in the SwitchoverAnnotation.
+// The proxies are pointed to the new primary first, then the writes are checked to go
+// to it and the transactions started on the old primary are given time to finish.
+// The switchover runs once for the annotation value, the result stays in the status.
+func (r *ReconcilePerconaXtraDBCluster) reconcileSwitchover(cr *api.PerconaXtraDBCluster) error {
+	if cr.CompareVersionWith("1.9.0") < 0 {
+		return nil
+	}
+
+	logger := r.logger(cr.Name, cr.Namespace)
+	target := cr.Annotations[api.SwitchoverAnnotation]
+	st := cr.Status.Switchover
+
+	if st == nil || switchoverFinished(st) {
+		if target == "" {
+			// HAProxy goes back to the configured order of the servers
+			if st != nil && st.State == api.SwitchoverSucceeded && !cr.ProxySQLEnabled() {
+				err := r.haproxySetPrimary(cr, "", false)
+				if err != nil {
+					logger.Error(err, "reset haproxy primary")
+					return nil
+				}
+			}
+			cr.Status.Switchover = nil
+			return nil
+		}
+		if st != nil && st.Target == target {
+			// the runtime state is lost when HAProxy restarts, the new pods use the configured order
+			if st.State == api.SwitchoverSucceeded && !cr.ProxySQLEnabled() {
+				err := r.haproxySetPrimary(cr, target, false)
+				if err != nil {
+					logger.Error(err, "keep haproxy primary", "target", target)
+				}
+			}
+			return nil
+		}
+		if reason := switchoverBlocked(cr); reason != "" {
+			logger.Info("can't start primary switchover: "+reason, "target", target)
+			return nil
+		}
+
+		logger.Info("primary switchover is requested", "target", target)
+		now := metav1.Now()
+		st = &api.SwitchoverStatus{Target: target, State: api.SwitchoverSwitching, StartedAt: now, PhaseStartedAt: now}
+		cr.Status.Switchover = st
+	}
+
+	var err error
+	switch st.State {
+	case api.SwitchoverSwitching:
+		err = r.switchPrimary(cr, st)
+	case api.SwitchoverVerifying:
+		err = r.verifySwitchover(cr, st)
+	case api.SwitchoverDraining:
+		err = r.drainOldPrimary(cr, st)
+	}
+	if err != nil {
+		logger.Error(err, "primary switchover failed", "target", st.Target)
+		finishSwitchover(st, api.SwitchoverFailed, err.Error())
+		return nil
+	}
+	if st.State == api.SwitchoverSucceeded {
+		logger.Info("primary switchover finished", "from", st.From, "to", st.Target, "message", st.Message)
+	}
+
+	return nil
+}
+
+func switchoverFinished(st *api.SwitchoverStatus) bool {
+	return st.State == api.SwitchoverSucceeded || st.State == api.SwitchoverFailed
+}
+
+// switchoverInProgress is true while the proxies are being pointed to the new primary.
+// The pods aren't restarted by SmartUpdate at that time.
+func switchoverInProgress(cr *api.PerconaXtraDBCluster) bool {
+	return cr.Status.Switchover != nil && !switchoverFinished(cr.Status.Switchover)
+}
+
+func switchoverBlocked(cr *api.PerconaXtraDBCluster) string {
+	switch {
+	case cr.Spec.Pause:
+		return "cluster is paused"
+	case pxcPodsUpdating(cr) || cr.Status.ProxySmartUpdate != nil && len(cr.Status.ProxySmartUpdate.Pods) > 0:
+		return "smart update is running"
+	case majorUpgradeHeld(cr):
+		return "major upgrade is running"
+	case cr.Status.Status != api.AppStateReady:
+		return "cluster isn't ready"
+	}
+
+	return ""
+}
+
+func finishSwitchover(st *api.SwitchoverStatus, state api.SwitchoverState, msg string) {
+	now := metav1.Now()
+	st.State = state
+	st.Message = msg
+	st.FinishedAt = &now
+}
+
+func setSwitchoverState(st *api.SwitchoverStatus, state api.SwitchoverState) {
+	st.State = state
+	st.PhaseStartedAt = metav1.Now()
+}
+
+func (r *ReconcilePerconaXtraDBCluster) switchPrimary(cr *api.PerconaXtraDBCluster, st *api.SwitchoverStatus) error {
+	sfsName := statefulset.NewNode(cr).StatefulSet().Name
+	ord, err := strconv.Atoi(strings.TrimPrefix(st.Target, sfsName+"-"))
+	if err != nil || !strings.HasPrefix(st.Target, sfsName+"-") || ord < 0 || ord >= int(cr.Spec.PXC.Size) {
+		return errors.Errorf("%s isn't a PXC pod of the cluster", st.Target)
+	}
+
+	primary, err := r.getPrimaryPod(cr)
+	if err != nil {
+		return errors.Wrap(err, "get primary pod")
+	}
+	st.From = podNameFromHost(primary)
+	if st.From == st.Target {
+		finishSwitchover(st, api.SwitchoverSucceeded, "pod is the primary already")
+		return nil
+	}
+
+	host := st.Target + "." + sfsName + "." + cr.Namespace
+	synced, err := r.isPXCSynced(cr, host)
+	if err != nil {
+		return errors.Wrap(err, "check target pod state")
+	}
+	if !synced {
+		return errors.Errorf("%s isn't synced with the cluster", st.Target)
+	}
+
+	if cr.ProxySQLEnabled() {
+		database, err := r.proxyDB(cr)
+		if err != nil {
+			return errors.Wrap(err, "failed to get proxySQL db")
+		}
+		defer database.Close()
+
+		err = database.SetPrimary(host)
+		if err == queries.ErrNotFound {
+			return errors.Errorf("%s isn't in the proxysql servers", st.Target)
+		}
+		if err != nil {
+			return errors.Wrap(err, "set proxysql writer")
+		}
+	} else {
+		err = r.haproxySetPrimary(cr, st.Target, true)
+		if err != nil {
+			return errors.Wrap(err, "set haproxy primary")
+		}
+	}
+
+	r.logger(cr.Name, cr.Namespace).Info("proxies are pointed to the new primary", "from", st.From, "to", st.Target)
+	setSwitchoverState(st, api.SwitchoverVerifying)
+
+	return nil
+}
+
+// haproxySetPrimary makes every running HAProxy pod send the writes to the target. HAProxy uses
+// the first available server of the backend, so the servers listed before the target are drained.
+// They are still checked and they are made ready again once HAProxy marks the target down,
+// so the writer fails over in the configured order. It's the runtime state, so it's applied
+// on every reconcile while the switchover is kept. The empty target makes all the servers ready.
+func (r *ReconcilePerconaXtraDBCluster) haproxySetPrimary(cr *api.PerconaXtraDBCluster, target string, switching bool) error {
+	list := corev1.PodList{}
+	err := r.client.List(context.TODO(),
+		&list,
+		&client.ListOptions{
+			Namespace:     cr.Namespace,
+			LabelSelector: labels.SelectorFromSet(statefulset.NewHAProxy(cr).Labels()),
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "get haproxy pods")
+	}
+	if switching && len(list.Items) == 0 {
+		return errors.New("no haproxy pods")
+	}
+
+	updating := make(map[string]bool)
+	if st := cr.Status.ProxySmartUpdate; st != nil {
+		for _, pod := range st.Pods {
+			updating[pod.Name] = true
+		}
+	}
+
+	for _, pod := range list.Items {
+		// the pod drained by the proxy SmartUpdate is left as it is
+		if updating[pod.Name] {
+			continue
+		}
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			if switching {
+				return errors.Errorf("%s isn't running", pod.Name)
+			}
+			continue
+		}
+
+		state, err := r.haproxyExec(cr, pod.Name, "echo 'show servers state' | socat stdio unix-connect:"+haproxySocket)
+		if err != nil {
+			return errors.Wrapf(err, "get servers state of %s", pod.Name)
+		}
+
+		servers := parseHAProxyServers(state)
+		if switching && !hasHAProxyServer(servers, target) {
+			return errors.Errorf("%s has no server %s", pod.Name, target)
+		}
+
+		cmds := haproxyPrimaryCommands(servers, target)
+		if len(cmds) == 0 {
+			continue
+		}
+
+		_, err = r.haproxyExec(cr, pod.Name, fmt.Sprintf("echo '%s' | socat stdio unix-connect:%s", strings.Join(cmds, "; "), haproxySocket))
+		if err != nil {
+			return errors.Wrapf(err, "set servers state of %s", pod.Name)
+		}
+		r.logger(cr.Name, cr.Namespace).Info("haproxy servers state is changed", "pod", pod.Name, "primary", target, "commands", cmds)
+	}
+
+	return nil
+}
+
+type haproxyServer struct {
+	backend string
+	name    string
+	up      bool
+	drained bool
+}
+
+// parseHAProxyServers returns the servers of the writer backends from the output
+// of the "show servers state" command in the configured order.
+// The replica backends balance the reads across all nodes, so they are skipped.
+func parseHAProxyServers(serversState string) []haproxyServer {
+	servers := []haproxyServer{}
+	for _, line := range strings.Split(serversState, "\n") {
+		f := strings.Fields(line)
+		// be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state ...
+		if len(f) < 7 || strings.HasPrefix(f[0], "#") || strings.Contains(f[1], "replica") {
+			continue
+		}
+		opState, err := strconv.Atoi(f[5])
+		if err != nil {
+			continue
+		}
+		adminState, err := strconv.Atoi(f[6])
+		if err != nil {
+			continue
+		}
+
+		servers = append(servers, haproxyServer{
+			backend: f[1],
+			name:    f[3],
+			// SRV_ST_RUNNING and not in any maintenance mode
+			up: opState == 2 && adminState&haproxyAdminMaint == 0,
+			// SRV_ADMF_FDRAIN is set by the "state drain" command
+			drained: adminState&haproxyAdminForcedDrain != 0,
+		})
+	}
+
+	return servers
+}
+
+const (
+	// haproxyAdminMaint are the SRV_ADMF_*MAINT flags of srv_admin_state
+	haproxyAdminMaint = 0x01 | 0x02 | 0x04 | 0x20 | 0x40
+	// haproxyAdminForcedDrain is the SRV_ADMF_FDRAIN flag of srv_admin_state
+	haproxyAdminForcedDrain = 0x08
+)
+
+func hasHAProxyServer(servers []haproxyServer, name string) bool {
+	for _, srv := range servers {
+		if srv.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// haproxyPrimaryCommands returns the runtime API commands that drain the servers before
+// the target in each backend with it and make the other drained servers ready.
+// Nothing is drained while the target is down.
+func haproxyPrimaryCommands(servers []haproxyServer, target string) []string {
+	targetUp := make(map[string]bool)
+	for _, srv := range servers {
+		if srv.name == target && srv.up {
+			targetUp[srv.backend] = true
+		}
+	}
+
+	cmds := []string{}
+	passed := make(map[string]bool)
+	for _, srv := range servers {
+		passed[srv.backend] = passed[srv.backend] || srv.name == target
+		drain := targetUp[srv.backend] && !passed[srv.backend]
+		switch {
+		case drain && !srv.drained:
+			cmds = append(cmds, fmt.Sprintf("set server %s/%s state drain", srv.backend, srv.name))
+		case !drain && srv.drained:
+			cmds = append(cmds, fmt.Sprintf("set server %s/%s state ready", srv.backend, srv.name))
+		}
+	}
+
+	return cmds
+}
+
+func (r *ReconcilePerconaXtraDBCluster) verifySwitchover(cr *api.PerconaXtraDBCluster, st *api.SwitchoverStatus) error {
+	primary, err := r.getPrimaryPod(cr)
+	if err == nil && podNameFromHost(primary) == st.Target {
+		setSwitchoverState(st, api.SwitchoverDraining)
+		return nil
+	}
+
+	if time.Since(st.PhaseStartedAt.Time) < switchoverVerifyTimeout {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get primary pod")
+	}
+
+	return errors.Errorf("writes go to %s after %s", primary, switchoverVerifyTimeout)
+}
+
+func (r *ReconcilePerconaXtraDBCluster) drainOldPrimary(cr *api.PerconaXtraDBCluster, st *api.SwitchoverStatus) error {
+	database, err := queries.New(r.client, cr.Namespace, "internal-"+cr.Name, "root",
+		st.From+"."+statefulset.NewNode(cr).StatefulSet().Name+"."+cr.Namespace, 33062)
+	if err != nil {
+		finishSwitchover(st, api.SwitchoverSucceeded, "can't check transactions on the old primary: "+err.Error())
+		return nil
+	}
+	defer database.Close()
+
+	trx, err := database.ActiveTransactions()
+	if err != nil {
+		finishSwitchover(st, api.SwitchoverSucceeded, "can't check transactions on the old primary: "+err.Error())
+		return nil
+	}
+	if trx == 0 {
+		finishSwitchover(st, api.SwitchoverSucceeded, "")
+		return nil
+	}
+	if time.Since(st.PhaseStartedAt.Time) >= switchoverDrainTimeout {
+		finishSwitchover(st, api.SwitchoverSucceeded, fmt.Sprintf("%d transactions are still open on %s", trx, st.From))
+	}
+
+	return nil
+}
+
+// podNameFromHost returns the pod name from its hostname or FQDN
+func podNameFromHost(host string) string {
+	return strings.SplitN(host, ".", 2)[0]
+}
@@ -0,0 +1,108 @@
+package pxc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestHAProxyPrimaryCommands(t *testing.T) {
+	state := func(states ...string) string {
+		return `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight
+3 galera-nodes 1 cluster1-pxc-0 10.0.0.1 ` + states[0] + ` 1
+3 galera-nodes 2 cluster1-pxc-1 10.0.0.2 ` + states[1] + ` 1
+3 galera-nodes 3 cluster1-pxc-2 10.0.0.3 ` + states[2] + ` 1
+4 galera-replica-nodes 1 cluster1-pxc-0 10.0.0.1 2 0 1
+4 galera-replica-nodes 2 cluster1-pxc-1 10.0.0.2 2 0 1
+`
+	}
+
+	tests := map[string]struct {
+		state  string
+		target string
+		cmds   []string
+	}{
+		"middle": {state("2 0", "2 0", "2 0"), "cluster1-pxc-1", []string{
+			"set server galera-nodes/cluster1-pxc-0 state drain",
+		}},
+		"applied already": {state("2 8", "2 0", "2 0"), "cluster1-pxc-1", []string{}},
+		"last": {state("2 8", "2 0", "2 0"), "cluster1-pxc-2", []string{
+			"set server galera-nodes/cluster1-pxc-1 state drain",
+		}},
+		"first": {state("2 8", "2 8", "2 0"), "cluster1-pxc-0", []string{
+			"set server galera-nodes/cluster1-pxc-0 state ready",
+			"set server galera-nodes/cluster1-pxc-1 state ready",
+		}},
+		"target is down": {state("2 8", "0 0", "2 0"), "cluster1-pxc-1", []string{
+			"set server galera-nodes/cluster1-pxc-0 state ready",
+		}},
+		"reset": {state("2 8", "2 8", "2 0"), "", []string{
+			"set server galera-nodes/cluster1-pxc-0 state ready",
+			"set server galera-nodes/cluster1-pxc-1 state ready",
+		}},
+		"unknown": {state("2 0", "2 0", "2 0"), "cluster1-pxc-5", []string{}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmds := haproxyPrimaryCommands(parseHAProxyServers(tt.state), tt.target)
+			if !reflect.DeepEqual(cmds, tt.cmds) {
+				t.Errorf("got %q, want %q", cmds, tt.cmds)
+			}
+		})
+	}
+}
+
+func TestReconcileSwitchover(t *testing.T) {
+	tests := map[string]struct {
+		target string
+		state  api.AppState
+		status *api.SwitchoverStatus
+		want   *api.SwitchoverStatus
+	}{
+		"no request": {"", api.AppStateReady, nil, nil},
+		"result is dropped with the annotation": {"", api.AppStateReady,
+			&api.SwitchoverStatus{Target: "cluster1-pxc-1", State: api.SwitchoverSucceeded}, nil},
+		"cluster isn't ready": {"cluster1-pxc-1", api.AppStateInit, nil, nil},
+		"not a pxc pod": {"cluster1-haproxy-0", api.AppStateReady, nil,
+			&api.SwitchoverStatus{Target: "cluster1-haproxy-0", State: api.SwitchoverFailed, Message: "cluster1-haproxy-0 isn't a PXC pod of the cluster"}},
+		"pod out of cluster size": {"cluster1-pxc-3", api.AppStateReady, nil,
+			&api.SwitchoverStatus{Target: "cluster1-pxc-3", State: api.SwitchoverFailed, Message: "cluster1-pxc-3 isn't a PXC pod of the cluster"}},
+		"finished": {"cluster1-pxc-1", api.AppStateReady,
+			&api.SwitchoverStatus{Target: "cluster1-pxc-1", State: api.SwitchoverSucceeded},
+			&api.SwitchoverStatus{Target: "cluster1-pxc-1", State: api.SwitchoverSucceeded}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Spec.CRVersion = "1.9.0"
+			cr.Annotations = map[string]string{api.SwitchoverAnnotation: tt.target}
+			cr.Status.Status = tt.state
+			cr.Status.Switchover = tt.status
+
+			r := buildFakeClient(nil)
+			r.log = zapr.NewLogger(zap.NewNop())
+
+			if err := r.reconcileSwitchover(cr); err != nil {
+				t.Fatal(err)
+			}
+
+			st := cr.Status.Switchover
+			if tt.want == nil {
+				if st != nil {
+					t.Errorf("unexpected status %+v", st)
+				}
+				return
+			}
+			if st == nil || st.Target != tt.want.Target || st.State != tt.want.State || st.Message != tt.want.Message {
+				t.Errorf("got %+v, want %+v", st, tt.want)
+			}
+		})
+	}
+}
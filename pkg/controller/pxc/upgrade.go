@@ -5,10 +5,10 @@ import (
 	"crypto/md5"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-logr/logr"
 	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app"
 
 	"github.com/pkg/errors"
@@ -20,12 +20,27 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func (r *ReconcilePerconaXtraDBCluster) updatePod(sfs api.StatefulApp, podSpec *api.PodSpec, cr *api.PerconaXtraDBCluster, initContainers []corev1.Container) error {
+	if isPXC(sfs) && cr.Status.UpgradeRollback != nil {
+		if pxcUpdateFrozen(cr) {
+			return nil
+		}
+		r.logger(cr.Name, cr.Namespace).Info("PXC spec is changed after the update rollback, apply it")
+		cr.Status.UpgradeRollback = nil
+		cr.Status.RemoveCondition(api.ConditionUpgradeFailed)
+	}
+
+	// the new major version isn't applied until the upgrade is checked and backed up
+	if isPXC(sfs) && majorUpgradeHeld(cr) {
+		return nil
+	}
+
 	currentSet := sfs.StatefulSet()
 	newAnnotations := currentSet.Spec.Template.Annotations // need this step to save all new annotations that was set to currentSet in this reconcile loop
 	err := r.client.Get(context.TODO(), types.NamespacedName{Name: currentSet.Name, Namespace: currentSet.Namespace}, currentSet)
@@ -33,7 +48,7 @@ func (r *ReconcilePerconaXtraDBCluster) updatePod(sfs api.StatefulApp, podSpec *
 		return errors.Wrap(err, "failed to get sate")
 	}
 
-	currentSet.Spec.UpdateStrategy = sfs.UpdateStrategy(cr)
+	currentSet.Spec.UpdateStrategy = pxc.UpdateStrategy(sfs, podSpec, cr)
 
 	// support annotation adjustements
 	pxc.MergeTemplateAnnotations(currentSet, podSpec.Annotations)
@@ -51,6 +66,10 @@ func (r *ReconcilePerconaXtraDBCluster) updatePod(sfs api.StatefulApp, podSpec *
 		currentSet.Spec.Template.Labels[k] = v
 	}
 
+	if pxc.HasServingLabel(sfs, cr) {
+		currentSet.Spec.Template.Labels[api.ProxyServingLabel] = "true"
+	}
+
 	// embed DB configuration hash
 	// TODO: code duplication with deploy function
 	configHash := r.getConfigHash(cr, sfs)
@@ -209,21 +228,104 @@ func (r *ReconcilePerconaXtraDBCluster) updatePod(sfs api.StatefulApp, podSpec *
 }
 
 func (r *ReconcilePerconaXtraDBCluster) smartUpdate(sfs api.StatefulApp, cr *api.PerconaXtraDBCluster) error {
+	if cr.Spec.Pause {
+		return nil
+	}
+
 	if !isPXC(sfs) {
+		if (isHAproxy(sfs) || isProxySQL(sfs)) && cr.CompareVersionWith("1.9.0") >= 0 {
+			return r.proxySmartUpdate(sfs, cr)
+		}
 		return nil
 	}
 
-	if cr.Spec.Pause {
+	logger := r.logger(cr.Name, cr.Namespace)
+	set := sfs.StatefulSet()
+	opts := cr.Spec.UpgradeOptions
+
+	// the pods deleted by one of the previous reconciles have to be synced
+	// and online before the next ones are updated
+	if st := cr.Status.SmartUpdate; st != nil && len(st.Pods) > 0 && st.Revision == set.Status.UpdateRevision {
+		inProgress := make([]api.SmartUpdatePod, 0, len(st.Pods))
+		for i, pod := range st.Pods {
+			pod := pod
+			done, err := r.smartUpdateStep(cr, set, &pod)
+			if f, ok := err.(upgradeFailure); ok && cr.CompareVersionWith("1.9.0") >= 0 {
+				f.msg = "pod " + pod.Name + ": " + f.msg
+				return errors.Wrap(r.rollbackUpdate(cr, set, f), "rollback update")
+			}
+			if err != nil {
+				// the pods are checked again by the next reconcile, the update status
+				// is kept, so the canary of the revision still has to be approved
+				st.Pods = append(append(inProgress, pod), st.Pods[i+1:]...)
+				return errors.Wrapf(err, "failed to apply changes to pod %s", pod.Name)
+			}
+			if !done {
+				inProgress = append(inProgress, pod)
+				continue
+			}
+			logger.Info("pod is updated", "pod name", pod.Name)
+		}
+		st.Pods = inProgress
+		if len(st.Pods) > 0 {
+			return nil
+		}
+		if opts.PauseBetweenPods != nil && opts.PauseBetweenPods.Duration > 0 {
+			until := metav1.NewTime(time.Now().Add(opts.PauseBetweenPods.Duration))
+			st.PausedUntil = &until
+		}
+	}
+
+	if set.Status.UpdatedReplicas >= set.Status.Replicas {
+		if cr.Status.SmartUpdate != nil {
+			logger.Info("smart update finished")
+			cr.Status.SmartUpdate = nil
+		}
 		return nil
 	}
 
-	if sfs.StatefulSet().Status.UpdatedReplicas >= sfs.StatefulSet().Status.Replicas {
+	list := corev1.PodList{}
+	if err := r.client.List(context.TODO(),
+		&list,
+		&client.ListOptions{
+			Namespace:     set.Namespace,
+			LabelSelector: labels.SelectorFromSet(sfs.Labels()),
+		},
+	); err != nil {
+		return errors.Wrap(err, "get pod list")
+	}
+
+	if cr.Status.SmartUpdate == nil || cr.Status.SmartUpdate.Revision != set.Status.UpdateRevision {
+		logger.Info("statefulSet was changed, run smart update")
+		cr.Status.SmartUpdate = &api.SmartUpdateStatus{
+			Revision:      set.Status.UpdateRevision,
+			NeedsApproval: needCanary(opts.Canary, list.Items, set),
+		}
+	}
+	st := cr.Status.SmartUpdate
+
+	if st.PausedUntil != nil {
+		if time.Now().Before(st.PausedUntil.Time) {
+			return nil
+		}
+		st.PausedUntil = nil
+	}
+
+	st.WaitingApproval = st.NeedsApproval && st.Canary != "" && cr.Annotations[api.ApproveUpdateAnnotation] != st.Revision
+	if st.WaitingApproval {
+		logger.Info("waiting for the update approval", "canary", st.Canary, "annotation", api.ApproveUpdateAnnotation, "revision", st.Revision)
 		return nil
 	}
 
-	logger := r.logger(cr.Name, cr.Namespace)
+	if cr.RestartsDeferred() {
+		logger.Info("can't start/continue 'SmartUpdate': waiting for the maintenance window")
+		return nil
+	}
 
-	logger.Info("statefulSet was changed, run smart update")
+	if switchoverInProgress(cr) {
+		logger.Info("can't start/continue 'SmartUpdate': primary switchover is running")
+		return nil
+	}
 
 	running, err := r.isBackupRunning(cr)
 	if err != nil {
@@ -235,157 +337,227 @@ func (r *ReconcilePerconaXtraDBCluster) smartUpdate(sfs api.StatefulApp, cr *api
 		return nil
 	}
 
-	if sfs.StatefulSet().Status.ReadyReplicas < sfs.StatefulSet().Status.Replicas {
+	if set.Status.ReadyReplicas < set.Status.Replicas {
 		logger.Info("can't start/continue 'SmartUpdate': waiting for all replicas are ready")
 		return nil
 	}
 
-	list := corev1.PodList{}
-	if err := r.client.List(context.TODO(),
-		&list,
-		&client.ListOptions{
-			Namespace:     sfs.StatefulSet().Namespace,
-			LabelSelector: labels.SelectorFromSet(sfs.Labels()),
-		},
-	); err != nil {
-		return errors.Wrap(err, "get pod list")
-	}
-
 	primary, err := r.getPrimaryPod(cr)
 	if err != nil {
 		return errors.Wrap(err, "get primary pod")
 	}
 	for _, pod := range list.Items {
-		if pod.Status.PodIP == primary || pod.Name == primary {
-			primary = fmt.Sprintf("%s.%s.%s", pod.Name, sfs.StatefulSet().Name, sfs.StatefulSet().Namespace)
+		if pod.Status.PodIP == primary || pod.Name == primary ||
+			strings.HasPrefix(primary, fmt.Sprintf("%s.%s.%s", pod.Name, set.Name, set.Namespace)) {
+			primary = pod.Name
 			break
 		}
 	}
 
-	logger.Info("primary pod", "pod name", primary)
-
-	waitLimit := 2 * 60 * 60 // 2 hours
-	if cr.Spec.PXC.LivenessInitialDelaySeconds != nil {
-		waitLimit = int(*cr.Spec.PXC.LivenessInitialDelaySeconds)
+	maxPods := opts.MaxUnavailablePods(set.Status.Replicas)
+	// validation checks maxUnavailable against the spec size, the running cluster can be smaller
+	if n := safeMaxUnavailable(maxPods, set.Status.Replicas); n < maxPods {
+		logger.Info("maxUnavailable is reduced to keep synced secondary pods with the primary",
+			"maxUnavailable", maxPods, "reduced", n, "replicas", set.Status.Replicas)
+		maxPods = n
+	}
+	canary := st.NeedsApproval && st.Canary == ""
+	if canary {
+		maxPods = 1
 	}
 
-	sort.Slice(list.Items, func(i, j int) bool {
-		return list.Items[i].Name > list.Items[j].Name
-	})
+	partition := int32(0)
+	if opts.Partition != nil {
+		partition = *opts.Partition
+	}
 
-	var primaryPod corev1.Pod
-	for _, pod := range list.Items {
-		pod := pod
-		if strings.HasPrefix(primary, fmt.Sprintf("%s.%s.%s", pod.Name, sfs.StatefulSet().Name, sfs.StatefulSet().Namespace)) {
-			primaryPod = pod
+	pods := podsToUpdate(list.Items, primary, set.Status.UpdateRevision, partition, maxPods)
+	for _, pod := range pods {
+		if pod.Name == primary {
+			logger.Info("apply changes to primary pod", "pod name", pod.Name)
 		} else {
 			logger.Info("apply changes to secondary pod", "pod name", pod.Name)
-			if err := r.applyNWait(cr, sfs.StatefulSet(), &pod, waitLimit); err != nil {
-				return errors.Wrap(err, "failed to apply changes")
+		}
+		if err := r.client.Delete(context.TODO(), &pod); err != nil {
+			return errors.Wrap(err, "failed to delete pod")
+		}
+
+		st.Pods = append(st.Pods, api.SmartUpdatePod{
+			Name:           pod.Name,
+			Phase:          api.SmartUpdateRestarting,
+			PhaseStartedAt: metav1.Now(),
+		})
+		if canary {
+			st.Canary = pod.Name
+		}
+	}
+
+	return nil
+}
+
+// needCanary checks if the update of the revision has to be stopped after the first pod.
+// It's decided once when the update starts.
+func needCanary(canary api.UpgradeCanary, pods []corev1.Pod, sfs *appsv1.StatefulSet) bool {
+	switch canary {
+	case api.UpgradeCanaryAlways:
+	case api.UpgradeCanaryMajor:
+		newImage := ""
+		for _, c := range sfs.Spec.Template.Spec.Containers {
+			if c.Name == "pxc" {
+				newImage = c.Image
+			}
+		}
+		major := false
+		// the pods restarted with the new revision already don't make the approval optional
+		for _, pod := range pods {
+			for _, c := range pod.Spec.Containers {
+				if c.Name == "pxc" && imageMajorVersion(c.Image) != imageMajorVersion(newImage) {
+					major = true
+				}
 			}
 		}
+		return major
+	default:
+		return false
 	}
 
-	logger.Info("apply changes to primary pod", "pod name", primaryPod.Name)
-	if err := r.applyNWait(cr, sfs.StatefulSet(), &primaryPod, waitLimit); err != nil {
-		return errors.Wrap(err, "failed to apply changes")
+	return true
+}
+
+// imageMajorVersion returns the major version from the image tag, e.g. 8.0 for percona-xtradb-cluster:8.0.22-13.1
+func imageMajorVersion(image string) string {
+	tag := image
+	if i := strings.LastIndex(image, ":"); i >= 0 {
+		tag = image[i+1:]
+	}
+	parts := strings.SplitN(tag, ".", 3)
+	if len(parts) < 2 {
+		return tag
 	}
 
-	logger.Info("smart update finished")
+	return parts[0] + "." + parts[1]
+}
 
-	return nil
+// safeMaxUnavailable caps the number of pods restarted together, so the rest of the nodes
+// keep the quorum and at least one synced secondary stays with the primary
+func safeMaxUnavailable(n int, size int32) int {
+	max := int(size-1) / 2
+	if max < 1 {
+		max = 1
+	}
+	if n > max {
+		return max
+	}
+	return n
 }
 
-func (r *ReconcilePerconaXtraDBCluster) applyNWait(cr *api.PerconaXtraDBCluster, sfs *appsv1.StatefulSet, pod *corev1.Pod, waitLimit int) error {
-	logger := r.logger(cr.Name, cr.Namespace)
+// podsToUpdate returns up to max pods that don't run the update revision.
+// Secondary pods are updated first starting from the highest ordinal, the primary is the last
+// and it's updated alone. Pods with the ordinal lower than the partition aren't updated.
+func podsToUpdate(pods []corev1.Pod, primary, revision string, partition int32, max int) []corev1.Pod {
+	sort.Slice(pods, func(i, j int) bool {
+		return podOrdinal(pods[i].Name) > podOrdinal(pods[j].Name)
+	})
 
-	if pod.ObjectMeta.Labels["controller-revision-hash"] == sfs.Status.UpdateRevision {
-		logger.Info("pod is already updated", "pod name", pod.Name)
-	} else {
-		if err := r.client.Delete(context.TODO(), pod); err != nil {
-			return errors.Wrap(err, "failed to delete pod")
+	var primaryPod *corev1.Pod
+	list := []corev1.Pod{}
+	for i := range pods {
+		pod := pods[i]
+		if pod.Labels["controller-revision-hash"] == revision || podOrdinal(pod.Name) < int(partition) {
+			continue
+		}
+		if pod.Name == primary {
+			primaryPod = &pods[i]
+			continue
+		}
+		if len(list) < max {
+			list = append(list, pod)
 		}
 	}
 
-	if err := r.waitPodRestart(sfs.Status.UpdateRevision, pod, waitLimit, logger); err != nil {
-		return errors.Wrap(err, "failed to wait pod")
+	if len(list) == 0 && primaryPod != nil {
+		list = append(list, *primaryPod)
 	}
 
-	if err := r.waitPXCSynced(cr, pod.Name+"."+cr.Name+"-pxc."+cr.Namespace, waitLimit); err != nil {
-		return errors.Wrap(err, "failed to wait pxc sync")
+	return list
+}
+
+func podOrdinal(name string) int {
+	i := strings.LastIndex(name, "-")
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return -1
 	}
 
-	if err := r.waitUntilOnline(cr, sfs.Name, pod, waitLimit, logger); err != nil {
-		return errors.Wrap(err, "failed to wait pxc status")
+	return n
+}
+
+// smartUpdateStep checks if the pod passed the current phase of the update and moves it
+// to the next one. It returns true when the pod is running the new revision and it's online.
+func (r *ReconcilePerconaXtraDBCluster) smartUpdateStep(cr *api.PerconaXtraDBCluster, sfs *appsv1.StatefulSet, pod *api.SmartUpdatePod) (bool, error) {
+	waitLimit := 2 * time.Hour
+	if cr.Spec.PXC.LivenessInitialDelaySeconds != nil {
+		waitLimit = time.Duration(*cr.Spec.PXC.LivenessInitialDelaySeconds) * time.Second
 	}
 
-	return nil
+	for {
+		if time.Since(pod.PhaseStartedAt.Time) > waitLimit {
+			return false, upgradeFailure{reason: "Timeout", msg: fmt.Sprintf("reach pod wait limit in %s phase", pod.Phase)}
+		}
+
+		var ok bool
+		var err error
+		next := pod.Phase
+		switch pod.Phase {
+		case api.SmartUpdateRestarting:
+			ok, err = r.isPodRestarted(sfs.Status.UpdateRevision, cr.Namespace, pod.Name)
+			next = api.SmartUpdateSyncing
+		case api.SmartUpdateSyncing:
+			ok, err = r.isPXCSynced(cr, pod.Name+"."+cr.Name+"-pxc."+cr.Namespace)
+			next = api.SmartUpdateWaitingOnline
+		case api.SmartUpdateWaitingOnline:
+			ok, err = r.isPodOnline(cr, sfs.Name, pod)
+			next = ""
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+		if next == "" {
+			return true, nil
+		}
+
+		pod.Phase = next
+		pod.PhaseStartedAt = metav1.Now()
+	}
 }
 
-func (r *ReconcilePerconaXtraDBCluster) waitUntilOnline(cr *api.PerconaXtraDBCluster, sfsName string, pod *corev1.Pod, waitLimit int, logger logr.Logger) error {
+func (r *ReconcilePerconaXtraDBCluster) isPodOnline(cr *api.PerconaXtraDBCluster, sfsName string, pod *api.SmartUpdatePod) (bool, error) {
+	// HAProxy checks the nodes every few seconds
 	if cr.Spec.HAProxy != nil && cr.Spec.HAProxy.Enabled {
-		time.Sleep(5 * time.Second)
-		return nil
+		return time.Since(pod.PhaseStartedAt.Time) >= 5*time.Second, nil
 	}
 
 	database, err := r.proxyDB(cr)
 	if err != nil {
-		return errors.Wrap(err, "failed to get proxySQL db")
+		return false, errors.Wrap(err, "failed to get proxySQL db")
 	}
 
 	defer database.Close()
 
 	podNamePrefix := fmt.Sprintf("%s.%s.%s", pod.Name, sfsName, cr.Namespace)
-
-	return retry(time.Second*10, time.Duration(waitLimit)*time.Second,
-		func() (bool, error) {
-			statuses, err := database.Status(podNamePrefix, pod.Name+"."+cr.Name+"-pxc."+cr.Namespace)
-			if err != nil && err != queries.ErrNotFound {
-				return false, errors.Wrap(err, "failed to get status")
-			}
-
-			for _, status := range statuses {
-				if status != "ONLINE" {
-					return false, nil
-				}
-			}
-
-			logger.Info("pod is online", "pod name", pod.Name)
-			return true, nil
-		})
-}
-
-// retry runs func "f" every "in" time until "limit" is reached
-// it also doesn't have an extra tail wait after the limit is reached
-// and f func runs first time instantly
-func retry(in, limit time.Duration, f func() (bool, error)) error {
-	fdone, err := f()
-	if err != nil {
-		return err
+	statuses, err := database.Status(podNamePrefix, pod.Name+"."+cr.Name+"-pxc."+cr.Namespace)
+	if err != nil && err != queries.ErrNotFound {
+		return false, errors.Wrap(err, "failed to get status")
 	}
-	if fdone {
-		return nil
-	}
-
-	done := time.NewTimer(limit)
-	defer done.Stop()
-	tk := time.NewTicker(in)
-	defer tk.Stop()
 
-	for {
-		select {
-		case <-done.C:
-			return errors.New("reach pod wait limit")
-		case <-tk.C:
-			fdone, err := f()
-			if err != nil {
-				return err
-			}
-			if fdone {
-				return nil
-			}
+	for _, status := range statuses {
+		if status != "ONLINE" {
+			return false, nil
 		}
 	}
+
+	r.logger(cr.Name, cr.Namespace).Info("pod is online", "pod name", pod.Name)
+	return true, nil
 }
 
 func (r *ReconcilePerconaXtraDBCluster) proxyDB(cr *api.PerconaXtraDBCluster) (queries.Database, error) {
@@ -455,7 +627,7 @@ func (r *ReconcilePerconaXtraDBCluster) getPrimaryPod(cr *api.PerconaXtraDBClust
 	return database.PrimaryHost()
 }
 
-func (r *ReconcilePerconaXtraDBCluster) waitPXCSynced(cr *api.PerconaXtraDBCluster, host string, waitLimit int) error {
+func (r *ReconcilePerconaXtraDBCluster) isPXCSynced(cr *api.PerconaXtraDBCluster, host string) (bool, error) {
 	user := "root"
 	secrets := cr.Spec.SecretsName
 	port := int32(3306)
@@ -466,52 +638,48 @@ func (r *ReconcilePerconaXtraDBCluster) waitPXCSynced(cr *api.PerconaXtraDBClust
 
 	database, err := queries.New(r.client, cr.Namespace, secrets, user, host, port)
 	if err != nil {
-		return errors.Wrap(err, "failed to access PXC database")
+		// the node may be not accepting connections yet
+		r.logger(cr.Name, cr.Namespace).Info("can't access PXC database", "host", host, "error", err.Error())
+		return false, nil
 	}
 
 	defer database.Close()
 
-	return retry(time.Second*10, time.Duration(waitLimit)*time.Second,
-		func() (bool, error) {
-			state, err := database.WsrepLocalStateComment()
-			if err != nil {
-				return false, errors.Wrap(err, "failed to get wsrep local state")
-			}
-
-			if state == "Synced" {
-				return true, nil
-			}
+	state, err := database.WsrepLocalStateComment()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get wsrep local state")
+	}
 
-			return false, nil
-		})
+	return state == "Synced", nil
 }
 
-func (r *ReconcilePerconaXtraDBCluster) waitPodRestart(updateRevision string, pod *corev1.Pod, waitLimit int, logger logr.Logger) error {
-	return retry(time.Second*10, time.Duration(waitLimit)*time.Second,
-		func() (bool, error) {
-			err := r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, pod)
-			if err != nil && !k8serrors.IsNotFound(err) {
-				return false, err
-			}
-
-			ready := false
-			for _, container := range pod.Status.ContainerStatuses {
-				if container.Name == "pxc" {
-					ready = container.Ready
-				}
-			}
+func (r *ReconcilePerconaXtraDBCluster) isPodRestarted(updateRevision, namespace, podName string) (bool, error) {
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: namespace}, pod)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
 
-			if pod.Status.Phase == corev1.PodFailed {
-				return false, errors.Errorf("pod %s is in failed phase", pod.Name)
-			}
+	ready := false
+	for _, container := range pod.Status.ContainerStatuses {
+		if container.Name != "pxc" {
+			continue
+		}
+		ready = container.Ready
+		if w := container.State.Waiting; w != nil && w.Reason == "CrashLoopBackOff" &&
+			pod.ObjectMeta.Labels["controller-revision-hash"] == updateRevision {
+			return false, upgradeFailure{reason: "CrashLoopBackOff", msg: w.Message}
+		}
+	}
 
-			if pod.Status.Phase == corev1.PodRunning && pod.ObjectMeta.Labels["controller-revision-hash"] == updateRevision && ready {
-				logger.Info("pod is running", "pod name", pod.Name)
-				return true, nil
-			}
+	if pod.Status.Phase == corev1.PodFailed {
+		return false, upgradeFailure{reason: "PodFailed", msg: "pod is in failed phase"}
+	}
 
-			return false, nil
-		})
+	return pod.Status.Phase == corev1.PodRunning && pod.ObjectMeta.Labels["controller-revision-hash"] == updateRevision && ready, nil
 }
 
 func isPXC(sfs api.StatefulApp) bool {
@@ -559,13 +727,14 @@ func (r *ReconcilePerconaXtraDBCluster) isRestoreRunning(clusterName, namespace
 	}
 
 	for _, v := range restoreList.Items {
-		if v.Spec.PXCCluster != clusterName {
+		if v.Spec.PXCCluster != clusterName || v.Spec.DryRun {
 			continue
 		}
 
 		switch v.Status.State {
 		case api.RestoreStarting, api.RestoreStopCluster, api.RestoreRestore,
-			api.RestoreStartCluster, api.RestorePITR:
+			api.RestoreStartCluster, api.RestorePreparePITR, api.RestorePITR,
+			api.RestoreSnapshot, api.RestoreCancelling, api.RestoreRollingBack, api.RestoreSwitchover:
 			return true, nil
 		}
 	}
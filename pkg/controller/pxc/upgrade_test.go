@@ -0,0 +1,147 @@
+package pxc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func newRevisionPods(image string, revisions ...string) []corev1.Pod {
+	list := make([]corev1.Pod, 0, len(revisions))
+	for i, rev := range revisions {
+		list = append(list, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   fmt.Sprintf("cluster1-pxc-%d", i),
+				Labels: map[string]string{"controller-revision-hash": rev},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "pxc", Image: image}}},
+		})
+	}
+	return list
+}
+
+func TestSafeMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		n    int
+		size int32
+		want int
+	}{
+		{1, 1, 1},
+		{1, 3, 1},
+		{2, 3, 1},
+		{2, 5, 2},
+		{3, 5, 2},
+		{3, 7, 3},
+	}
+
+	for _, tt := range tests {
+		if got := safeMaxUnavailable(tt.n, tt.size); got != tt.want {
+			t.Errorf("safeMaxUnavailable(%d, %d) = %d, want %d", tt.n, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestPodsToUpdate(t *testing.T) {
+	tests := map[string]struct {
+		pods      []corev1.Pod
+		primary   string
+		partition int32
+		max       int
+		want      string
+	}{
+		"highest secondary first": {newRevisionPods("", "old", "old", "old"), "cluster1-pxc-0", 0, 1, "cluster1-pxc-2"},
+		"skip primary":            {newRevisionPods("", "old", "old", "old"), "cluster1-pxc-2", 0, 1, "cluster1-pxc-1"},
+		"skip updated":            {newRevisionPods("", "old", "old", "new"), "cluster1-pxc-0", 0, 1, "cluster1-pxc-1"},
+		"primary last":            {newRevisionPods("", "new", "old", "new"), "cluster1-pxc-1", 0, 2, "cluster1-pxc-1"},
+		"all updated":             {newRevisionPods("", "new", "new", "new"), "cluster1-pxc-0", 0, 1, ""},
+		"max unavailable":         {newRevisionPods("", "old", "old", "old", "old", "old"), "cluster1-pxc-0", 0, 2, "cluster1-pxc-4,cluster1-pxc-3"},
+		"primary alone":           {newRevisionPods("", "old", "old", "new"), "cluster1-pxc-0", 0, 2, "cluster1-pxc-1"},
+		"partition":               {newRevisionPods("", "old", "old", "new"), "cluster1-pxc-2", 2, 1, ""},
+		"ordinal order":           {newRevisionPods("", "old", "old", "old", "old", "old", "old", "old", "old", "old", "old", "old"), "cluster1-pxc-0", 0, 1, "cluster1-pxc-10"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			names := []string{}
+			for _, pod := range podsToUpdate(tt.pods, tt.primary, "new", tt.partition, tt.max) {
+				names = append(names, pod.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("podsToUpdate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedCanary(t *testing.T) {
+	sfs := &appsv1.StatefulSet{}
+	sfs.Spec.Template.Spec.Containers = []corev1.Container{{Name: "pxc", Image: "percona/percona-xtradb-cluster:8.0.22-13.1"}}
+	sfs.Status.UpdateRevision = "new"
+
+	tests := map[string]struct {
+		canary api.UpgradeCanary
+		pods   []corev1.Pod
+		want   bool
+	}{
+		"never":           {api.UpgradeCanaryNever, newRevisionPods("percona/percona-xtradb-cluster:5.7.33-31.49", "old", "old", "old"), false},
+		"always":          {api.UpgradeCanaryAlways, newRevisionPods("percona/percona-xtradb-cluster:8.0.21-12.1", "old", "old", "old"), true},
+		"major":           {api.UpgradeCanaryMajor, newRevisionPods("percona/percona-xtradb-cluster:5.7.33-31.49", "old", "old", "old"), true},
+		"minor":           {api.UpgradeCanaryMajor, newRevisionPods("percona/percona-xtradb-cluster:8.0.21-12.1", "old", "old", "old"), false},
+		"pod restarted":   {api.UpgradeCanaryAlways, newRevisionPods("percona/percona-xtradb-cluster:8.0.21-12.1", "old", "old", "new"), true},
+		"default is none": {"", newRevisionPods("percona/percona-xtradb-cluster:5.7.33-31.49", "old", "old", "old"), false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := needCanary(tt.canary, tt.pods, sfs); got != tt.want {
+				t.Errorf("needCanary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeInProgressHeldPods(t *testing.T) {
+	partition := int32(2)
+	tests := map[string]struct {
+		partition *int32
+		approval  bool
+		updated   int32
+		want      bool
+	}{
+		"not updated":           {nil, false, 1, true},
+		"updated":               {nil, false, 3, false},
+		"held by partition":     {&partition, false, 1, false},
+		"updating to partition": {&partition, false, 0, true},
+		"waiting approval":      {nil, true, 1, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Spec.UpdateStrategy = api.SmartUpdateStatefulSetStrategyType
+			cr.Spec.UpgradeOptions.Partition = tt.partition
+			cr.Status.SmartUpdate = &api.SmartUpdateStatus{WaitingApproval: tt.approval}
+
+			sfs := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster1-pxc", Namespace: "ns"},
+				Status:     appsv1.StatefulSetStatus{Replicas: 3, UpdatedReplicas: tt.updated},
+			}
+			r := buildFakeClient([]runtime.Object{sfs})
+
+			got, err := r.upgradeInProgress(cr, "pxc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("upgradeInProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
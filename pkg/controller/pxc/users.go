@@ -81,6 +81,10 @@ func (r *ReconcilePerconaXtraDBCluster) reconcileUsers(cr *api.PerconaXtraDBClus
 				return nil, nil, errors.Wrap(err, "manage xtrabackup user")
 			}
 		}
+		err = r.manageReplicasUser(cr, &sysUsersSecretObj, &internalSysSecretObj)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "manage replicas user")
+		}
 	}
 
 	if cr.Status.Status != api.AppStateReady {
@@ -455,6 +459,59 @@ func (r *ReconcilePerconaXtraDBCluster) manageOperatorAdminUser(cr *api.PerconaX
 	return nil
 }
 
+// manageReplicasUser creates the user the read replicas replicate with.
+// The password is generated if the users secret doesn't have it
+// and is changed if it's changed in the secret.
+func (r *ReconcilePerconaXtraDBCluster) manageReplicasUser(cr *api.PerconaXtraDBCluster, sysUsersSecretObj, internalSysSecretObj *corev1.Secret) error {
+	if !cr.ReplicasEnabled() {
+		return nil
+	}
+
+	pass, existInSys := sysUsersSecretObj.Data[api.ReplicasUser]
+	if existInSys && bytes.Equal(pass, internalSysSecretObj.Data[api.ReplicasUser]) {
+		return nil
+	}
+	if !existInSys {
+		var err error
+		pass, err = generatePass()
+		if err != nil {
+			return errors.Wrap(err, "generate password")
+		}
+	}
+
+	um, err := users.NewManager(cr.Name+"-pxc-unready."+cr.Namespace+":33062", "root", string(internalSysSecretObj.Data["root"]))
+	if err != nil {
+		return errors.Wrap(err, "new users manager")
+	}
+	defer um.Close()
+
+	err = um.CreateReplicationUser(api.ReplicasUser, string(pass))
+	if err != nil {
+		return errors.Wrap(err, "create replicas user")
+	}
+
+	if !existInSys {
+		if sysUsersSecretObj.Data == nil {
+			sysUsersSecretObj.Data = make(map[string][]byte)
+		}
+		sysUsersSecretObj.Data[api.ReplicasUser] = pass
+		err = r.client.Update(context.TODO(), sysUsersSecretObj)
+		if err != nil {
+			return errors.Wrap(err, "update sys users secret")
+		}
+	}
+	if internalSysSecretObj.Data == nil {
+		internalSysSecretObj.Data = make(map[string][]byte)
+	}
+	internalSysSecretObj.Data[api.ReplicasUser] = pass
+	err = r.client.Update(context.TODO(), internalSysSecretObj)
+	if err != nil {
+		return errors.Wrap(err, "update internal users secret")
+	}
+
+	return nil
+}
+
 func sysUsersSecretDataChanged(newHash string, usersSecret *corev1.Secret) (bool, error) {
 	secretData, err := json.Marshal(usersSecret.Data)
 	if err != nil {
@@ -131,7 +131,7 @@ func (r *ReconcilePerconaXtraDBCluster) ensurePXCVersion(cr *api.PerconaXtraDBCl
 		return errors.New("cluster is not ready")
 	}
 
-	newVersion, err := vs.GetExactVersion(cr, cr.Spec.UpgradeOptions.VersionServiceEndpoint, versionMeta{
+	newVersion, err := r.getVersion(cr, vs, versionMeta{
 		Apply:               cr.Spec.UpgradeOptions.Apply,
 		Platform:            string(cr.Spec.Platform),
 		KubeVersion:         r.serverVersion.Info.GitVersion,
@@ -246,6 +246,12 @@ func (r *ReconcilePerconaXtraDBCluster) fetchVersionFromPXC(cr *api.PerconaXtraD
 		return nil
 	}
 
+	// the pods run the previous image after the update rollback,
+	// until the major version upgrade is allowed or the maintenance window opens
+	if cr.Status.UpgradeRollback != nil || majorUpgradeHeld(cr) || cr.RestartsDeferred() {
+		return nil
+	}
+
 	upgradeInProgress, err := r.upgradeInProgress(cr, "pxc")
 	if err != nil {
 		return errors.Wrap(err, "check pxc upgrade progress")
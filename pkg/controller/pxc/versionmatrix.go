@@ -0,0 +1,191 @@
+package pxc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	v "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/versionserviceclient/models"
+)
+
+const defaultVersionMatrixKey = "matrix.json"
+
+type cachedVersion struct {
+	version DepVersion
+	time    time.Time
+}
+
+// getVersion returns the versions for the apply value of the upgrade options. They are resolved against
+// the offline version matrix if it's set. Otherwise the version service is asked, and the last successful
+// answer is used if the version service can't be reached.
+func (r *ReconcilePerconaXtraDBCluster) getVersion(cr *api.PerconaXtraDBCluster, vs VersionService, vm versionMeta) (DepVersion, error) {
+	if cr.Spec.UpgradeOptions.VersionMatrix != nil {
+		matrix, err := r.readVersionMatrix(cr)
+		if err != nil {
+			return DepVersion{}, errors.Wrap(err, "read version matrix")
+		}
+		return depVersion(cr, matrix, matrixVersionPicker(vm.Apply))
+	}
+
+	endpoint := cr.Spec.UpgradeOptions.VersionServiceEndpoint
+	key := strings.Join([]string{cr.Namespace, cr.Name, endpoint, strings.ToLower(vm.Apply)}, "/")
+
+	dv, err := vs.GetExactVersion(cr, endpoint, vm)
+	if err != nil {
+		cached, ok := r.versionCache.Load(key)
+		if !ok {
+			return DepVersion{}, err
+		}
+		c := cached.(cachedVersion)
+		r.logger(cr.Name, cr.Namespace).Info("version service is unavailable, use the previous answer",
+			"error", err.Error(), "answered at", c.time.Format(time.RFC3339))
+		return c.version, nil
+	}
+
+	r.versionCache.Store(key, cachedVersion{version: dv, time: time.Now()})
+
+	return dv, nil
+}
+
+func (r *ReconcilePerconaXtraDBCluster) readVersionMatrix(cr *api.PerconaXtraDBCluster) (*models.VersionVersionMatrix, error) {
+	src := cr.Spec.UpgradeOptions.VersionMatrix
+
+	var data []byte
+	switch {
+	case src.ConfigMap != nil:
+		cm := &corev1.ConfigMap{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: src.ConfigMap.Name, Namespace: cr.Namespace}, cm)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get configmap %s", src.ConfigMap.Name)
+		}
+		key := src.ConfigMap.Key
+		if key == "" {
+			key = defaultVersionMatrixKey
+		}
+		d, ok := cm.Data[key]
+		if !ok {
+			return nil, errors.Errorf("configmap %s has no %s key", src.ConfigMap.Name, key)
+		}
+		data = []byte(d)
+	case src.File != "":
+		d, err := ioutil.ReadFile(src.File)
+		if err != nil {
+			return nil, errors.Wrap(err, "read file")
+		}
+		data = d
+	default:
+		return nil, errors.New("neither configMap nor file is set")
+	}
+
+	return parseVersionMatrix(data)
+}
+
+// parseVersionMatrix accepts the matrix itself or the whole answer of the version service
+func parseVersionMatrix(data []byte) (*models.VersionVersionMatrix, error) {
+	resp := models.VersionOperatorResponse{}
+	err := json.Unmarshal(data, &resp)
+	if err == nil && len(resp.Versions) > 0 && resp.Versions[0].Matrix != nil {
+		return resp.Versions[0].Matrix, nil
+	}
+
+	matrix := &models.VersionVersionMatrix{}
+	err = json.Unmarshal(data, matrix)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal")
+	}
+	if len(matrix.Pxc) == 0 {
+		return nil, errors.New("matrix has no pxc versions")
+	}
+
+	return matrix, nil
+}
+
+// matrixVersionPicker resolves the apply value the way the version service does:
+// recommended, latest, <major>-recommended, <major>-latest or the exact PXC version.
+// The other products get the recommended version, the backup matches the PXC major version.
+func matrixVersionPicker(apply string) versionPicker {
+	return func(product string, versions map[string]models.VersionVersion, pxcVersion string) (string, error) {
+		if len(versions) == 0 {
+			return "", errors.Errorf("no %s versions in the matrix", product)
+		}
+
+		if product == "backup" {
+			return bestVersion(versions, backupMajorVersion(pxcVersion), false)
+		}
+		if product != "pxc" {
+			return bestVersion(versions, "", false)
+		}
+
+		a := strings.ToLower(apply)
+		switch {
+		case a == "recommended" || a == "latest":
+			return bestVersion(versions, "", a == "latest")
+		case strings.HasSuffix(a, "-recommended"):
+			return bestVersion(versions, strings.TrimSuffix(a, "-recommended"), false)
+		case strings.HasSuffix(a, "-latest"):
+			return bestVersion(versions, strings.TrimSuffix(a, "-latest"), true)
+		}
+
+		if ver, ok := versions[apply]; ok && ver.Status != models.VersionStatusDisabled {
+			return apply, nil
+		}
+		return "", errors.Errorf("pxc version %s isn't in the matrix", apply)
+	}
+}
+
+// backupMajorVersion is the xtrabackup version prefix for the PXC version
+func backupMajorVersion(pxcVersion string) string {
+	if strings.HasPrefix(pxcVersion, "5.7.") {
+		return "2.4"
+	}
+
+	return "8.0"
+}
+
+// bestVersion returns the highest recommended version with the prefix.
+// If latest is set or there are no recommended versions the highest available one is returned.
+func bestVersion(versions map[string]models.VersionVersion, prefix string, latest bool) (string, error) {
+	best, bestRecommended := "", ""
+	for ver, info := range versions {
+		if info.Status == models.VersionStatusDisabled {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(ver, prefix+".") {
+			continue
+		}
+		if best == "" || versionLess(best, ver) {
+			best = ver
+		}
+		if info.Status == models.VersionStatusRecommended && (bestRecommended == "" || versionLess(bestRecommended, ver)) {
+			bestRecommended = ver
+		}
+	}
+
+	if !latest && bestRecommended != "" {
+		return bestRecommended, nil
+	}
+	if best == "" {
+		return "", fmt.Errorf("no versions matching %q", prefix)
+	}
+
+	return best, nil
+}
+
+func versionLess(a, b string) bool {
+	va, errA := v.NewVersion(a)
+	vb, errB := v.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+
+	return va.LessThan(vb)
+}
@@ -0,0 +1,121 @@
+package pxc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+const testVersionMatrix = `{
+  "pxc": {
+    "5.7.33-31.49": {"imagePath": "pxc:5.7.33", "status": "recommended"},
+    "5.7.34-31.51": {"imagePath": "pxc:5.7.34", "status": "available"},
+    "8.0.22-13.1": {"imagePath": "pxc:8.0.22", "status": "recommended"},
+    "8.0.23-14.1": {"imagePath": "pxc:8.0.23", "status": "available"},
+    "8.0.25-15.1": {"imagePath": "pxc:8.0.25", "status": "disabled"}
+  },
+  "backup": {
+    "2.4.23": {"imagePath": "xtrabackup:2.4.23", "status": "recommended"},
+    "8.0.22": {"imagePath": "xtrabackup:8.0.22", "status": "recommended"},
+    "8.0.23": {"imagePath": "xtrabackup:8.0.23", "status": "available"}
+  },
+  "pmm": {"2.18.0": {"imagePath": "pmm:2.18.0", "status": "recommended"}},
+  "proxysql": {"2.0.18": {"imagePath": "proxysql:2.0.18", "status": "recommended"}},
+  "haproxy": {"2.3.10": {"imagePath": "haproxy:2.3.10", "status": "recommended"}},
+  "logCollector": {"1.8.0": {"imagePath": "fluentbit:1.8.0", "status": "recommended"}}
+}`
+
+type fakeVersionService struct {
+	version DepVersion
+	err     error
+}
+
+func (vs fakeVersionService) GetExactVersion(cr *api.PerconaXtraDBCluster, endpoint string, vm versionMeta) (DepVersion, error) {
+	return vs.version, vs.err
+}
+
+func TestVersionMatrix(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "versions", Namespace: "ns"},
+		Data:       map[string]string{defaultVersionMatrixKey: testVersionMatrix},
+	}
+
+	tests := map[string]struct {
+		apply  string
+		pxc    string
+		backup string
+		err    bool
+	}{
+		"recommended":     {"recommended", "pxc:8.0.22", "xtrabackup:8.0.22", false},
+		"latest":          {"latest", "pxc:8.0.23", "xtrabackup:8.0.22", false},
+		"5.7 recommended": {"5.7-recommended", "pxc:5.7.33", "xtrabackup:2.4.23", false},
+		"5.7 latest":      {"5.7-Latest", "pxc:5.7.34", "xtrabackup:2.4.23", false},
+		"exact":           {"8.0.23-14.1", "pxc:8.0.23", "xtrabackup:8.0.22", false},
+		"disabled":        {"8.0.25-15.1", "", "", true},
+		"unknown":         {"8.0.99-1.1", "", "", true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Spec.CRVersion = "1.9.0"
+			cr.Spec.UpgradeOptions.VersionMatrix = &api.VersionMatrixSource{
+				ConfigMap: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "versions"}},
+			}
+
+			r := buildFakeClient([]runtime.Object{cm})
+			dv, err := r.getVersion(cr, fakeVersionService{err: errors.New("unreachable")}, versionMeta{Apply: tt.apply})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", dv)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dv.PXCImage != tt.pxc || dv.BackupImage != tt.backup || dv.HAProxyImage != "haproxy:2.3.10" || dv.LogCollectorImage != "fluentbit:1.8.0" {
+				t.Errorf("unexpected versions %+v", dv)
+			}
+		})
+	}
+}
+
+func TestVersionServiceCache(t *testing.T) {
+	cr := newCR("cluster1", "ns")
+	cr.Spec.UpgradeOptions.Apply = "recommended"
+
+	r := buildFakeClient(nil)
+	r.log = zapr.NewLogger(zap.NewNop())
+
+	_, err := r.getVersion(cr, fakeVersionService{err: errors.New("unreachable")}, versionMeta{Apply: "recommended"})
+	if err == nil {
+		t.Fatal("expected error without the previous answer")
+	}
+
+	answer := DepVersion{PXCImage: "pxc:8.0.22", PXCVersion: "8.0.22-13.1"}
+	_, err = r.getVersion(cr, fakeVersionService{version: answer}, versionMeta{Apply: "recommended"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dv, err := r.getVersion(cr, fakeVersionService{err: errors.New("unreachable")}, versionMeta{Apply: "recommended"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dv != answer {
+		t.Errorf("got %+v, want the previous answer %+v", dv, answer)
+	}
+
+	_, err = r.getVersion(cr, fakeVersionService{err: errors.New("unreachable")}, versionMeta{Apply: "latest"})
+	if err == nil {
+		t.Fatal("the answer for another apply value shouldn't be used")
+	}
+}
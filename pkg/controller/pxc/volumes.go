@@ -0,0 +1,288 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+func volumeExpansionEnabled(cr *api.PerconaXtraDBCluster) bool {
+	return cr.CompareVersionWith("1.9.0") >= 0 && cr.Spec.VolumeExpansion != nil && cr.Spec.VolumeExpansion.Enabled
+}
+
+// reconcilePersistentVolumes resizes the data volumes of PXC and ProxySQL
+// if the requested storage size in the volumeSpec grows.
+// The volume claim templates of the statefulsets are immutable, so the PVCs are resized
+// one by one and the statefulset is recreated afterwards if it's allowed.
+func (r *ReconcilePerconaXtraDBCluster) reconcilePersistentVolumes(cr *api.PerconaXtraDBCluster) error {
+	if !volumeExpansionEnabled(cr) || cr.Spec.Pause {
+		cr.Status.VolumeExpansion = nil
+		return nil
+	}
+
+	type sfsvolume struct {
+		app  api.StatefulApp
+		spec *api.PodSpec
+	}
+	apps := []sfsvolume{{statefulset.NewNode(cr), cr.Spec.PXC.PodSpec}}
+	if cr.ProxySQLEnabled() {
+		apps = append(apps, sfsvolume{statefulset.NewProxy(cr), cr.Spec.ProxySQL})
+	}
+
+	statuses := make([]api.VolumeExpansionStatus, 0, len(apps))
+	for _, a := range apps {
+		status, err := r.resizeVolumes(cr, a.app, a.spec)
+		if err != nil {
+			return errors.Wrapf(err, "resize %s volumes", a.app.Name())
+		}
+		if status == nil {
+			continue
+		}
+		statuses = append(statuses, *status)
+	}
+	cr.Status.VolumeExpansion = statuses
+
+	return nil
+}
+
+// resizeVolumes patches the PVCs of the statefulset with the requested storage size.
+// It returns nil if the volumes have the requested size already.
+func (r *ReconcilePerconaXtraDBCluster) resizeVolumes(cr *api.PerconaXtraDBCluster, app api.StatefulApp, spec *api.PodSpec) (*api.VolumeExpansionStatus, error) {
+	if spec == nil || spec.VolumeSpec == nil || spec.VolumeSpec.PersistentVolumeClaim == nil {
+		return nil, nil
+	}
+	requested, ok := spec.VolumeSpec.PersistentVolumeClaim.Resources.Requests[corev1.ResourceStorage]
+	if !ok || requested.IsZero() {
+		return nil, nil
+	}
+
+	sfs := &appsv1.StatefulSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: app.StatefulSet().Name, Namespace: cr.Namespace}, sfs)
+	if k8serrors.IsNotFound(err) {
+		// the statefulset deleted for recreation isn't created again yet
+		return recreatingStatefulSet(cr, app.Name()), nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get statefulset")
+	}
+	if len(sfs.Spec.VolumeClaimTemplates) == 0 {
+		return nil, nil
+	}
+	tmpl := sfs.Spec.VolumeClaimTemplates[0]
+	tmplSize := tmpl.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	pvcs, err := r.statefulSetPVCs(sfs, tmpl.Name, app.Labels())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &api.VolumeExpansionStatus{
+		Component:     app.Name(),
+		State:         api.VolumeExpansionInProgress,
+		RequestedSize: requested.String(),
+		TotalPVCs:     int32(len(pvcs)),
+	}
+
+	resized := int32(0)
+	for _, pvc := range pvcs {
+		if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && capacity.Cmp(requested) >= 0 {
+			resized++
+		}
+	}
+	status.ResizedPVCs = resized
+
+	if tmplSize.Cmp(requested) == 0 && resized == status.TotalPVCs {
+		return nil, nil
+	}
+
+	if requested.Cmp(tmplSize) < 0 {
+		status.State = api.VolumeExpansionError
+		status.Message = fmt.Sprintf("volumes can't be shrunk from %s to %s", tmplSize.String(), requested.String())
+		return status, nil
+	}
+
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if current.Cmp(requested) >= 0 {
+			continue
+		}
+
+		msg, err := r.checkVolumeExpansion(pvc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "check pvc %s", pvc.Name)
+		}
+		if msg != "" {
+			status.State = api.VolumeExpansionError
+			status.Message = msg
+			return status, nil
+		}
+
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = requested
+		err = r.client.Update(context.TODO(), pvc)
+		if k8serrors.IsForbidden(err) || k8serrors.IsInvalid(err) {
+			status.State = api.VolumeExpansionError
+			status.Message = fmt.Sprintf("pvc %s can't be expanded: %v", pvc.Name, err)
+			return status, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "update pvc %s", pvc.Name)
+		}
+		r.logger(cr.Name, cr.Namespace).Info("resize volume", "pvc", pvc.Name, "from", current.String(), "to", requested.String())
+	}
+
+	if resized < status.TotalPVCs {
+		status.Message = "waiting for the volumes to be resized"
+		if pending := fsResizePending(pvcs); len(pending) > 0 {
+			status.Message = "waiting for the file system resize on " + strings.Join(pending, ", ")
+		}
+		return status, nil
+	}
+
+	if !cr.Spec.VolumeExpansion.RecreateStatefulSet {
+		status.State = api.VolumeExpansionDone
+		status.Message = "statefulset keeps the previous volume claim template"
+		return status, nil
+	}
+
+	// the pods keep running, the statefulset is created again by the next reconcile.
+	// The state is kept in the status not to take the missing statefulset for the new cluster.
+	err = r.client.Delete(context.TODO(), sfs, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "delete statefulset with orphan policy")
+	}
+	status.State = api.VolumeExpansionRecreating
+	status.Message = "recreating statefulset"
+
+	return status, nil
+}
+
+// recreatingStatefulSet returns the volume expansion status of the component
+// if its statefulset is deleted to be created again
+func recreatingStatefulSet(cr *api.PerconaXtraDBCluster, component string) *api.VolumeExpansionStatus {
+	for i := range cr.Status.VolumeExpansion {
+		st := &cr.Status.VolumeExpansion[i]
+		if st.Component == component && st.State == api.VolumeExpansionRecreating {
+			return st
+		}
+	}
+
+	return nil
+}
+
+// statefulSetPVCs returns the PVCs created from the volume claim template of the statefulset
+func (r *ReconcilePerconaXtraDBCluster) statefulSetPVCs(sfs *appsv1.StatefulSet, tmplName string, ls map[string]string) ([]corev1.PersistentVolumeClaim, error) {
+	list := &corev1.PersistentVolumeClaimList{}
+	err := r.client.List(context.TODO(), list, &client.ListOptions{
+		Namespace:     sfs.Namespace,
+		LabelSelector: labels.SelectorFromSet(ls),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pvcs")
+	}
+
+	prefix := tmplName + "-" + sfs.Name + "-"
+	pvcs := make([]corev1.PersistentVolumeClaim, 0, len(list.Items))
+	for _, pvc := range list.Items {
+		if strings.HasPrefix(pvc.Name, prefix) {
+			pvcs = append(pvcs, pvc)
+		}
+	}
+
+	return pvcs, nil
+}
+
+// checkVolumeExpansion returns the reason the PVC can't be expanded or empty string.
+// The storage class can't be read with the namespaced operator permissions,
+// the expansion is checked by the API server in that case.
+func (r *ReconcilePerconaXtraDBCluster) checkVolumeExpansion(pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName == "" {
+		return fmt.Sprintf("pvc %s has no storage class, it can't be expanded", pvc.Name), nil
+	}
+
+	sc := &storagev1.StorageClass{}
+	if pvc.Spec.StorageClassName == nil {
+		// the PVC without the class uses the default one
+		list := &storagev1.StorageClassList{}
+		err := r.apiReader.List(context.TODO(), list)
+		if k8serrors.IsForbidden(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "list storage classes")
+		}
+		def := defaultStorageClass(list.Items)
+		if def == nil {
+			return fmt.Sprintf("pvc %s has no storage class and there is no default one, it can't be expanded", pvc.Name), nil
+		}
+		sc = def
+	} else {
+		err := r.apiReader.Get(context.TODO(), types.NamespacedName{Name: *pvc.Spec.StorageClassName}, sc)
+		if k8serrors.IsForbidden(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "get storage class %s", *pvc.Spec.StorageClassName)
+		}
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return fmt.Sprintf("storage class %s doesn't allow volume expansion", sc.Name), nil
+	}
+
+	return "", nil
+}
+
+// defaultStorageClass returns the storage class marked as the default one
+func defaultStorageClass(classes []storagev1.StorageClass) *storagev1.StorageClass {
+	for i := range classes {
+		for _, a := range []string{"storageclass.kubernetes.io/is-default-class", "storageclass.beta.kubernetes.io/is-default-class"} {
+			if classes[i].Annotations[a] == "true" {
+				return &classes[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func fsResizePending(pvcs []corev1.PersistentVolumeClaim) []string {
+	pending := []string{}
+	for _, pvc := range pvcs {
+		for _, c := range pvc.Status.Conditions {
+			if c.Type == corev1.PersistentVolumeClaimFileSystemResizePending && c.Status == corev1.ConditionTrue {
+				pending = append(pending, pvc.Name)
+			}
+		}
+	}
+
+	return pending
+}
+
+// keepVolumeClaimTemplates copies the volume claim templates of the existing statefulset
+// to the new one, they can't be updated and are changed only by recreating the statefulset
+func (r *ReconcilePerconaXtraDBCluster) keepVolumeClaimTemplates(sfs *appsv1.StatefulSet) error {
+	current := &appsv1.StatefulSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: sfs.Name, Namespace: sfs.Namespace}, current)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get statefulset")
+	}
+
+	sfs.Spec.VolumeClaimTemplates = current.Spec.VolumeClaimTemplates
+	return nil
+}
@@ -0,0 +1,161 @@
+package pxc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestResizeVolumes(t *testing.T) {
+	storage := func(size string) corev1.ResourceList {
+		return corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)}
+	}
+
+	newObjects := func(tmplSize, pvcSize, capacity string, allowExpansion bool) []runtime.Object {
+		cr := newCR("cluster1", "ns")
+		app := statefulset.NewNode(cr)
+		sfs := app.StatefulSet().DeepCopy()
+		sfs.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{
+			ObjectMeta: metav1.ObjectMeta{Name: statefulset.DataVolumeName},
+			Spec:       corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{Requests: storage(tmplSize)}},
+		}}
+
+		sc := "standard"
+		objs := []runtime.Object{
+			sfs,
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: sc}, AllowVolumeExpansion: &allowExpansion},
+		}
+		for i := 0; i < 3; i++ {
+			objs = append(objs, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-%s-%d", statefulset.DataVolumeName, sfs.Name, i),
+					Namespace: "ns",
+					Labels:    app.Labels(),
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &sc,
+					Resources:        corev1.ResourceRequirements{Requests: storage(pvcSize)},
+				},
+				Status: corev1.PersistentVolumeClaimStatus{Capacity: storage(capacity)},
+			})
+		}
+		return objs
+	}
+
+	tests := map[string]struct {
+		objs      []runtime.Object
+		requested string
+		recreate  bool
+		state     api.VolumeExpansionState
+		resized   int32
+		pvcSize   string
+		deleteSfs bool
+	}{
+		"unchanged":      {newObjects("6Gi", "6Gi", "6Gi", true), "6Gi", false, "", 0, "6Gi", false},
+		"grow":           {newObjects("6Gi", "6Gi", "6Gi", true), "10Gi", false, api.VolumeExpansionInProgress, 0, "10Gi", false},
+		"not expandable": {newObjects("6Gi", "6Gi", "6Gi", false), "10Gi", false, api.VolumeExpansionError, 0, "6Gi", false},
+		"shrink":         {newObjects("20Gi", "20Gi", "20Gi", true), "10Gi", false, api.VolumeExpansionError, 3, "20Gi", false},
+		"resized":        {newObjects("6Gi", "10Gi", "10Gi", true), "10Gi", false, api.VolumeExpansionDone, 3, "10Gi", false},
+		"recreate":       {newObjects("6Gi", "10Gi", "10Gi", true), "10Gi", true, api.VolumeExpansionRecreating, 3, "10Gi", true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := newCR("cluster1", "ns")
+			cr.Spec.VolumeExpansion = &api.VolumeExpansionSpec{Enabled: true, RecreateStatefulSet: tt.recreate}
+			cr.Spec.PXC.VolumeSpec = &api.VolumeSpec{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{Requests: storage(tt.requested)}},
+			}
+
+			r := buildFakeClient(tt.objs)
+			r.apiReader = r.client
+			r.log = zapr.NewLogger(zap.NewNop())
+			app := statefulset.NewNode(cr)
+
+			status, err := r.resizeVolumes(cr, app, cr.Spec.PXC.PodSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.state == "" {
+				if status != nil {
+					t.Fatalf("expected no status, got %+v", status)
+				}
+				return
+			}
+			if status == nil {
+				t.Fatalf("expected %s status, got nil", tt.state)
+			}
+			if status.State != tt.state || status.ResizedPVCs != tt.resized || status.TotalPVCs != 3 {
+				t.Errorf("got %s %d/%d, want %s %d/3: %s", status.State, status.ResizedPVCs, status.TotalPVCs, tt.state, tt.resized, status.Message)
+			}
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: statefulset.DataVolumeName + "-" + app.StatefulSet().Name + "-0", Namespace: "ns"}, pvc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; got.String() != tt.pvcSize {
+				t.Errorf("pvc size = %s, want %s", got.String(), tt.pvcSize)
+			}
+
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: app.StatefulSet().Name, Namespace: "ns"}, &appsv1.StatefulSet{})
+			if deleted := k8serrors.IsNotFound(err); deleted != tt.deleteSfs {
+				t.Errorf("statefulset deleted = %v, want %v", deleted, tt.deleteSfs)
+			}
+		})
+	}
+}
+
+func TestCheckVolumeExpansionDefaultClass(t *testing.T) {
+	allow := true
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "datadir-cluster1-pxc-0", Namespace: "ns"}}
+	standard := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: &allow}
+	def := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+		},
+	}
+
+	tests := map[string]struct {
+		objs []runtime.Object
+		ok   bool
+	}{
+		"no default":             {[]runtime.Object{standard}, false},
+		"default not expandable": {[]runtime.Object{standard, def.DeepCopy()}, false},
+		"default expandable": {[]runtime.Object{standard, func() runtime.Object {
+			sc := def.DeepCopy()
+			sc.AllowVolumeExpansion = &allow
+			return sc
+		}()}, true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := buildFakeClient(tt.objs)
+			r.apiReader = r.client
+
+			msg, err := r.checkVolumeExpansion(pvc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok := msg == ""; ok != tt.ok {
+				t.Errorf("expandable = %v, want %v: %s", ok, tt.ok, msg)
+			}
+		})
+	}
+}
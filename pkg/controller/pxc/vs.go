@@ -55,56 +55,67 @@ func (vs VersionServiceClient) GetExactVersion(cr *api.PerconaXtraDBCluster, end
 		return DepVersion{}, err
 	}
 
-	if len(resp.Payload.Versions) == 0 {
+	if len(resp.Payload.Versions) == 0 || resp.Payload.Versions[0].Matrix == nil {
 		return DepVersion{}, fmt.Errorf("empty versions response")
 	}
 
-	pxcVersion, err := getVersion(resp.Payload.Versions[0].Matrix.Pxc)
+	// the version service returns the only version of each product
+	return depVersion(cr, resp.Payload.Versions[0].Matrix, func(_ string, versions map[string]models.VersionVersion, _ string) (string, error) {
+		return getVersion(versions)
+	})
+}
+
+// versionPicker chooses the version of the product from the matrix,
+// pxcVersion is the chosen PXC version for the other products
+type versionPicker func(product string, versions map[string]models.VersionVersion, pxcVersion string) (string, error)
+
+func depVersion(cr *api.PerconaXtraDBCluster, matrix *models.VersionVersionMatrix, pick versionPicker) (DepVersion, error) {
+	pxcVersion, err := pick("pxc", matrix.Pxc, "")
 	if err != nil {
 		return DepVersion{}, err
 	}
 
-	backupVersion, err := getVersion(resp.Payload.Versions[0].Matrix.Backup)
+	backupVersion, err := pick("backup", matrix.Backup, pxcVersion)
 	if err != nil {
 		return DepVersion{}, err
 	}
 
-	pmmVersion, err := getVersion(resp.Payload.Versions[0].Matrix.Pmm)
+	pmmVersion, err := pick("pmm", matrix.Pmm, pxcVersion)
 	if err != nil {
 		return DepVersion{}, err
 	}
 
-	proxySqlVersion, err := getVersion(resp.Payload.Versions[0].Matrix.Proxysql)
+	proxySqlVersion, err := pick("proxysql", matrix.Proxysql, pxcVersion)
 	if err != nil {
 		return DepVersion{}, err
 	}
 
-	haproxyVersion, err := getVersion(resp.Payload.Versions[0].Matrix.Haproxy)
+	haproxyVersion, err := pick("haproxy", matrix.Haproxy, pxcVersion)
 	if err != nil {
 		return DepVersion{}, err
 	}
 
 	dv := DepVersion{
-		PXCImage:        resp.Payload.Versions[0].Matrix.Pxc[pxcVersion].ImagePath,
+		PXCImage:        matrix.Pxc[pxcVersion].ImagePath,
 		PXCVersion:      pxcVersion,
-		BackupImage:     resp.Payload.Versions[0].Matrix.Backup[backupVersion].ImagePath,
+		BackupImage:     matrix.Backup[backupVersion].ImagePath,
 		BackupVersion:   backupVersion,
-		ProxySqlImage:   resp.Payload.Versions[0].Matrix.Proxysql[proxySqlVersion].ImagePath,
+		ProxySqlImage:   matrix.Proxysql[proxySqlVersion].ImagePath,
 		ProxySqlVersion: proxySqlVersion,
-		PMMImage:        resp.Payload.Versions[0].Matrix.Pmm[pmmVersion].ImagePath,
+		PMMImage:        matrix.Pmm[pmmVersion].ImagePath,
 		PMMVersion:      pmmVersion,
-		HAProxyImage:    resp.Payload.Versions[0].Matrix.Haproxy[haproxyVersion].ImagePath,
+		HAProxyImage:    matrix.Haproxy[haproxyVersion].ImagePath,
 		HAProxyVersion:  haproxyVersion,
 	}
 
 	if cr.CompareVersionWith("1.7.0") >= 0 {
-		logCollectorVersion, err := getVersion(resp.Payload.Versions[0].Matrix.LogCollector)
+		logCollectorVersion, err := pick("logCollector", matrix.LogCollector, pxcVersion)
 		if err != nil {
 			return DepVersion{}, err
 		}
 
 		dv.LogCollectorVersion = logCollectorVersion
-		dv.LogCollectorImage = resp.Payload.Versions[0].Matrix.LogCollector[logCollectorVersion].ImagePath
+		dv.LogCollectorImage = matrix.LogCollector[logCollectorVersion].ImagePath
 
 	}
 
@@ -0,0 +1,217 @@
+package pxcrestore
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/k8s"
+)
+
+// getBackupRef returns the backup the restore refers to with backupRef.
+// The backup in another namespace and its storage credentials secret have to be granted
+// to the restore namespace, see checkAccess. The credentials are copied to the restore namespace.
+func (r *ReconcilePerconaXtraDBClusterRestore) getBackupRef(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) (*api.PerconaXtraDBClusterBackup, error) {
+	ref := cr.Spec.BackupRef
+	ns := ref.Namespace
+	if ns == "" {
+		ns = cr.Namespace
+	}
+
+	bcp := &api.PerconaXtraDBClusterBackup{}
+	err := r.apiReader.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: ns}, bcp)
+	if err != nil {
+		return nil, accessError(err, "backup", ns, ref.Name)
+	}
+	if bcp.Status.State != api.BackupSucceeded {
+		return nil, errors.Errorf("backup %s/%s didn't finished yet, current state: %s", ns, bcp.Name, bcp.Status.State)
+	}
+
+	if ns == cr.Namespace {
+		return bcp, nil
+	}
+
+	err = r.checkAccess(cr, cluster, bcp.Annotations, "perconaxtradbclusterbackups", ns, bcp.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(bcp.Status.Destination, "s3://") || bcp.Status.S3 == nil {
+		return nil, errors.Errorf("backup %s/%s with destination %s can't be restored from another namespace", ns, bcp.Name, bcp.Status.Destination)
+	}
+
+	// the object is only used to build the restore jobs in the restore namespace
+	bcp.Namespace = cr.Namespace
+	bcp.Status.S3 = bcp.Status.S3.DeepCopy()
+	bcp.Status.S3.CredentialsSecret, err = r.copyCredentials(cr, cluster, ns, bcp.Status.S3.CredentialsSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return bcp, nil
+}
+
+// resolveStorage fills in the S3 options of the backup source from
+// the storage with backupSource.storageName of the storageClusterRef cluster
+// (or the restore cluster if it isn't set)
+func (r *ReconcilePerconaXtraDBClusterRestore) resolveStorage(cr *api.PerconaXtraDBClusterRestore, restoreCluster *api.PerconaXtraDBCluster, bcp *api.PerconaXtraDBClusterBackup) error {
+	ref := api.ObjectRef{Namespace: cr.Namespace, Name: cr.Spec.PXCCluster}
+	if cr.Spec.StorageClusterRef != nil {
+		ref = *cr.Spec.StorageClusterRef
+		if ref.Namespace == "" {
+			ref.Namespace = cr.Namespace
+		}
+	}
+
+	cluster := &api.PerconaXtraDBCluster{}
+	err := r.apiReader.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, cluster)
+	if err != nil {
+		return accessError(err, "cluster", ref.Namespace, ref.Name)
+	}
+	if ref.Namespace != cr.Namespace {
+		err = r.checkAccess(cr, restoreCluster, cluster.Annotations, "perconaxtradbclusters", ref.Namespace, ref.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	name := bcp.Status.StorageName
+	if cluster.Spec.Backup == nil || cluster.Spec.Backup.Storages[name] == nil {
+		return errors.Errorf("no storage %s in cluster %s/%s", name, ref.Namespace, ref.Name)
+	}
+	storage := cluster.Spec.Backup.Storages[name]
+	if storage.Type != api.BackupStorageS3 {
+		return errors.Errorf("storage %s of cluster %s/%s isn't s3", name, ref.Namespace, ref.Name)
+	}
+
+	bcp.Status.S3 = storage.S3.DeepCopy()
+	if ref.Namespace != cr.Namespace {
+		bcp.Status.S3.CredentialsSecret, err = r.copyCredentials(cr, restoreCluster, ref.Namespace, storage.S3.CredentialsSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accessError makes the error clear if the operator isn't allowed to read the source namespace
+func accessError(err error, kind, namespace, name string) error {
+	if k8serrors.IsForbidden(err) {
+		return errors.Wrapf(err, "operator isn't allowed to get %s %s/%s", kind, namespace, name)
+	}
+
+	return errors.Wrapf(err, "get %s %s/%s", kind, namespace, name)
+}
+
+// checkAccess checks that the object of another namespace is granted to the restore namespace.
+// The object has to list the namespace in the RestoreAllowedNamespacesAnnotation and RBAC of the
+// source namespace has to let the service account the restore jobs run with get the object.
+// The operator reads the object with its own permissions, so the annotation alone would let any
+// namespace listed there use it regardless of RBAC.
+func (r *ReconcilePerconaXtraDBClusterRestore) checkAccess(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster,
+	annotations map[string]string, resource, namespace, name string) error {
+	if !api.NamespaceAllowed(annotations, cr.Namespace) {
+		return errors.Errorf("%s %s/%s doesn't allow restores from namespace %s, see %s annotation",
+			resource, namespace, name, cr.Namespace, api.RestoreAllowedNamespacesAnnotation)
+	}
+
+	group := api.SchemeGroupVersion.Group
+	if resource == "secrets" {
+		group = ""
+	}
+	sa := restoreServiceAccount(cluster)
+	review := &authorizationv1.LocalSubjectAccessReview{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   "system:serviceaccount:" + cr.Namespace + ":" + sa,
+			Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + cr.Namespace, "system:authenticated"},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Group:     group,
+				Resource:  resource,
+				Name:      name,
+			},
+		},
+	}
+	err := r.client.Create(context.TODO(), review)
+	if err != nil {
+		return errors.Wrapf(err, "review access of service account %s/%s to %s %s/%s", cr.Namespace, sa, resource, namespace, name)
+	}
+	if !review.Status.Allowed {
+		return errors.Errorf("service account %s/%s isn't allowed to get %s %s/%s: %s",
+			cr.Namespace, sa, resource, namespace, name, review.Status.Reason)
+	}
+
+	return nil
+}
+
+// restoreServiceAccount returns the service account the restore jobs run with
+func restoreServiceAccount(cluster *api.PerconaXtraDBCluster) string {
+	if cluster.Spec.PXC != nil && cluster.Spec.PXC.ServiceAccountName != "" {
+		return cluster.Spec.PXC.ServiceAccountName
+	}
+	return "default"
+}
+
+// copyCredentials copies the storage credentials secret from another namespace
+// to the restore namespace and returns the name of the copy. The copy is synced
+// with the source on every reconcile and it's deleted along with the restore.
+func (r *ReconcilePerconaXtraDBClusterRestore) copyCredentials(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster, namespace, name string) (string, error) {
+	src := &corev1.Secret{}
+	err := r.apiReader.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, src)
+	if err != nil {
+		return "", accessError(err, "credentials secret", namespace, name)
+	}
+	err = r.checkAccess(cr, cluster, src.Annotations, "secrets", namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-backup-credentials",
+			Namespace: cr.Namespace,
+		},
+		Type: src.Type,
+		Data: src.Data,
+	}
+	err = k8s.SetControllerReference(cr, secret, r.scheme)
+	if err != nil {
+		return "", errors.Wrap(err, "set controller reference")
+	}
+
+	current := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, current)
+	if k8serrors.IsNotFound(err) {
+		err = r.client.Create(context.TODO(), secret)
+		if err != nil {
+			return "", errors.Wrap(err, "copy credentials secret")
+		}
+		return secret.Name, nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "get secret %s", secret.Name)
+	}
+
+	if !metav1.IsControlledBy(current, cr) {
+		return "", errors.Errorf("secret %s exists and doesn't belong to the restore", secret.Name)
+	}
+	if !reflect.DeepEqual(current.Data, src.Data) {
+		current.Data = src.Data
+		err = r.client.Update(context.TODO(), current)
+		if err != nil {
+			return "", errors.Wrap(err, "update credentials secret copy")
+		}
+	}
+
+	return secret.Name, nil
+}
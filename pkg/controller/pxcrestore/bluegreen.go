@@ -0,0 +1,211 @@
+package pxcrestore
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+// replacesAnnotation keeps the cluster the standby cluster is going to replace
+const replacesAnnotation = "percona.com/replaces"
+
+func standbyName(cr *api.PerconaXtraDBClusterRestore) string {
+	return fmt.Sprintf("%s-%x", cr.Spec.PXCCluster, sha1.Sum([]byte(cr.Name)))[:len(cr.Spec.PXCCluster)+6]
+}
+
+// restoreStandby creates the standby cluster that is bootstrapped from the backup
+// with spec.dataSource. It returns true when the data is restored and the standby is ready.
+func (r *ReconcilePerconaXtraDBClusterRestore) restoreStandby(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) (bool, error) {
+	standby := &api.PerconaXtraDBCluster{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Status.StandbyCluster, Namespace: cr.Namespace}, standby)
+	if k8serrors.IsNotFound(err) {
+		return false, r.createStandby(cr, cluster)
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "get standby cluster %s", cr.Status.StandbyCluster)
+	}
+
+	restores := &api.PerconaXtraDBClusterRestoreList{}
+	err = r.client.List(context.TODO(), restores, &client.ListOptions{
+		Namespace:     cr.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{api.DataSourceRestoreLabel: standby.Name}),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "list standby restores")
+	}
+	if len(restores.Items) == 0 {
+		return false, nil
+	}
+
+	restore := restores.Items[0]
+	switch restore.Status.State {
+	case api.RestoreSucceeded:
+	case api.RestoreFailed, api.RestoreCancelled:
+		return false, errors.Errorf("standby cluster restore %s is %s: %s", restore.Name, restore.Status.State, restore.Status.Comments)
+	default:
+		return false, nil
+	}
+
+	return standby.Status.ObservedGeneration == standby.Generation && standby.Status.Status == api.AppStateReady, nil
+}
+
+// createStandby creates the standby cluster with the spec of the currently serving cluster
+func (r *ReconcilePerconaXtraDBClusterRestore) createStandby(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) error {
+	serving := cluster
+	if cluster.ServingCluster() != cluster.Name {
+		serving = &api.PerconaXtraDBCluster{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: cluster.ServingCluster(), Namespace: cluster.Namespace}, serving)
+		if err != nil {
+			return errors.Wrapf(err, "get serving cluster %s", cluster.ServingCluster())
+		}
+	}
+
+	standby := &api.PerconaXtraDBCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Status.StandbyCluster,
+			Namespace: cr.Namespace,
+			Annotations: map[string]string{
+				replacesAnnotation: serving.Name,
+			},
+		},
+		Spec: *serving.Spec.DeepCopy(),
+	}
+
+	standby.Spec.Pause = false
+	standby.Spec.DataSource = &api.DataSource{
+		BackupName:   cr.Spec.BackupName,
+		BackupSource: cr.Spec.BackupSource,
+		BackupRef:    cr.Spec.BackupRef,
+		PITR:         cr.Spec.PITR,
+	}
+	// clients keep connecting through the services of the cluster after the switchover,
+	// so the standby uses the certificates issued for the cluster names
+	standby.Spec.SSLSecretName, standby.Spec.SSLInternalSecretName = sslSecrets(serving)
+	// backups and binlogs are moved to the standby on the switchover
+	if standby.Spec.Backup != nil {
+		standby.Spec.Backup.Schedule = nil
+		standby.Spec.Backup.PITR.Enabled = false
+	}
+
+	err := r.client.Create(context.TODO(), standby)
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "create standby cluster %s", standby.Name)
+	}
+
+	return nil
+}
+
+// sslSecrets returns the names of the SSL secrets the cluster uses
+func sslSecrets(cluster *api.PerconaXtraDBCluster) (string, string) {
+	ssl, sslInternal := cluster.Spec.SSLSecretName, cluster.Spec.SSLInternalSecretName
+	if len(ssl) == 0 {
+		ssl = cluster.Name + "-ssl"
+	}
+	if len(sslInternal) == 0 {
+		sslInternal = cluster.Name + "-ssl-internal"
+	}
+
+	return ssl, sslInternal
+}
+
+// switchover points the proxy services of the cluster to the standby cluster
+// and retires the cluster that was serving before. It returns true when it's done.
+func (r *ReconcilePerconaXtraDBClusterRestore) switchover(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) (bool, error) {
+	standby := &api.PerconaXtraDBCluster{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Status.StandbyCluster, Namespace: cr.Namespace}, standby)
+	if err != nil {
+		return false, errors.Wrapf(err, "get standby cluster %s", cr.Status.StandbyCluster)
+	}
+
+	if cluster.ServingCluster() != standby.Name {
+		if cluster.Annotations == nil {
+			cluster.Annotations = make(map[string]string)
+		}
+		cluster.Annotations[api.ServingClusterAnnotation] = standby.Name
+		err = r.client.Update(context.TODO(), cluster)
+		if err != nil {
+			return false, errors.Wrap(err, "update cluster")
+		}
+		return false, nil
+	}
+
+	// the services are updated by the cluster controller
+	svcs := []types.NamespacedName{}
+	if cluster.Spec.HAProxy != nil && cluster.Spec.HAProxy.Enabled {
+		svcs = append(svcs, cluster.HaproxyServiceNamespacedName(), cluster.HAProxyReplicasNamespacedName())
+	}
+	if cluster.Spec.ProxySQL != nil && cluster.Spec.ProxySQL.Enabled {
+		svcs = append(svcs, cluster.ProxySQLServiceNamespacedName())
+	}
+	for _, nn := range svcs {
+		svc := &corev1.Service{}
+		err = r.client.Get(context.TODO(), nn, svc)
+		if err != nil {
+			return false, errors.Wrapf(err, "get service %s", nn.Name)
+		}
+		if svc.Spec.Selector["app.kubernetes.io/instance"] != standby.Name {
+			return false, nil
+		}
+	}
+
+	retired := &api.PerconaXtraDBCluster{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: standby.Annotations[replacesAnnotation], Namespace: cr.Namespace}, retired)
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "get retired cluster %s", standby.Annotations[replacesAnnotation])
+	}
+	if retired.Spec.Pause {
+		return true, nil
+	}
+
+	if retired.Spec.Backup != nil && standby.Spec.Backup != nil {
+		standby.Spec.Backup.Schedule = retired.Spec.Backup.Schedule
+		standby.Spec.Backup.PITR.Enabled = retired.Spec.Backup.PITR.Enabled
+		err = r.client.Update(context.TODO(), standby)
+		if err != nil {
+			return false, errors.Wrap(err, "move backups to standby cluster")
+		}
+
+		retired.Spec.Backup.Schedule = nil
+		retired.Spec.Backup.PITR.Enabled = false
+	}
+	retired.Spec.Pause = true
+	err = r.client.Update(context.TODO(), retired)
+	if err != nil {
+		return false, errors.Wrapf(err, "retire cluster %s", retired.Name)
+	}
+
+	return true, nil
+}
+
+// deleteStandby deletes the standby cluster if the switchover hasn't happened yet
+func (r *ReconcilePerconaXtraDBClusterRestore) deleteStandby(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) error {
+	if cr.Status.StandbyCluster == "" || cluster.ServingCluster() == cr.Status.StandbyCluster {
+		return nil
+	}
+
+	standby := &api.PerconaXtraDBCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Status.StandbyCluster,
+			Namespace: cr.Namespace,
+		},
+	}
+	err := r.client.Delete(context.TODO(), standby)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete standby cluster %s", standby.Name)
+	}
+
+	return nil
+}
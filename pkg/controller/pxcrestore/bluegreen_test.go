@@ -0,0 +1,45 @@
+package pxcrestore
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestStandbyName(t *testing.T) {
+	restore := func(name string) *api.PerconaXtraDBClusterRestore {
+		return &api.PerconaXtraDBClusterRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       api.PerconaXtraDBClusterRestoreSpec{PXCCluster: "cluster1"},
+		}
+	}
+
+	name := standbyName(restore("restore1"))
+	if !strings.HasPrefix(name, "cluster1-") || len(name) != len("cluster1-")+5 {
+		t.Errorf("unexpected standby name %q", name)
+	}
+	if standbyName(restore("restore1")) != name {
+		t.Error("standby name isn't stable")
+	}
+	if standbyName(restore("restore2")) == name {
+		t.Error("standby names of different restores are the same")
+	}
+}
+
+func TestSSLSecrets(t *testing.T) {
+	cluster := &api.PerconaXtraDBCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	ssl, sslInternal := sslSecrets(cluster)
+	if ssl != "cluster1-ssl" || sslInternal != "cluster1-ssl-internal" {
+		t.Errorf("unexpected default secrets %q, %q", ssl, sslInternal)
+	}
+
+	cluster.Spec.SSLSecretName = "my-ssl"
+	cluster.Spec.SSLInternalSecretName = "my-ssl-internal"
+	ssl, sslInternal = sslSecrets(cluster)
+	if ssl != "my-ssl" || sslInternal != "my-ssl-internal" {
+		t.Errorf("unexpected secrets %q, %q", ssl, sslInternal)
+	}
+}
@@ -3,6 +3,7 @@ package pxcrestore
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -24,6 +26,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/percona/percona-xtradb-cluster-operator/clientcmd"
 	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
 	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
 	"github.com/percona/percona-xtradb-cluster-operator/version"
@@ -46,6 +49,11 @@ func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 		return nil, fmt.Errorf("get version: %v", err)
 	}
 
+	cli, err := clientcmd.NewClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "create clientcmd")
+	}
+
 	zapLog, err := zap.NewProduction()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create logger")
@@ -53,8 +61,11 @@ func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 
 	return &ReconcilePerconaXtraDBClusterRestore{
 		client:        mgr.GetClient(),
+		apiReader:     mgr.GetAPIReader(),
 		scheme:        mgr.GetScheme(),
+		recorder:      mgr.GetEventRecorderFor("perconaxtradbclusterrestore-controller"),
 		serverVersion: sv,
+		clientcmd:     cli,
 		log:           zapr.NewLogger(zapLog),
 	}, nil
 }
@@ -83,9 +94,14 @@ type ReconcilePerconaXtraDBClusterRestore struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// apiReader reads the objects of the other namespaces
+	// that aren't in the cache of the client
+	apiReader client.Reader
+	scheme    *runtime.Scheme
+	recorder  record.EventRecorder
 
 	serverVersion *version.ServerVersion
+	clientcmd     *clientcmd.Client
 	log           logr.Logger
 }
 
@@ -96,156 +112,368 @@ func (r *ReconcilePerconaXtraDBClusterRestore) logger(name, namespace string) lo
 
 // Reconcile reads that state of the cluster for a PerconaXtraDBClusterRestore object and makes changes based on the state read
 // and what is in the PerconaXtraDBClusterRestore.Spec
+// The restore is a state machine. Each reconcile makes one step of the current state
+// and moves the restore to the next state once the step is done. All the progress is kept
+// in the restore status, so the restore is resumed after the operator restart.
 // Note:
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcilePerconaXtraDBClusterRestore) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	rr := reconcile.Result{}
+	rr := reconcile.Result{
+		RequeueAfter: time.Second * 5,
+	}
 
 	cr := &api.PerconaXtraDBClusterRestore{}
 	err := r.client.Get(context.TODO(), request.NamespacedName, cr)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			return rr, nil
+			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
-		return rr, err
+		return reconcile.Result{}, err
 	}
-	if cr.Status.State != api.RestoreNew {
-		return rr, nil
+	if cr.IsFinished() {
+		return reconcile.Result{}, nil
 	}
 
 	lgr := r.logger(request.Name, request.Namespace)
-	lgr.Info("backup restore request")
 
-	err = r.setStatus(cr, api.RestoreStarting, "")
-	if err != nil {
-		return rr, errors.Wrap(err, "set status")
-	}
-	rJobsList := &api.PerconaXtraDBClusterRestoreList{}
-	err = r.client.List(
-		context.TODO(),
-		rJobsList,
-		&client.ListOptions{
-			Namespace: cr.Namespace,
-		},
-	)
-	if err != nil {
-		return rr, errors.Wrap(err, "get restore jobs list")
+	if cr.Spec.Cancel && cr.Status.State != api.RestoreCancelling && cr.Status.State != api.RestoreRollingBack {
+		state := api.RestoreCancelling
+		if cr.Status.State == api.RestoreNew {
+			state = api.RestoreCancelled
+		}
+		lgr.Info("restore is cancelled", "cluster", cr.Spec.PXCCluster, "state", cr.Status.State)
+		err = r.setStatus(cr, state, "")
+		if err != nil {
+			return rr, errors.Wrap(err, "set status")
+		}
+		return rr, nil
 	}
 
-	returnMsg := fmt.Sprintf(backupRestoredMsg, cr.Name, cr.Spec.PXCCluster, cr.Name)
+	if cr.Status.State == api.RestoreNew {
+		lgr.Info("backup restore request")
+	}
 
-	defer func() {
-		status := api.BcpRestoreStates(api.RestoreSucceeded)
+	progress := cr.Status.Progress.DeepCopy()
+	state, msg, err := r.reconcileState(cr)
+	if err != nil {
+		if k8serrors.IsConflict(errors.Cause(err)) {
+			lgr.Info("object was modified, retrying", "state", cr.Status.State, "error", err.Error())
+			return rr, nil
+		}
+		// the state is limited in time, so it's safe to retry
+		if isTransient(err) {
+			lgr.Info("temporary error, retrying", "state", cr.Status.State, "error", err.Error())
+			return rr, nil
+		}
+		lgr.Error(err, "restore failed", "state", cr.Status.State)
+		if canRollback(cr) {
+			lgr.Info("rolling back the cluster", "cluster", cr.Spec.PXCCluster, "snapshot", cr.Status.Snapshot)
+			err = r.setStatus(cr, api.RestoreRollingBack, err.Error())
+			if err != nil {
+				return rr, errors.Wrap(err, "set status")
+			}
+			return rr, nil
+		}
+		err = r.setStatus(cr, api.RestoreFailed, err.Error())
 		if err != nil {
-			status = api.RestoreFailed
-			returnMsg = err.Error()
+			return rr, errors.Wrap(err, "set status")
 		}
-		r.setStatus(cr, status, returnMsg)
-	}()
+		return reconcile.Result{}, nil
+	}
 
-	for _, j := range rJobsList.Items {
-		if j.Spec.PXCCluster == cr.Spec.PXCCluster &&
-			j.Name != cr.Name && j.Status.State != api.RestoreFailed &&
-			j.Status.State != api.RestoreSucceeded {
-			err = errors.Errorf("unable to continue, concurent restore job %s running now.", j.Name)
-			return rr, err
+	if state == cr.Status.State {
+		// the progress is reported while the jobs run
+		if !reflect.DeepEqual(progress, cr.Status.Progress) {
+			err = r.setStatus(cr, state, cr.Status.Comments)
+			if err != nil {
+				return rr, errors.Wrap(err, "set status")
+			}
 		}
+		return rr, nil
 	}
 
-	err = cr.CheckNsetDefaults()
+	lgr.Info("restore state changed", "cluster", cr.Spec.PXCCluster, "from", cr.Status.State, "to", state)
+	err = r.setStatus(cr, state, msg)
 	if err != nil {
-		return rr, err
-	}
-	bcp, err := r.getBackup(cr)
-	if err != nil {
-		return rr, errors.Wrap(err, "get backup")
+		return rr, errors.Wrap(err, "set status")
 	}
 
-	cluster := api.PerconaXtraDBCluster{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Spec.PXCCluster, Namespace: cr.Namespace}, &cluster)
-	if err != nil {
-		err = errors.Wrapf(err, "get cluster %s", cr.Spec.PXCCluster)
-		return rr, err
+	if cr.IsFinished() {
+		lgr.Info(msg)
+		return reconcile.Result{}, nil
 	}
 
-	_, err = cluster.CheckNSetDefaults(r.serverVersion, r.log)
-	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("wrong PXC options: %v", err)
-	}
+	return rr, nil
+}
 
-	lgr.Info("stopping cluster", "cluster", cr.Spec.PXCCluster)
-	err = r.setStatus(cr, api.RestoreStopCluster, "")
+// reconcileState makes a step of the current restore state and
+// returns the next state with the message for it
+func (r *ReconcilePerconaXtraDBClusterRestore) reconcileState(cr *api.PerconaXtraDBClusterRestore) (api.BcpRestoreStates, string, error) {
+	err := cr.CheckNsetDefaults()
 	if err != nil {
-		err = errors.Wrap(err, "set status")
-		return rr, err
+		return "", "", err
 	}
-	err = r.stopCluster(cluster.DeepCopy())
+
+	cluster := &api.PerconaXtraDBCluster{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Spec.PXCCluster, Namespace: cr.Namespace}, cluster)
 	if err != nil {
-		err = errors.Wrapf(err, "stop cluster %s", cluster.Name)
-		return rr, err
+		return "", "", errors.Wrapf(err, "get cluster %s", cr.Spec.PXCCluster)
 	}
 
-	lgr.Info("starting restore", "cluster", cr.Spec.PXCCluster, "backup", cr.Spec.BackupName)
-	err = r.setStatus(cr, api.RestoreRestore, "")
-	if err != nil {
-		err = errors.Wrap(err, "set status")
-		return rr, err
+	// cancellation and rollback don't need the backup
+	switch cr.Status.State {
+	case api.RestoreCancelling:
+		done, err := r.deleteRestoreObjects(cr)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "delete restore objects")
+		}
+		err = r.deleteStandby(cr, cluster)
+		if err != nil {
+			return cr.Status.State, "", err
+		}
+		if cr.Status.Cluster == nil {
+			return api.RestoreCancelled, fmt.Sprintf(cancelledMsg, cr.Spec.PXCCluster), nil
+		}
+		// the cluster can't be started on the partly restored data
+		if !canRollback(cr) {
+			return api.RestoreCancelled, fmt.Sprintf(cancelledDataChangedMsg, cr.Spec.PXCCluster), nil
+		}
+		return api.RestoreRollingBack, "", nil
+	case api.RestoreRollingBack:
+		done, err := r.rollback(cr, cluster)
+		if err != nil || !done {
+			// keep the reason of the rollback
+			return cr.Status.State, cr.Status.Comments, errors.Wrap(err, "roll back")
+		}
+		msg := fmt.Sprintf(rolledBackMsg, cr.Spec.PXCCluster)
+		if cr.Status.Snapshot != "" {
+			msg = fmt.Sprintf(rolledBackToSnapshotMsg, cr.Spec.PXCCluster, cr.Status.Snapshot)
+		}
+		if cr.Spec.Cancel {
+			return api.RestoreCancelled, msg, nil
+		}
+		return api.RestoreFailed, cr.Status.Comments + "\n" + msg, nil
 	}
-	err = r.restore(cr, bcp, cluster.Spec)
+
+	// jobs are built from the cluster spec with defaults,
+	// but only the original cluster object should be updated
+	clusterWithDefaults := cluster.DeepCopy()
+	_, err = clusterWithDefaults.CheckNSetDefaults(r.serverVersion, r.log)
 	if err != nil {
-		err = errors.Wrap(err, "run restore")
-		return rr, err
+		return "", "", errors.Wrap(err, "wrong PXC options")
 	}
 
-	lgr.Info("starting cluster", "cluster", cr.Spec.PXCCluster)
-	err = r.setStatus(cr, api.RestoreStartCluster, "")
+	bcp, err := r.getBackup(cr, clusterWithDefaults)
 	if err != nil {
-		err = errors.Wrap(err, "set status")
-		return rr, err
+		return "", "", errors.Wrap(err, "get backup")
 	}
 
-	if cr.Spec.PITR != nil {
-		oldSize := cluster.Spec.PXC.Size
-		oldUnsafe := cluster.Spec.AllowUnsafeConfig
-		cluster.Spec.PXC.Size = 1
-		cluster.Spec.AllowUnsafeConfig = true
+	if timeout := stateTimeout(cr, clusterWithDefaults); timeout > 0 &&
+		cr.Status.StateChangedAt != nil && time.Since(cr.Status.StateChangedAt.Time) > timeout {
+		return "", "", errors.Errorf("state %q exceeded timeout %s", cr.Status.State, timeout)
+	}
 
-		if err := r.startCluster(&cluster); err != nil {
-			return rr, errors.Wrap(err, "restart cluster for pitr")
+	switch cr.Status.State {
+	case api.RestoreNew, api.RestoreStarting:
+		return r.reconcileNew(cr)
+	case api.RestoreValidating:
+		return r.reconcileValidating(cr, bcp, cluster, clusterWithDefaults)
+	case api.RestoreStandby:
+		done, err := r.restoreStandby(cr, cluster)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrapf(err, "restore standby cluster %s", cr.Status.StandbyCluster)
+		}
+		return api.RestoreSwitchover, "", nil
+	case api.RestoreSwitchover:
+		done, err := r.switchover(cr, cluster)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrapf(err, "switch over to cluster %s", cr.Status.StandbyCluster)
+		}
+		return api.RestoreSucceeded, fmt.Sprintf(switchedOverMsg, cr.Spec.PXCCluster, cr.Status.StandbyCluster), nil
+	case api.RestoreStopCluster:
+		if isDataSource(cr) {
+			done, err := r.createDataVolume(cluster, clusterWithDefaults)
+			if err != nil || !done {
+				return cr.Status.State, "", errors.Wrap(err, "create data volume")
+			}
+			return api.RestoreRestore, "", nil
+		}
+		done, err := r.stopCluster(cluster)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrapf(err, "stop cluster %s", cluster.Name)
+		}
+		if cr.Spec.Rollback != nil && cr.Spec.Rollback.Enabled {
+			return api.RestoreSnapshot, "", nil
+		}
+		return api.RestoreRestore, "", nil
+	case api.RestoreSnapshot:
+		done, err := r.snapshot(cr, cluster)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "take pre-restore snapshot")
+		}
+		cr.Status.Snapshot = snapshotName(cr)
+		return api.RestoreRestore, "", nil
+	case api.RestoreRestore:
+		done, err := r.restore(cr, bcp, clusterWithDefaults.Spec)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "run restore")
+		}
+		// the restore job has downloaded the whole backup
+		if cr.Status.Progress != nil {
+			cr.Status.Progress.BytesDownloaded = backupSize(cr)
+		}
+		if cr.Spec.PITR != nil {
+			return api.RestorePreparePITR, "", nil
+		}
+		return api.RestoreStartCluster, "", nil
+	case api.RestorePreparePITR:
+		done, err := r.startCluster(cluster, 1, true)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "restart cluster for pitr")
+		}
+		return api.RestorePITR, "", nil
+	case api.RestorePITR:
+		done, err := r.pitr(cr, bcp, clusterWithDefaults.Spec)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "run pitr")
+		}
+		return api.RestoreStartCluster, "", nil
+	case api.RestoreStartCluster:
+		size, unsafe := cluster.Spec.PXC.Size, cluster.Spec.AllowUnsafeConfig
+		if cr.Status.Cluster != nil {
+			size, unsafe = cr.Status.Cluster.PXCSize, cr.Status.Cluster.AllowUnsafeConfig
+		}
+		done, err := r.startCluster(cluster, size, unsafe)
+		if err != nil || !done {
+			return cr.Status.State, "", errors.Wrap(err, "restart cluster")
 		}
+		return api.RestoreSucceeded, fmt.Sprintf(backupRestoredMsg, cr.Name, cr.Spec.PXCCluster, cr.Name), nil
+	}
+
+	return "", "", errors.Errorf("unknown state %q", cr.Status.State)
+}
 
-		lgr.Info("point-in-time recovering", "cluster", cr.Spec.PXCCluster)
-		err = r.setStatus(cr, api.RestorePITR, "")
+func (r *ReconcilePerconaXtraDBClusterRestore) reconcileNew(cr *api.PerconaXtraDBClusterRestore) (api.BcpRestoreStates, string, error) {
+	if !cr.Spec.DryRun {
+		rJobsList := &api.PerconaXtraDBClusterRestoreList{}
+		err := r.client.List(
+			context.TODO(),
+			rJobsList,
+			&client.ListOptions{
+				Namespace: cr.Namespace,
+			},
+		)
 		if err != nil {
-			return rr, errors.Wrap(err, "set status")
+			return "", "", errors.Wrap(err, "get restore jobs list")
 		}
 
-		err = r.pitr(cr, bcp, cluster.Spec)
-		if err != nil {
-			return rr, errors.Wrap(err, "run pitr")
+		for _, j := range rJobsList.Items {
+			if j.Spec.DryRun {
+				continue
+			}
+			if j.Spec.PXCCluster == cr.Spec.PXCCluster &&
+				j.Name != cr.Name && !j.IsFinished() {
+				return "", "", errors.Errorf("unable to continue, concurent restore job %s running now.", j.Name)
+			}
 		}
+	}
 
-		cluster.Spec.PXC.Size = oldSize
-		cluster.Spec.AllowUnsafeConfig = oldUnsafe
+	if cr.Spec.PITR != nil && cr.Spec.PITR.Type == api.PITRTypeDate {
+		// CheckNsetDefaults has already validated the date
+		t, _ := cr.Spec.PITR.Time()
+		cr.Status.PITRTarget = t.UTC().Format(time.RFC3339)
 	}
 
-	err = r.startCluster(&cluster)
+	return api.RestoreValidating, "", nil
+}
+
+// reconcileValidating runs the checks that can be done without stopping the cluster.
+// Dry-run restores are finished after them.
+func (r *ReconcilePerconaXtraDBClusterRestore) reconcileValidating(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster, clusterWithDefaults *api.PerconaXtraDBCluster) (api.BcpRestoreStates, string, error) {
+	plan, err := r.validate(cr, bcp, clusterWithDefaults)
 	if err != nil {
-		err = errors.Wrap(err, "restart cluster")
-		return rr, err
+		return "", "", errors.Wrap(err, "validate restore")
+	}
+	if plan == nil {
+		return cr.Status.State, "", nil
+	}
+
+	cr.Status.Plan = plan
+	if cr.Spec.DryRun {
+		return api.RestoreSucceeded, fmt.Sprintf(dryRunMsg, cr.Spec.PXCCluster), nil
+	}
+
+	cr.Status.Progress = &api.RestoreProgress{}
+	if cr.Spec.PITR != nil {
+		cr.Status.Progress.BinlogsPlanned = plan.BinlogsCount
+	}
+
+	// the serving cluster isn't touched until the standby one is ready
+	if cr.Spec.Strategy == api.RestoreStrategyBlueGreen {
+		cr.Status.StandbyCluster = standbyName(cr)
+		return api.RestoreStandby, "", nil
 	}
 
-	lgr.Info(returnMsg)
+	cr.Status.Cluster = &api.RestoreClusterOptions{
+		PXCSize:           cluster.Spec.PXC.Size,
+		AllowUnsafeConfig: cluster.Spec.AllowUnsafeConfig,
+	}
 
-	return rr, err
+	return api.RestoreStopCluster, "", nil
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) getBackup(cr *api.PerconaXtraDBClusterRestore) (*api.PerconaXtraDBClusterBackup, error) {
+// defaultDataTimeout limits the states that copy the data if spec.dataTimeout isn't set
+const defaultDataTimeout = 24 * time.Hour
+
+// stateTimeout returns how long the restore can stay in the current state.
+// Zero means the state isn't limited in time.
+func stateTimeout(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) time.Duration {
+	switch cr.Status.State {
+	case api.RestoreStopCluster:
+		var gracePeriodSec int64
+		if cluster.Spec.PXC.TerminationGracePeriodSeconds != nil {
+			gracePeriodSec = int64(cluster.Spec.PXC.Size) * *cluster.Spec.PXC.TerminationGracePeriodSeconds
+		}
+		// time to shutdown pods and to delete pvcs
+		return time.Duration(2*waitLimitSec+gracePeriodSec) * time.Second
+	case api.RestorePreparePITR, api.RestoreStartCluster:
+		if cluster.Spec.PXC.LivenessInitialDelaySeconds != nil {
+			return time.Duration(*cluster.Spec.PXC.LivenessInitialDelaySeconds*cluster.Spec.PXC.Size) * time.Second
+		}
+		return 2 * time.Hour
+	case api.RestoreValidating, api.RestoreSnapshot:
+		return time.Hour
+	case api.RestoreSwitchover:
+		return time.Duration(2*waitLimitSec) * time.Second
+	case api.RestoreRestore, api.RestorePITR, api.RestoreStandby:
+		if cr.Spec.DataTimeout != nil && cr.Spec.DataTimeout.Duration > 0 {
+			return cr.Spec.DataTimeout.Duration
+		}
+		return defaultDataTimeout
+	}
+
+	return 0
+}
+
+// isTransient checks if the error is caused by the temporary
+// unavailability of the API server and the step can be retried
+func isTransient(err error) bool {
+	err = errors.Cause(err)
+	return k8serrors.IsServerTimeout(err) || k8serrors.IsTimeout(err) ||
+		k8serrors.IsTooManyRequests(err) || k8serrors.IsServiceUnavailable(err) ||
+		k8serrors.IsInternalError(err) || k8serrors.IsUnexpectedServerError(err)
+}
+
+func (r *ReconcilePerconaXtraDBClusterRestore) getBackup(cr *api.PerconaXtraDBClusterRestore, cluster *api.PerconaXtraDBCluster) (*api.PerconaXtraDBClusterBackup, error) {
+	if cr.Spec.BackupRef != nil {
+		return r.getBackupRef(cr, cluster)
+	}
+
 	if cr.Spec.BackupSource != nil {
-		return &api.PerconaXtraDBClusterBackup{
+		bcp := &api.PerconaXtraDBClusterBackup{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        cr.Name,
 				Namespace:   cr.Namespace,
@@ -261,7 +489,14 @@ func (r *ReconcilePerconaXtraDBClusterRestore) getBackup(cr *api.PerconaXtraDBCl
 				StorageName: cr.Spec.BackupSource.StorageName,
 				S3:          cr.Spec.BackupSource.S3,
 			},
-		}, nil
+		}
+		if bcp.Status.S3 == nil && strings.HasPrefix(bcp.Status.Destination, "s3://") {
+			err := r.resolveStorage(cr, cluster, bcp)
+			if err != nil {
+				return nil, errors.Wrap(err, "resolve backup storage")
+			}
+		}
+		return bcp, nil
 	}
 
 	bcp := &api.PerconaXtraDBClusterBackup{}
@@ -284,24 +519,59 @@ If everything is fine, you can cleanup the job:
 $ kubectl delete pxc-restore/%s
 `
 
-func (r *ReconcilePerconaXtraDBClusterRestore) stopCluster(c *api.PerconaXtraDBCluster) error {
-	var gracePeriodSec int64
+const cancelledMsg = `Restore was cancelled, cluster %s was not changed.
+`
 
-	if c.Spec.PXC != nil && c.Spec.PXC.TerminationGracePeriodSeconds != nil {
-		gracePeriodSec = int64(c.Spec.PXC.Size) * *c.Spec.PXC.TerminationGracePeriodSeconds
-	}
+const cancelledDataChangedMsg = `Restore was cancelled after the data of cluster %s was changed and there is no pre-restore snapshot to roll back to.
+The cluster is left paused, restore it from a backup.
+`
 
-	c.Spec.Pause = true
+const rolledBackMsg = `Cluster %s was started with the options it had before the restore.
+`
 
-	err := r.client.Update(context.TODO(), c)
-	if err != nil {
-		return errors.Wrap(err, "shutdown pods")
+const rolledBackToSnapshotMsg = `Cluster %s was rolled back to the pre-restore snapshot %s.
+You can delete the snapshot along with the restore:
+$ kubectl delete pxc-restore/<name>
+`
+
+const switchedOverMsg = `Services of cluster %s were switched over to the restored cluster %s.
+The cluster that served before is paused and its data is kept, you can delete it when it isn't needed:
+$ kubectl get pxc/%[2]s -o jsonpath='{.metadata.annotations.percona\.com/replaces}'
+`
+
+const dryRunMsg = `Dry run: cluster %s was not changed.
+You can find the restore plan in the status:
+$ kubectl get pxc-restore/<name> -o jsonpath='{.status.plan}'
+`
+
+// stopCluster pauses the cluster and deletes all PXC PVCs except the first one
+// that is used for restore. It returns true when the cluster is stopped.
+func (r *ReconcilePerconaXtraDBClusterRestore) stopCluster(c *api.PerconaXtraDBCluster) (bool, error) {
+	if !c.Spec.Pause {
+		c.Spec.Pause = true
+		err := r.client.Update(context.TODO(), c)
+		if err != nil {
+			return false, errors.Wrap(err, "shutdown pods")
+		}
+		return false, nil
 	}
 
 	ls := statefulset.NewNode(c).Labels()
-	err = r.waitForPodsShutdown(ls, c.Namespace, gracePeriodSec)
+
+	pods := corev1.PodList{}
+	err := r.client.List(
+		context.TODO(),
+		&pods,
+		&client.ListOptions{
+			Namespace:     c.Namespace,
+			LabelSelector: labels.SelectorFromSet(ls),
+		},
+	)
 	if err != nil {
-		return errors.Wrap(err, "shutdown pods")
+		return false, errors.Wrap(err, "get pods list")
+	}
+	if len(pods.Items) > 0 {
+		return false, nil
 	}
 
 	pvcs := corev1.PersistentVolumeClaimList{}
@@ -314,7 +584,10 @@ func (r *ReconcilePerconaXtraDBClusterRestore) stopCluster(c *api.PerconaXtraDBC
 		},
 	)
 	if err != nil {
-		return errors.Wrap(err, "get pvc list")
+		return false, errors.Wrap(err, "get pvc list")
+	}
+	if len(pvcs.Items) == 1 {
+		return true, nil
 	}
 
 	pxcNode := statefulset.NewNode(c)
@@ -324,122 +597,96 @@ func (r *ReconcilePerconaXtraDBClusterRestore) stopCluster(c *api.PerconaXtraDBC
 		if pvc.Name == pvcNameTemplate+"-0" || !strings.HasPrefix(pvc.Name, pvcNameTemplate) {
 			continue
 		}
+		if pvc.DeletionTimestamp != nil {
+			continue
+		}
 
 		err = r.client.Delete(context.TODO(), &pvc)
-		if err != nil {
-			return errors.Wrap(err, "delete pvc")
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return false, errors.Wrap(err, "delete pvc")
 		}
 	}
 
-	err = r.waitForPVCShutdown(ls, c.Namespace)
-	if err != nil {
-		return errors.Wrap(err, "shutdown pvc")
-	}
-
-	return nil
+	return false, nil
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) startCluster(cr *api.PerconaXtraDBCluster) (err error) {
-	// tryin several times just to avoid possible conflicts with the main controller
-	for i := 0; i < 5; i++ {
-		// need to get the object with latest version of meta-data for update
-		current := &api.PerconaXtraDBCluster{}
-		err = r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, current)
-		if err != nil {
-			return errors.Wrap(err, "get cluster")
-		}
-
-		current.Spec = cr.Spec
-
-		uerr := r.client.Update(context.TODO(), current)
-		if uerr == nil {
-			break
-		}
-		err = errors.Wrap(uerr, "update cluster")
-		time.Sleep(time.Second * 1)
-	}
-	if err != nil {
-		return err
-	}
-
-	// give time for process new state
-	time.Sleep(10 * time.Second)
+// startCluster unpauses the cluster with the given PXC size and unsafe configurations option.
+// It returns true when the cluster is ready.
+func (r *ReconcilePerconaXtraDBClusterRestore) startCluster(c *api.PerconaXtraDBCluster, size int32, allowUnsafe bool) (bool, error) {
+	if c.Spec.Pause || c.Spec.PXC.Size != size || c.Spec.AllowUnsafeConfig != allowUnsafe {
+		c.Spec.Pause = false
+		c.Spec.PXC.Size = size
+		c.Spec.AllowUnsafeConfig = allowUnsafe
 
-	var waitLimit int32 = 2 * 60 * 60 // 2 hours
-	if cr.Spec.PXC.LivenessInitialDelaySeconds != nil {
-		waitLimit = *cr.Spec.PXC.LivenessInitialDelaySeconds * cr.Spec.PXC.Size
-	}
-
-	for i := int32(0); i < waitLimit; i++ {
-		current := &api.PerconaXtraDBCluster{}
-		err = r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, current)
+		err := r.client.Update(context.TODO(), c)
 		if err != nil {
-			return errors.Wrap(err, "get cluster")
-		}
-		if current.Status.ObservedGeneration == current.Generation && current.Status.PXC.Status == api.AppStateReady {
-			return nil
+			return false, errors.Wrap(err, "update cluster")
 		}
-		time.Sleep(time.Second * 1)
+		return false, nil
 	}
 
-	return errors.Errorf("exceeded wait limit")
+	return c.Status.ObservedGeneration == c.Generation && c.Status.PXC.Status == api.AppStateReady, nil
 }
 
 const waitLimitSec int64 = 300
 
-func (r *ReconcilePerconaXtraDBClusterRestore) waitForPodsShutdown(ls map[string]string, namespace string, gracePeriodSec int64) error {
-	for i := int64(0); i < waitLimitSec+gracePeriodSec; i++ {
-		pods := corev1.PodList{}
-
-		err := r.client.List(
-			context.TODO(),
-			&pods,
-			&client.ListOptions{
-				Namespace:     namespace,
-				LabelSelector: labels.SelectorFromSet(ls),
-			},
-		)
-		if err != nil {
-			return errors.Wrap(err, "get pods list")
-		}
-
-		if len(pods.Items) == 0 {
-			return nil
-		}
-
-		time.Sleep(time.Second * 1)
-	}
-
-	return errors.Errorf("exceeded wait limit")
+// stateConditions are the conditions that are reached when the restore leaves the state
+var stateConditions = map[api.BcpRestoreStates]api.RestoreConditionType{
+	api.RestoreStopCluster:  api.RestoreConditionClusterStopped,
+	api.RestoreRestore:      api.RestoreConditionDataRestored,
+	api.RestorePITR:         api.RestoreConditionPITRApplied,
+	api.RestoreStartCluster: api.RestoreConditionClusterReady,
+	api.RestoreRollingBack:  api.RestoreConditionClusterReady,
+	api.RestoreStandby:      api.RestoreConditionDataRestored,
+	api.RestoreSwitchover:   api.RestoreConditionClusterReady,
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) waitForPVCShutdown(ls map[string]string, namespace string) error {
-	for i := int64(0); i < waitLimitSec; i++ {
-		pvcs := corev1.PersistentVolumeClaimList{}
-
-		err := r.client.List(
-			context.TODO(),
-			&pvcs,
-			&client.ListOptions{
-				Namespace:     namespace,
-				LabelSelector: labels.SelectorFromSet(ls),
-			},
-		)
-		if err != nil {
-			return errors.Wrap(err, "get pvc list")
-		}
-
-		if len(pvcs.Items) == 1 {
-			return nil
+// changeState updates conditions and phases of the restore
+// moving from the current state to the given one and records the event about it
+func (r *ReconcilePerconaXtraDBClusterRestore) changeState(cr *api.PerconaXtraDBClusterRestore, state api.BcpRestoreStates, comments string) {
+	now := metav1.NewTime(time.Now())
+	prev := cr.Status.State
+
+	if t, ok := stateConditions[prev]; ok {
+		c := api.ClusterCondition{Type: t, Status: api.ConditionTrue, Reason: "Done", LastTransitionTime: now}
+		switch {
+		case prev == api.RestoreRollingBack:
+			c.Reason = "RolledBack"
+		case state == api.RestoreFailed, state == api.RestoreRollingBack:
+			c.Status, c.Reason, c.Message = api.ConditionFalse, "Failed", comments
+		case state == api.RestoreCancelling:
+			c.Status, c.Reason = api.ConditionFalse, "Cancelled"
 		}
+		cr.Status.SetCondition(c)
+	}
+	if t, ok := stateConditions[state]; ok {
+		cr.Status.SetCondition(api.ClusterCondition{Type: t, Status: api.ConditionFalse, Reason: "InProgress", LastTransitionTime: now})
+	}
 
-		time.Sleep(time.Second * 1)
+	if n := len(cr.Status.Phases); n > 0 && cr.Status.Phases[n-1].FinishedAt == nil {
+		cr.Status.Phases[n-1].FinishedAt = &now
 	}
+	cr.Status.StateChangedAt = &now
 
-	return errors.Errorf("exceeded wait limit")
+	switch state {
+	case api.RestoreSucceeded:
+		r.recorder.Event(cr, corev1.EventTypeNormal, "RestoreSucceeded", "Restore is finished")
+	case api.RestoreCancelled:
+		r.recorder.Event(cr, corev1.EventTypeNormal, "RestoreCancelled", "Restore is cancelled")
+	case api.RestoreFailed:
+		r.recorder.Event(cr, corev1.EventTypeWarning, "RestoreFailed", comments)
+	case api.RestoreRollingBack:
+		r.recorder.Eventf(cr, corev1.EventTypeWarning, "RollingBack", "Rolling back the cluster: %s", comments)
+	default:
+		cr.Status.Phases = append(cr.Status.Phases, api.RestorePhase{State: state, StartedAt: now})
+		r.recorder.Eventf(cr, corev1.EventTypeNormal, "StateChanged", "%s", state)
+	}
 }
 
 func (r *ReconcilePerconaXtraDBClusterRestore) setStatus(cr *api.PerconaXtraDBClusterRestore, state api.BcpRestoreStates, comments string) error {
+	if cr.Status.State != state {
+		r.changeState(cr, state, comments)
+	}
 	cr.Status.State = state
 	switch state {
 	case api.RestoreSucceeded:
@@ -0,0 +1,85 @@
+package pxcrestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestChangeState(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcilePerconaXtraDBClusterRestore{recorder: recorder}
+	cr := &api.PerconaXtraDBClusterRestore{}
+
+	for _, state := range []api.BcpRestoreStates{api.RestoreValidating, api.RestoreStopCluster, api.RestoreRestore} {
+		r.changeState(cr, state, "")
+		cr.Status.State = state
+	}
+	r.changeState(cr, api.RestoreFailed, "job failed")
+	cr.Status.State = api.RestoreFailed
+
+	if c := cr.Status.Condition(api.RestoreConditionClusterStopped); c == nil || c.Status != api.ConditionTrue {
+		t.Errorf("ClusterStopped should be true, got %+v", c)
+	}
+	if c := cr.Status.Condition(api.RestoreConditionDataRestored); c == nil || c.Status != api.ConditionFalse || c.Message != "job failed" {
+		t.Errorf("DataRestored should be false with the failure, got %+v", c)
+	}
+	if c := cr.Status.Condition(api.RestoreConditionClusterReady); c != nil {
+		t.Errorf("ClusterReady shouldn't be set, got %+v", c)
+	}
+
+	if len(cr.Status.Phases) != 3 {
+		t.Fatalf("expected 3 phases, got %d", len(cr.Status.Phases))
+	}
+	for _, p := range cr.Status.Phases {
+		if p.FinishedAt == nil {
+			t.Errorf("phase %s isn't finished", p.State)
+		}
+	}
+
+	if len(recorder.Events) != 4 {
+		t.Errorf("expected 4 events, got %d", len(recorder.Events))
+	}
+}
+
+func TestStateTimeout(t *testing.T) {
+	cluster := &api.PerconaXtraDBCluster{Spec: api.PerconaXtraDBClusterSpec{PXC: &api.PXCSpec{PodSpec: &api.PodSpec{Size: 3}}}}
+
+	for _, state := range []api.BcpRestoreStates{api.RestoreValidating, api.RestoreStandby, api.RestoreRestore, api.RestorePITR, api.RestoreSnapshot, api.RestoreSwitchover} {
+		cr := &api.PerconaXtraDBClusterRestore{Status: api.PerconaXtraDBClusterRestoreStatus{State: state}}
+		if stateTimeout(cr, cluster) <= 0 {
+			t.Errorf("state %q isn't limited in time", state)
+		}
+	}
+
+	cr := &api.PerconaXtraDBClusterRestore{
+		Spec:   api.PerconaXtraDBClusterRestoreSpec{DataTimeout: &metav1.Duration{Duration: 3 * time.Hour}},
+		Status: api.PerconaXtraDBClusterRestoreStatus{State: api.RestorePITR},
+	}
+	if got := stateTimeout(cr, cluster); got != 3*time.Hour {
+		t.Errorf("expected dataTimeout 3h, got %s", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	gr := schema.GroupResource{Resource: "jobs"}
+	tests := map[error]bool{
+		errors.Wrap(k8serrors.NewServerTimeout(gr, "get", 1), "get job"):     true,
+		errors.Wrap(k8serrors.NewTooManyRequests("slow down", 1), "get job"): true,
+		k8serrors.NewInternalError(errors.New("etcd")):                       true,
+		errors.Wrap(k8serrors.NewNotFound(gr, "restore-job"), "get job"):     false,
+		errors.New("job failed"): false,
+	}
+	for err, want := range tests {
+		if got := isTransient(err); got != want {
+			t.Errorf("%v: transient = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package pxcrestore
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+// isDataSource reports whether the restore bootstraps the cluster from spec.dataSource
+func isDataSource(cr *api.PerconaXtraDBClusterRestore) bool {
+	_, ok := cr.Labels[api.DataSourceRestoreLabel]
+	return ok
+}
+
+// createDataVolume creates the volume of the first PXC pod for the cluster bootstrapped
+// from the data source. The cluster doesn't create the PXC statefulset until the data
+// is restored into the volume, the statefulset picks the volume up by its name.
+// It returns true when the volume exists.
+func (r *ReconcilePerconaXtraDBClusterRestore) createDataVolume(c, cluster *api.PerconaXtraDBCluster) (bool, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: dataPVCName(c), Namespace: c.Namespace}, pvc)
+	if err == nil {
+		return true, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return false, errors.Wrap(err, "get pvc")
+	}
+
+	vs := cluster.Spec.PXC.VolumeSpec
+	if vs == nil || vs.PersistentVolumeClaim == nil {
+		return false, errors.New("the data source can be restored only into a persistent volume claim")
+	}
+
+	pvc = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dataPVCName(c),
+			Namespace: c.Namespace,
+			// the statefulset controller labels its pvcs with the selector labels
+			Labels: statefulset.NewNode(cluster).Labels(),
+		},
+		Spec: app.VolumeSpec(vs),
+	}
+
+	return false, r.createIfNotExists(pvc)
+}
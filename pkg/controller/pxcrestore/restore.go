@@ -2,114 +2,528 @@ package pxcrestore
 
 import (
 	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
 	"github.com/percona/percona-xtradb-cluster-operator/pkg/k8s"
 	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/backup"
 )
 
-func (r *ReconcilePerconaXtraDBClusterRestore) restore(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster api.PerconaXtraDBClusterSpec) error {
+// restore runs the restore of the backup into the first PXC PVC.
+// It returns true when the restore is finished.
+func (r *ReconcilePerconaXtraDBClusterRestore) restore(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster api.PerconaXtraDBClusterSpec) (bool, error) {
 	if cluster.Backup == nil {
-		return errors.New("undefined backup section in a cluster spec")
+		return false, errors.New("undefined backup section in a cluster spec")
 	}
 	if len(bcp.Status.Destination) > 6 {
 		switch {
 		case bcp.Status.Destination[:4] == "pvc/":
-			return errors.Wrap(r.restorePVC(cr, bcp, bcp.Status.Destination[4:], cluster), "pvc")
+			done, err := r.restorePVC(cr, bcp, bcp.Status.Destination[4:], cluster)
+			return done, errors.Wrap(err, "pvc")
 		case bcp.Status.Destination[:5] == "s3://":
-			return errors.Wrap(r.restoreS3(cr, bcp, bcp.Status.Destination[5:], cluster, false), "s3")
+			done, err := r.restoreS3(cr, bcp, bcp.Status.Destination[5:], cluster, false)
+			return done, errors.Wrap(err, "s3")
 		}
 	}
 
-	return errors.Errorf("unknown destination %s", bcp.Status.Destination)
+	return false, errors.Errorf("unknown destination %s", bcp.Status.Destination)
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) pitr(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster api.PerconaXtraDBClusterSpec) error {
-	return errors.Wrap(r.restoreS3(cr, bcp, bcp.Status.Destination[5:], cluster, true), "PITR restore")
+// pitr applies binlogs to the running cluster.
+// It returns true when the recovery is finished.
+func (r *ReconcilePerconaXtraDBClusterRestore) pitr(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster api.PerconaXtraDBClusterSpec) (bool, error) {
+	job, err := backup.S3RestoreJob(cr, bcp, bcp.Status.Destination[5:], cluster, true)
+	if err != nil {
+		return false, errors.Wrap(err, "PITR restore")
+	}
+	k8s.SetControllerReference(cr, job, r.scheme)
+
+	done, err := r.ensureJob(job)
+	if err != nil {
+		return false, errors.Wrap(err, "PITR restore")
+	}
+
+	// the progress is only reporting, so the stats are only logged if they can't be read
+	var stats *pitrStats
+	if done {
+		stats, err = r.pitrResult(job)
+	} else if checkProgress(cr) {
+		stats, err = r.pitrProgress(job)
+	}
+	if err != nil {
+		r.logger(cr.Name, cr.Namespace).Info("can't get PITR stats", "error", err.Error())
+	}
+	if stats != nil && cr.Status.Progress != nil {
+		cr.Status.Progress.BinlogsApplied = stats.BinlogsApplied
+		cr.Status.Progress.BytesDownloaded = backupSize(cr) + stats.BytesDownloaded
+		cr.Status.Progress.LastGTID = stats.LastGTID
+	}
+
+	return done, nil
+}
+
+// pitrStats is the output of the PITR job
+type pitrStats struct {
+	BinlogsApplied  int    `json:"binlogsApplied"`
+	BytesDownloaded int64  `json:"bytesDownloaded"`
+	LastGTID        string `json:"lastGTID,omitempty"`
+}
+
+// pitrProgressPrefix marks the lines the PITR job logs after each applied binlog,
+// it has to match the prefix in cmd/pitr/recoverer
+const pitrProgressPrefix = "progress: "
+
+const (
+	// progressInterval is how often the job log is read for the progress
+	progressInterval = 30 * time.Second
+	// progressLogLimit bounds the size of the job log read at once,
+	// the rest is read on the next check
+	progressLogLimit int64 = 1 << 20
+	// pitrProgressTail is the number of the last lines of the PITR job log
+	// the progress is looked for in, each applied binlog adds a few lines
+	pitrProgressTail int64 = 50
+)
+
+// checkProgress reports whether it's time to read the job log for the progress
+// and marks the progress as checked if it is
+func checkProgress(cr *api.PerconaXtraDBClusterRestore) bool {
+	p := cr.Status.Progress
+	if p == nil {
+		return false
+	}
+	if p.CheckedAt != nil && time.Since(p.CheckedAt.Time) < progressInterval {
+		return false
+	}
+
+	now := metav1.Now()
+	p.CheckedAt = &now
+	return true
+}
+
+// xbcloudChunkRe matches the lines xbcloud logs for each downloaded chunk of the backup
+var xbcloudChunkRe = regexp.MustCompile(`successfully downloaded chunk:? \S+?,? size:? (\d+)`)
+
+// pitrResult returns the stats the finished PITR job has written to the termination message
+func (r *ReconcilePerconaXtraDBClusterRestore) pitrResult(job *batchv1.Job) (*pitrStats, error) {
+	msg, err := r.jobTerminationMessage(job, corev1.PodSucceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &pitrStats{}
+	err = json.Unmarshal([]byte(msg), stats)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal stats")
+	}
+
+	return stats, nil
+}
+
+// pitrProgress returns the stats of the binlogs the running PITR job has applied so far.
+// The progress lines are cumulative, so only the tail of the log is read.
+func (r *ReconcilePerconaXtraDBClusterRestore) pitrProgress(job *batchv1.Job) (*pitrStats, error) {
+	tail, limit := pitrProgressTail, progressLogLimit
+	logs, err := r.jobLogs(job, &corev1.PodLogOptions{TailLines: &tail, LimitBytes: &limit})
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePITRProgress(logs)
+}
+
+// parsePITRProgress returns the stats from the last progress line of the PITR job log
+// or nil if no binlog has been applied yet
+func parsePITRProgress(logs []string) (*pitrStats, error) {
+	for i := len(logs) - 1; i >= 0; i-- {
+		idx := strings.Index(logs[i], pitrProgressPrefix)
+		if idx < 0 {
+			continue
+		}
+
+		stats := &pitrStats{}
+		err := json.Unmarshal([]byte(logs[i][idx+len(pitrProgressPrefix):]), stats)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshal progress %q", logs[i])
+		}
+		return stats, nil
+	}
+
+	return nil, nil
+}
+
+// downloadedBytes sums the size of the backup chunks xbcloud has reported as downloaded
+// in the log lines written after the given time. The lines have to be prefixed with
+// the timestamps, the time of the last line is returned to read the log from on the next check.
+func downloadedBytes(logs []string, after *metav1.MicroTime) (int64, *metav1.MicroTime) {
+	var n int64
+	last := after
+	for _, l := range logs {
+		i := strings.IndexByte(l, ' ')
+		if i < 0 {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, l[:i])
+		if err != nil {
+			continue
+		}
+		// the status keeps the time in microseconds
+		ts = ts.Truncate(time.Microsecond)
+		// SinceTime is in seconds, so the lines counted on the previous check are read again
+		if last != nil && !ts.After(last.Time) {
+			continue
+		}
+		t := metav1.NewMicroTime(ts)
+		last = &t
+
+		m := xbcloudChunkRe.FindStringSubmatch(l[i+1:])
+		if m == nil {
+			continue
+		}
+		size, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		n += size
+	}
+
+	return n, last
+}
+
+// backupSize returns the size of the backup the restore downloads
+func backupSize(cr *api.PerconaXtraDBClusterRestore) int64 {
+	if cr.Status.Plan == nil {
+		return 0
+	}
+	return cr.Status.Plan.BackupSize
+}
+
+// jobLogs returns the log of the running pod of the job
+func (r *ReconcilePerconaXtraDBClusterRestore) jobLogs(job *batchv1.Job, opts *corev1.PodLogOptions) ([]string, error) {
+	if r.clientcmd == nil {
+		return nil, errors.New("no client to read the pod logs")
+	}
+
+	pods := corev1.PodList{}
+	err := r.client.List(
+		context.TODO(),
+		&pods,
+		&client.ListOptions{
+			Namespace:     job.Namespace,
+			LabelSelector: labels.SelectorFromSet(map[string]string{"job-name": job.Name}),
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "get job pods")
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		opts.Container = job.Spec.Template.Spec.Containers[0].Name
+		logs, err := r.clientcmd.PodLogs(pod.Namespace, pod.Name, opts)
+		return logs, errors.Wrapf(err, "get logs from %s pod", pod.Name)
+	}
+
+	return nil, nil
+}
+
+// validationResult is the output of the validation job
+type validationResult struct {
+	BackupServerVersion string           `json:"backupServerVersion,omitempty"`
+	BackupSize          int64            `json:"backupSize,omitempty"`
+	Plan                *api.RestorePlan `json:"plan,omitempty"`
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) restorePVC(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, pvcName string, cluster api.PerconaXtraDBClusterSpec) error {
+// validate checks that the restore can be done while the cluster is still running.
+// It returns nil plan until all the checks are done.
+func (r *ReconcilePerconaXtraDBClusterRestore) validate(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, cluster *api.PerconaXtraDBCluster) (*api.RestorePlan, error) {
+	if cluster.Spec.Backup == nil {
+		return nil, errors.New("undefined backup section in a cluster spec")
+	}
+
+	plan := &api.RestorePlan{
+		BackupDestination: bcp.Status.Destination,
+	}
+
+	switch {
+	case strings.HasPrefix(bcp.Status.Destination, "pvc/"):
+		if cr.Spec.PITR != nil {
+			return nil, errors.Errorf("point-in-time recovery isn't supported for destination %s", bcp.Status.Destination)
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		name := strings.TrimPrefix(bcp.Status.Destination, "pvc/")
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cr.Namespace}, pvc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get backup pvc %s", name)
+		}
+		if pvc.Status.Phase != corev1.ClaimBound {
+			return nil, errors.Errorf("backup pvc %s is %s", name, pvc.Status.Phase)
+		}
+		return plan, nil
+	case !strings.HasPrefix(bcp.Status.Destination, "s3://"):
+		return nil, errors.Errorf("unknown destination %s", bcp.Status.Destination)
+	}
+
+	job, err := backup.RestoreValidateJob(cr, bcp, strings.TrimPrefix(bcp.Status.Destination, "s3://"), cluster.Spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "validate job")
+	}
+	k8s.SetControllerReference(cr, job, r.scheme)
+
+	done, err := r.ensureJob(job)
+	if err != nil {
+		// the job writes the reason of the failure to the termination message
+		if msg, merr := r.jobTerminationMessage(job, corev1.PodFailed); merr == nil {
+			return nil, errors.New(msg)
+		}
+		return nil, errors.Wrap(err, "run validate job")
+	}
+	if !done {
+		return nil, nil
+	}
+
+	msg, err := r.jobTerminationMessage(job, corev1.PodSucceeded)
+	if err != nil {
+		return nil, errors.Wrap(err, "get validation result")
+	}
+
+	res := validationResult{}
+	err = json.Unmarshal([]byte(msg), &res)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal validation result %s", msg)
+	}
+
+	err = checkVersion(res.BackupServerVersion, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Plan != nil {
+		plan = res.Plan
+		plan.BackupDestination = bcp.Status.Destination
+	}
+	plan.BackupServerVersion = res.BackupServerVersion
+	plan.BackupSize = res.BackupSize
+
+	return plan, nil
+}
+
+// checkVersion checks that the backup was taken from the server
+// of the same major version as the cluster runs
+func checkVersion(backupVersion string, cluster *api.PerconaXtraDBCluster) error {
+	clusterVersion := cluster.Status.PXC.Version
+	if clusterVersion == "" {
+		// the version service can be disabled, use the image tag then
+		if i := strings.LastIndex(cluster.Spec.PXC.Image, ":"); i != -1 {
+			clusterVersion = cluster.Spec.PXC.Image[i+1:]
+		}
+	}
+
+	bv, cv := majorVersion(backupVersion), majorVersion(clusterVersion)
+	if bv == "" || cv == "" {
+		// nothing to compare with
+		return nil
+	}
+	if bv != cv {
+		return errors.Errorf("backup is taken from PXC %s, it can't be restored to the cluster with PXC %s", backupVersion, clusterVersion)
+	}
+
+	return nil
+}
+
+// majorVersion returns "x.y" part of the version like 8.0.22-13.1
+// or an empty string if it isn't a version
+func majorVersion(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	for _, p := range parts[:2] {
+		if _, err := strconv.Atoi(p); err != nil {
+			return ""
+		}
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// jobTerminationMessage returns the termination message of the job pod in the given phase
+func (r *ReconcilePerconaXtraDBClusterRestore) jobTerminationMessage(job *batchv1.Job, phase corev1.PodPhase) (string, error) {
+	pods := corev1.PodList{}
+	err := r.client.List(
+		context.TODO(),
+		&pods,
+		&client.ListOptions{
+			Namespace:     job.Namespace,
+			LabelSelector: labels.SelectorFromSet(map[string]string{"job-name": job.Name}),
+		},
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "get job pods")
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != phase {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Terminated != nil && len(cs.State.Terminated.Message) > 0 {
+				return cs.State.Terminated.Message, nil
+			}
+		}
+	}
+
+	return "", errors.Errorf("no termination message in job %s pods", job.Name)
+}
+
+func (r *ReconcilePerconaXtraDBClusterRestore) restorePVC(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, pvcName string, cluster api.PerconaXtraDBClusterSpec) (bool, error) {
 	svc := backup.PVCRestoreService(cr)
 	k8s.SetControllerReference(cr, svc, r.scheme)
 	pod, err := backup.PVCRestorePod(cr, bcp.Status.StorageName, pvcName, cluster)
 	if err != nil {
-		return errors.Wrap(err, "restore pod")
+		return false, errors.Wrap(err, "restore pod")
 	}
 	k8s.SetControllerReference(cr, pod, r.scheme)
 
 	job, err := backup.PVCRestoreJob(cr, cluster)
 	if err != nil {
-		return errors.Wrap(err, "restore job")
+		return false, errors.Wrap(err, "restore job")
 	}
 	k8s.SetControllerReference(cr, job, r.scheme)
 
-	r.client.Delete(context.TODO(), svc)
-	r.client.Delete(context.TODO(), pod)
-
-	err = r.client.Create(context.TODO(), svc)
-	if err != nil {
-		return errors.Wrap(err, "create service")
-	}
-	err = r.client.Create(context.TODO(), pod)
-	if err != nil {
-		return errors.Wrap(err, "create pod")
+	currentJob := &batchv1.Job{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, currentJob)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, errors.Wrap(err, "get job")
 	}
 
-	for {
-		time.Sleep(time.Second * 1)
+	if k8serrors.IsNotFound(err) {
+		// the job can be started only when the source pod is running
+		err = r.createIfNotExists(svc)
+		if err != nil {
+			return false, errors.Wrap(err, "create service")
+		}
+		err = r.createIfNotExists(pod)
+		if err != nil {
+			return false, errors.Wrap(err, "create pod")
+		}
 
-		err := r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, pod)
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, pod)
 		if err != nil {
-			return errors.Wrap(err, "get pod status")
+			if k8serrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, errors.Wrap(err, "get pod status")
 		}
-		if pod.Status.Phase == corev1.PodRunning {
-			break
+		if pod.Status.Phase != corev1.PodRunning {
+			return false, nil
 		}
+
+		return false, r.createIfNotExists(job)
 	}
 
-	defer func() {
-		r.client.Delete(context.TODO(), svc)
-		r.client.Delete(context.TODO(), pod)
-	}()
+	done, err := jobFinished(currentJob)
+	if !done {
+		return false, nil
+	}
 
-	return r.createJob(job)
+	r.client.Delete(context.TODO(), svc)
+	r.client.Delete(context.TODO(), pod)
+
+	return true, err
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) restoreS3(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, s3dest string, cluster api.PerconaXtraDBClusterSpec, pitr bool) error {
+func (r *ReconcilePerconaXtraDBClusterRestore) restoreS3(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, s3dest string, cluster api.PerconaXtraDBClusterSpec, pitr bool) (bool, error) {
 	job, err := backup.S3RestoreJob(cr, bcp, s3dest, cluster, pitr)
 	if err != nil {
-		return err
+		return false, err
 	}
 	k8s.SetControllerReference(cr, job, r.scheme)
 
-	return r.createJob(job)
+	done, err := r.ensureJob(job)
+	if err != nil || done || !checkProgress(cr) {
+		return done, err
+	}
+
+	// the log is read from the last counted line, BytesDownloaded keeps the running total
+	p := cr.Status.Progress
+	limit := progressLogLimit
+	opts := &corev1.PodLogOptions{Timestamps: true, LimitBytes: &limit}
+	if p.LogTime != nil {
+		since := metav1.NewTime(p.LogTime.Time)
+		opts.SinceTime = &since
+	}
+
+	// the progress is only reporting, so the restore goes on if the log can't be read
+	logs, err := r.jobLogs(job, opts)
+	if err != nil {
+		r.logger(cr.Name, cr.Namespace).Info("can't get restore progress", "error", err.Error())
+		return false, nil
+	}
+	n, last := downloadedBytes(logs, p.LogTime)
+	p.BytesDownloaded += n
+	p.LogTime = last
+	// a retried chunk is reported again
+	if size := backupSize(cr); size > 0 && p.BytesDownloaded > size {
+		p.BytesDownloaded = size
+	}
+
+	return false, nil
 }
 
-func (r *ReconcilePerconaXtraDBClusterRestore) createJob(job *batchv1.Job) error {
-	err := r.client.Create(context.TODO(), job)
+// ensureJob creates the job if it doesn't exist yet and reports whether the job is finished.
+// An error is returned if the job has failed.
+func (r *ReconcilePerconaXtraDBClusterRestore) ensureJob(job *batchv1.Job) (bool, error) {
+	currentJob := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, currentJob)
+	if k8serrors.IsNotFound(err) {
+		return false, r.createIfNotExists(job)
+	}
 	if err != nil {
-		return errors.Wrap(err, "create job")
+		return false, errors.Wrap(err, "get job status")
 	}
 
-	for {
-		time.Sleep(time.Second * 1)
+	return jobFinished(currentJob)
+}
+
+func (r *ReconcilePerconaXtraDBClusterRestore) createIfNotExists(obj runtime.Object) error {
+	err := r.client.Create(context.TODO(), obj)
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "create %s", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
 
-		checkJob := batchv1.Job{}
-		err := r.client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &checkJob)
-		if err != nil && !k8serrors.IsNotFound(err) {
-			return errors.Wrap(err, "get job status")
+	return nil
+}
+
+// jobFinished reports whether the job is finished.
+// An error is returned if the job has failed.
+func jobFinished(job *batchv1.Job) (bool, error) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
 		}
-		for _, cond := range checkJob.Status.Conditions {
-			if cond.Type == batchv1.JobComplete && cond.Status == corev1.ConditionTrue {
-				return nil
-			}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return true, errors.Errorf("job %s failed: %s", job.Name, cond.Message)
 		}
 	}
+
+	return false, nil
 }
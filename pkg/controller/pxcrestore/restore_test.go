@@ -0,0 +1,89 @@
+package pxcrestore
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestCheckVersion(t *testing.T) {
+	cases := []struct {
+		backup  string
+		status  string
+		image   string
+		wantErr bool
+	}{
+		{"8.0.22-13", "8.0.21-12.1", "", false},
+		{"5.7.32-35-57", "8.0.22-13.1", "", true},
+		{"8.0.22-13", "", "percona/percona-xtradb-cluster:5.7.33-31.49", true},
+		{"8.0.22-13", "", "percona/percona-xtradb-cluster:8.0.22-13.1", false},
+		{"8.0.22-13", "", "registry:5000/percona-xtradb-cluster", false},
+		{"", "8.0.22-13.1", "", false},
+	}
+
+	for _, c := range cases {
+		cluster := &api.PerconaXtraDBCluster{}
+		cluster.Status.PXC.Version = c.status
+		cluster.Spec.PXC = &api.PXCSpec{PodSpec: &api.PodSpec{Image: c.image}}
+
+		err := checkVersion(c.backup, cluster)
+		if (err != nil) != c.wantErr {
+			t.Errorf("backup %q, cluster %q/%q: unexpected error %v", c.backup, c.status, c.image, err)
+		}
+	}
+}
+
+func TestParsePITRProgress(t *testing.T) {
+	stats, err := parsePITRProgress([]string{"2021/06/01 10:00:00 working with binlog_1"})
+	if err != nil || stats != nil {
+		t.Fatalf("unexpected progress %v, error %v", stats, err)
+	}
+
+	stats, err = parsePITRProgress([]string{
+		`2021/06/01 10:00:00 progress: {"binlogsApplied":1,"bytesDownloaded":100,"lastGTID":"a:1-5"}`,
+		`2021/06/01 10:00:01 progress: {"binlogsApplied":2,"bytesDownloaded":250,"lastGTID":"a:1-9"}`,
+		"2021/06/01 10:00:02 working with binlog_3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BinlogsApplied != 2 || stats.BytesDownloaded != 250 || stats.LastGTID != "a:1-9" {
+		t.Errorf("unexpected progress %+v", stats)
+	}
+
+	_, err = parsePITRProgress([]string{"2021/06/01 10:00:00 progress: {"})
+	if err == nil {
+		t.Error("expected an error for the broken progress line")
+	}
+}
+
+func TestDownloadedBytes(t *testing.T) {
+	logs := []string{
+		"2021-06-01T10:00:00.100000000Z 210601 10:00:00 xbcloud: successfully downloaded chunk backup/ibdata1.00000000000000000000, size: 1000",
+		"2021-06-01T10:00:01.200000000Z 210601 10:00:01 xbcloud: successfully downloaded chunk backup/ibdata1.00000000000000000001, size 500",
+		"2021-06-01T10:00:01.300000000Z 210601 10:00:01 xbcloud: downloading chunk backup/ibdata1.00000000000000000002",
+	}
+
+	n, last := downloadedBytes(logs, nil)
+	if n != 1500 {
+		t.Errorf("downloadedBytes() = %d, want 1500", n)
+	}
+	if last == nil || !last.Time.Equal(time.Date(2021, 6, 1, 10, 0, 1, 300000000, time.UTC)) {
+		t.Errorf("unexpected last line time %v", last)
+	}
+
+	// the lines up to the last counted one are read again from the start of its second
+	after := metav1.NewMicroTime(time.Date(2021, 6, 1, 10, 0, 0, 100000000, time.UTC))
+	n, _ = downloadedBytes(logs, &after)
+	if n != 500 {
+		t.Errorf("downloadedBytes() after the first line = %d, want 500", n)
+	}
+
+	n, last = downloadedBytes(nil, &after)
+	if n != 0 || last != &after {
+		t.Errorf("unexpected %d bytes, last line time %v for the empty log", n, last)
+	}
+}
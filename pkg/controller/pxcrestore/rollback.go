@@ -0,0 +1,281 @@
+package pxcrestore
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/k8s"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app/statefulset"
+)
+
+const snapshotAPIGroup = "snapshot.storage.k8s.io"
+
+// snapshotAPIVersions are the VolumeSnapshot API versions in the order of preference.
+// v1beta1 is used on clusters that don't serve v1 yet.
+var snapshotAPIVersions = []string{snapshotAPIGroup + "/v1", snapshotAPIGroup + "/v1beta1"}
+
+func snapshotName(cr *api.PerconaXtraDBClusterRestore) string {
+	return cr.Name + "-pre-restore"
+}
+
+func dataPVCName(c *api.PerconaXtraDBCluster) string {
+	return statefulset.DataVolumeName + "-" + statefulset.NewNode(c).StatefulSet().Name + "-0"
+}
+
+// canRollback reports whether the cluster can be brought back after the restore failed
+// or was cancelled in the current state. It's possible until the data is touched
+// or if there is a pre-restore snapshot to return the data from.
+func canRollback(cr *api.PerconaXtraDBClusterRestore) bool {
+	// the cluster bootstrapped from the data source has no data to go back to
+	if cr.Status.Cluster == nil || isDataSource(cr) {
+		return false
+	}
+
+	state := cr.Status.State
+	if state == api.RestoreCancelling {
+		state = stateBeforeCancel(cr)
+	}
+
+	switch state {
+	case api.RestoreStopCluster, api.RestoreSnapshot:
+		return true
+	case api.RestoreRestore, api.RestorePreparePITR, api.RestorePITR, api.RestoreStartCluster:
+		return cr.Status.Snapshot != ""
+	}
+
+	return false
+}
+
+// stateBeforeCancel returns the state the restore was cancelled in
+func stateBeforeCancel(cr *api.PerconaXtraDBClusterRestore) api.BcpRestoreStates {
+	for i := len(cr.Status.Phases) - 1; i >= 0; i-- {
+		if s := cr.Status.Phases[i].State; s != api.RestoreCancelling {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// snapshot takes the VolumeSnapshot of the first PXC PVC.
+// It returns true when the snapshot is ready to use.
+func (r *ReconcilePerconaXtraDBClusterRestore) snapshot(cr *api.PerconaXtraDBClusterRestore, c *api.PerconaXtraDBCluster) (bool, error) {
+	snap, err := r.getSnapshot(cr.Namespace, snapshotName(cr))
+	if k8serrors.IsNotFound(err) {
+		return false, r.createSnapshot(cr, c)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "get snapshot")
+	}
+
+	if msg, ok, _ := unstructured.NestedString(snap.Object, "status", "error", "message"); ok && msg != "" {
+		return false, errors.Errorf("snapshot %s: %s", snap.GetName(), msg)
+	}
+	ready, _, _ := unstructured.NestedBool(snap.Object, "status", "readyToUse")
+
+	return ready, nil
+}
+
+func (r *ReconcilePerconaXtraDBClusterRestore) getSnapshot(namespace, name string) (*unstructured.Unstructured, error) {
+	var err error
+	for _, v := range snapshotAPIVersions {
+		snap := &unstructured.Unstructured{}
+		snap.SetAPIVersion(v)
+		snap.SetKind("VolumeSnapshot")
+		err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, snap)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		return snap, err
+	}
+
+	return nil, errors.Wrap(err, "VolumeSnapshot API isn't available")
+}
+
+func (r *ReconcilePerconaXtraDBClusterRestore) createSnapshot(cr *api.PerconaXtraDBClusterRestore, c *api.PerconaXtraDBCluster) error {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": dataPVCName(c),
+		},
+	}
+	if cr.Spec.Rollback.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = cr.Spec.Rollback.VolumeSnapshotClassName
+	}
+
+	var err error
+	for _, v := range snapshotAPIVersions {
+		snap := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		snap.SetAPIVersion(v)
+		snap.SetKind("VolumeSnapshot")
+		snap.SetName(snapshotName(cr))
+		snap.SetNamespace(cr.Namespace)
+		err = k8s.SetControllerReference(cr, snap, r.scheme)
+		if err != nil {
+			return errors.Wrap(err, "set controller reference")
+		}
+
+		err = r.createIfNotExists(snap)
+		if meta.IsNoMatchError(errors.Cause(err)) {
+			continue
+		}
+		return err
+	}
+
+	return errors.Wrap(err, "VolumeSnapshot API isn't available")
+}
+
+// deleteRestoreObjects deletes the jobs, pods and services the restore has created.
+// It returns true when all of them are gone.
+func (r *ReconcilePerconaXtraDBClusterRestore) deleteRestoreObjects(cr *api.PerconaXtraDBClusterRestore) (bool, error) {
+	jobs := batchv1.JobList{}
+	pods := corev1.PodList{}
+	svcs := corev1.ServiceList{}
+
+	var objs []runtime.Object
+	for _, list := range []runtime.Object{&jobs, &pods, &svcs} {
+		err := r.client.List(context.TODO(), list, &client.ListOptions{Namespace: cr.Namespace})
+		if err != nil {
+			return false, errors.Wrap(err, "list restore objects")
+		}
+	}
+	for i := range jobs.Items {
+		objs = append(objs, &jobs.Items[i])
+	}
+	for i := range pods.Items {
+		objs = append(objs, &pods.Items[i])
+	}
+	for i := range svcs.Items {
+		objs = append(objs, &svcs.Items[i])
+	}
+
+	done := true
+	for _, obj := range objs {
+		o := obj.(metav1.Object)
+		if !isOwnedBy(o, cr) {
+			continue
+		}
+		done = false
+		if o.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		err := r.client.Delete(context.TODO(), obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "delete %s", o.GetName())
+		}
+	}
+
+	return done, nil
+}
+
+func isOwnedBy(obj metav1.Object, cr *api.PerconaXtraDBClusterRestore) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == cr.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// rollback brings the cluster back with the options it had before the restore.
+// If there is the pre-restore snapshot, the first PXC PVC is recreated from it first.
+// It returns true when the cluster is ready.
+func (r *ReconcilePerconaXtraDBClusterRestore) rollback(cr *api.PerconaXtraDBClusterRestore, c *api.PerconaXtraDBCluster) (bool, error) {
+	if cr.Status.Snapshot != "" {
+		done, err := r.restorePVCFromSnapshot(c, cr.Status.Snapshot)
+		if err != nil || !done {
+			return false, errors.Wrap(err, "restore pvc from snapshot")
+		}
+	}
+
+	size, unsafe := c.Spec.PXC.Size, c.Spec.AllowUnsafeConfig
+	if cr.Status.Cluster != nil {
+		size, unsafe = cr.Status.Cluster.PXCSize, cr.Status.Cluster.AllowUnsafeConfig
+	}
+
+	return r.startCluster(c, size, unsafe)
+}
+
+// restorePVCFromSnapshot replaces the first PXC PVC with the one provisioned from the snapshot.
+// It returns true when the PVC is replaced.
+func (r *ReconcilePerconaXtraDBClusterRestore) restorePVCFromSnapshot(c *api.PerconaXtraDBCluster, snapshot string) (bool, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: dataPVCName(c), Namespace: c.Namespace}, pvc)
+	if err == nil {
+		if ds := pvc.Spec.DataSource; ds != nil && ds.Kind == "VolumeSnapshot" && ds.Name == snapshot {
+			return true, nil
+		}
+
+		// the pvc can't be deleted while pods use it
+		done, err := r.stopCluster(c)
+		if err != nil || !done {
+			return false, errors.Wrap(err, "stop cluster")
+		}
+		if pvc.DeletionTimestamp != nil {
+			return false, nil
+		}
+		err = r.client.Delete(context.TODO(), pvc)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return false, errors.Wrap(err, "delete pvc")
+		}
+		return false, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return false, errors.Wrap(err, "get pvc")
+	}
+
+	sts := &appsv1.StatefulSet{}
+	stsName := statefulset.NewNode(c).StatefulSet().Name
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: stsName, Namespace: c.Namespace}, sts)
+	if err != nil {
+		return false, errors.Wrapf(err, "get statefulset %s", stsName)
+	}
+
+	var tmpl *corev1.PersistentVolumeClaim
+	for i := range sts.Spec.VolumeClaimTemplates {
+		if sts.Spec.VolumeClaimTemplates[i].Name == statefulset.DataVolumeName {
+			tmpl = &sts.Spec.VolumeClaimTemplates[i]
+		}
+	}
+	if tmpl == nil {
+		return false, errors.Errorf("statefulset %s has no %s volume claim template", stsName, statefulset.DataVolumeName)
+	}
+
+	pvc = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dataPVCName(c),
+			Namespace: c.Namespace,
+			Labels:    make(map[string]string),
+		},
+		Spec: *tmpl.Spec.DeepCopy(),
+	}
+	// the statefulset controller labels its pvcs with the selector labels
+	for k, v := range tmpl.Labels {
+		pvc.Labels[k] = v
+	}
+	if sts.Spec.Selector != nil {
+		for k, v := range sts.Spec.Selector.MatchLabels {
+			pvc.Labels[k] = v
+		}
+	}
+	apiGroup := snapshotAPIGroup
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshot,
+	}
+
+	return false, r.createIfNotExists(pvc)
+}
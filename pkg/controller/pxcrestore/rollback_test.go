@@ -0,0 +1,48 @@
+package pxcrestore
+
+import (
+	"testing"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+)
+
+func TestCanRollback(t *testing.T) {
+	cases := []struct {
+		state    api.BcpRestoreStates
+		cluster  bool
+		snapshot string
+		want     bool
+		// cancelledIn is the state the restore was cancelled in
+		cancelledIn api.BcpRestoreStates
+	}{
+		{api.RestoreNew, false, "", false, ""},
+		{api.RestoreValidating, false, "", false, ""},
+		{api.RestoreStopCluster, true, "", true, ""},
+		{api.RestoreSnapshot, true, "", true, ""},
+		{api.RestoreRestore, true, "", false, ""},
+		{api.RestoreRestore, true, "snap", true, ""},
+		{api.RestorePITR, true, "snap", true, ""},
+		{api.RestoreStartCluster, true, "", false, ""},
+		{api.RestoreRollingBack, true, "snap", false, ""},
+		{api.RestoreCancelling, true, "snap", false, ""},
+		{api.RestoreCancelling, true, "", true, api.RestoreStopCluster},
+		{api.RestoreCancelling, true, "", false, api.RestoreRestore},
+		{api.RestoreCancelling, true, "snap", true, api.RestoreRestore},
+	}
+
+	for _, c := range cases {
+		cr := &api.PerconaXtraDBClusterRestore{}
+		cr.Status.State = c.state
+		cr.Status.Snapshot = c.snapshot
+		if c.cancelledIn != "" {
+			cr.Status.Phases = []api.RestorePhase{{State: c.cancelledIn}, {State: api.RestoreCancelling}}
+		}
+		if c.cluster {
+			cr.Status.Cluster = &api.RestoreClusterOptions{PXCSize: 3}
+		}
+
+		if got := canRollback(cr); got != c.want {
+			t.Errorf("state %q, cancelled in %q, snapshot %q: got %v, want %v", c.state, c.cancelledIn, c.snapshot, got, c.want)
+		}
+	}
+}
@@ -79,6 +79,12 @@ func GetBinlogCollectorDeployment(cr *api.PerconaXtraDBCluster) (appsv1.Deployme
 			Value: strconv.FormatInt(bufferSize, 10),
 		},
 	}
+	if len(cr.Spec.Backup.PITR.Compression) > 0 {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "COMPRESSION",
+			Value: cr.Spec.Backup.PITR.Compression,
+		})
+	}
 	if len(storage.S3.EndpointURL) > 0 {
 		envs = append(envs, corev1.EnvVar{
 			Name:  "ENDPOINT",
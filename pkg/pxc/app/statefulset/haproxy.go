@@ -365,6 +365,12 @@ func (c *HAProxy) UpdateStrategy(cr *api.PerconaXtraDBCluster) appsv1.StatefulSe
 	switch cr.Spec.UpdateStrategy {
 	case appsv1.OnDeleteStatefulSetStrategyType:
 		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	case api.SmartUpdateStatefulSetStrategyType:
+		// the operator drains and restarts the proxies one by one
+		if cr.CompareVersionWith("1.9.0") >= 0 {
+			return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+		}
+		fallthrough
 	default:
 		var zero int32 = 0
 		return appsv1.StatefulSetUpdateStrategy{
@@ -494,11 +494,14 @@ func (c *Node) UpdateStrategy(cr *api.PerconaXtraDBCluster) appsv1.StatefulSetUp
 	case api.SmartUpdateStatefulSetStrategyType:
 		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
 	default:
-		var zero int32 = 0
+		var partition int32 = 0
+		if cr.Spec.UpgradeOptions.Partition != nil {
+			partition = *cr.Spec.UpgradeOptions.Partition
+		}
 		return appsv1.StatefulSetUpdateStrategy{
 			Type: appsv1.RollingUpdateStatefulSetStrategyType,
 			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
-				Partition: &zero,
+				Partition: &partition,
 			},
 		}
 	}
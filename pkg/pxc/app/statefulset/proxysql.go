@@ -401,6 +401,10 @@ func (c *Proxy) UpdateStrategy(cr *api.PerconaXtraDBCluster) appsv1.StatefulSetU
 	case appsv1.OnDeleteStatefulSetStrategyType:
 		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
 	case api.SmartUpdateStatefulSetStrategyType:
+		// the operator drains and restarts the proxies one by one
+		if cr.CompareVersionWith("1.9.0") >= 0 {
+			return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+		}
 		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType}
 	default:
 		var zero int32 = 0
@@ -0,0 +1,158 @@
+package statefulset
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/percona/percona-xtradb-cluster-operator/pkg/apis/pxc/v1"
+	"github.com/percona/percona-xtradb-cluster-operator/pkg/pxc/app"
+	"github.com/pkg/errors"
+)
+
+const ReplicaName = "replicas"
+
+// Replica is the read-only MySQL node that replicates asynchronously from the cluster.
+// It runs the PXC image without Galera and shares the PXC configuration and secrets.
+type Replica struct {
+	*Node
+	sfs     *appsv1.StatefulSet
+	labels  map[string]string
+	service string
+}
+
+func NewReplica(cr *api.PerconaXtraDBCluster) *Replica {
+	sfs := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "StatefulSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-" + ReplicaName,
+			Namespace: cr.Namespace,
+		},
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "percona-xtradb-cluster",
+		"app.kubernetes.io/instance":   cr.Name,
+		"app.kubernetes.io/component":  ReplicaName,
+		"app.kubernetes.io/managed-by": "percona-xtradb-cluster-operator",
+		"app.kubernetes.io/part-of":    "percona-xtradb-cluster",
+	}
+
+	return &Replica{
+		Node:    NewNode(cr),
+		sfs:     sfs,
+		labels:  labels,
+		service: cr.Name + "-" + ReplicaName + "-unready",
+	}
+}
+
+func (c *Replica) Name() string {
+	return ReplicaName
+}
+
+func (c *Replica) AppContainer(spec *api.PodSpec, secrets string, cr *api.PerconaXtraDBCluster) (corev1.Container, error) {
+	appc, err := c.Node.AppContainer(spec, secrets, cr)
+	if err != nil {
+		return appc, err
+	}
+
+	appc.Command = []string{"/var/lib/mysql/replica-entrypoint.sh"}
+	appc.ReadinessProbe.Exec.Command = []string{"/var/lib/mysql/replica-check.sh", "readiness"}
+	appc.LivenessProbe.Exec.Command = []string{"/var/lib/mysql/replica-check.sh", "liveness"}
+
+	// replicas aren't cluster members
+	ports := make([]corev1.ContainerPort, 0, len(appc.Ports))
+	for _, p := range appc.Ports {
+		switch p.Name {
+		case "sst", "write-set", "ist":
+			continue
+		}
+		ports = append(ports, p)
+	}
+	appc.Ports = ports
+
+	return appc, nil
+}
+
+// BootstrapContainer copies the data from a cluster node with xtrabackup
+// into the empty datadir of the replica. The copy is requested the same way as
+// by the backup jobs, the donor checks the xtrabackup user password, so the container
+// needs it. The replica replicates with the replicas user afterwards.
+func (c *Replica) BootstrapContainer(spec *api.PodSpec, secrets string, cr *api.PerconaXtraDBCluster) (corev1.Container, error) {
+	ct := corev1.Container{
+		Name:            "replica-bootstrap",
+		Image:           cr.Spec.Backup.Image,
+		ImagePullPolicy: cr.Spec.Backup.ImagePullPolicy,
+		Command:         []string{"/var/lib/mysql/replica-bootstrap.sh"},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "PXC_SERVICE",
+				Value: cr.Name + "-" + app.Name,
+			},
+			{
+				Name: "PXC_PASS",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: app.SecretKeySelector(secrets, "xtrabackup"),
+				},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      DataVolumeName,
+				MountPath: "/var/lib/mysql",
+			},
+			{
+				Name:      "ssl",
+				MountPath: "/etc/mysql/ssl",
+			},
+			{
+				Name:      "ssl-internal",
+				MountPath: "/etc/mysql/ssl-internal",
+			},
+			{
+				Name:      VaultSecretVolumeName,
+				MountPath: "/etc/mysql/vault-keyring-secret",
+			},
+		},
+		SecurityContext: spec.ContainerSecurityContext,
+	}
+
+	res, err := app.CreateResources(spec.Resources)
+	if err != nil {
+		return ct, errors.Wrap(err, "create resources")
+	}
+	ct.Resources = res
+
+	return ct, nil
+}
+
+func (c *Replica) StatefulSet() *appsv1.StatefulSet {
+	return c.sfs
+}
+
+func (c *Replica) Labels() map[string]string {
+	return c.labels
+}
+
+func (c *Replica) Service() string {
+	return c.service
+}
+
+// UpdateStrategy is always rolling, replicas aren't behind the proxies
+// so SmartUpdate doesn't apply to them
+func (c *Replica) UpdateStrategy(cr *api.PerconaXtraDBCluster) appsv1.StatefulSetUpdateStrategy {
+	if cr.Spec.UpdateStrategy == appsv1.OnDeleteStatefulSetStrategyType {
+		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	}
+
+	var zero int32 = 0
+	return appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+			Partition: &zero,
+		},
+	}
+}
@@ -393,9 +393,17 @@ func S3RestoreJob(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClu
 			Name:  "PITR_GTID",
 			Value: cr.Spec.PITR.GTID,
 		})
+		date := cr.Spec.PITR.Date
+		if cr.Spec.PITR.Type == api.PITRTypeDate {
+			t, err := cr.Spec.PITR.Time()
+			if err != nil {
+				return nil, errors.Wrap(err, "get PITR date")
+			}
+			date = t.UTC().Format(api.PITRDateFormat)
+		}
 		envs = append(envs, corev1.EnvVar{
 			Name:  "PITR_DATE",
-			Value: cr.Spec.PITR.Date,
+			Value: date,
 		})
 		jobName = "pitr-job-" + cr.Name + "-" + cr.Spec.PXCCluster
 		volumeMounts = []corev1.VolumeMount{}
@@ -465,6 +473,25 @@ func S3RestoreJob(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClu
 	return job, nil
 }
 
+// RestoreValidateJob returns the job object that checks the backup
+// and binlogs (if PITR is requested) before the cluster is stopped
+func RestoreValidateJob(cr *api.PerconaXtraDBClusterRestore, bcp *api.PerconaXtraDBClusterBackup, s3dest string, cluster api.PerconaXtraDBClusterSpec) (*batchv1.Job, error) {
+	job, err := S3RestoreJob(cr, bcp, s3dest, cluster, cr.Spec.PITR != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Name = "restore-validate-job-" + cr.Name + "-" + cr.Spec.PXCCluster
+	// the datadir is still used by the running cluster
+	job.Spec.Template.Spec.Volumes = nil
+	job.Spec.Template.Spec.Containers[0].VolumeMounts = nil
+	job.Spec.Template.Spec.Containers[0].Command = []string{"pitr", "validate"}
+	job.Spec.Template.Spec.Containers[0].TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	job.Spec.BackoffLimit = func(i int32) *int32 { return &i }(1)
+
+	return job, nil
+}
+
 func xbMemoryUse(cluster api.PerconaXtraDBClusterSpec) (useMem string, k8sQuantity resource.Quantity, err error) {
 	var memory string
 
@@ -5,8 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	v "github.com/hashicorp/go-version"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -16,12 +20,18 @@ import (
 // https://github.com/percona/percona-docker/blob/pxc-operator-1.3.0/proxysql/dockerdir/etc/proxysql-admin.cnf#L23
 const writerID = 11
 
+// backup writers are the nodes that can be promoted if the writer fails
+const backupWriterID = 12
+
 type Database struct {
 	db *sql.DB
 }
 
 var ErrNotFound = errors.New("not found")
 
+// connectTimeout limits the dial to the pods on the unreachable nodes
+const connectTimeout = 10 * time.Second
+
 func New(client client.Client, namespace, secretName, user, host string, port int32) (Database, error) {
 	secretObj := corev1.Secret{}
 	err := client.Get(context.TODO(),
@@ -36,7 +46,7 @@ func New(client client.Client, namespace, secretName, user, host string, port in
 	}
 
 	pass := string(secretObj.Data[user])
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/mysql?interpolateParams=true", user, pass, host, port)
+	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/mysql?interpolateParams=true&timeout=%s", user, pass, host, port, connectTimeout)
 	db, err := sql.Open("mysql", connStr)
 	if err != nil {
 		return Database{}, err
@@ -89,6 +99,69 @@ func (p *Database) PrimaryHost() (string, error) {
 	return host, nil
 }
 
+// SetPrimary makes ProxySQL send the writes to the host. The writer is the node with the highest weight
+// in the writer hostgroups, the change is synced to the other proxies of the ProxySQL cluster.
+func (p *Database) SetPrimary(hostPrefix string) error {
+	res, err := p.db.Exec("UPDATE mysql_servers SET weight = CASE WHEN hostname LIKE ? THEN 1000000 ELSE 1000 END WHERE hostgroup_id IN (?, ?)",
+		hostPrefix+"%", writerID, backupWriterID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	for _, q := range []string{"LOAD MYSQL SERVERS TO RUNTIME", "SAVE MYSQL SERVERS TO DISK"} {
+		if _, err := p.db.Exec(q); err != nil {
+			return fmt.Errorf("%s: %v", strings.ToLower(q), err)
+		}
+	}
+
+	return nil
+}
+
+// ActiveTransactions returns the number of the open InnoDB transactions of the other connections
+func (p *Database) ActiveTransactions() (int, error) {
+	var count int
+	err := p.db.QueryRow("SELECT COUNT(*) FROM information_schema.innodb_trx WHERE trx_mysql_thread_id != CONNECTION_ID()").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// PauseProxySQL stops accepting the new client connections, the current ones are served
+func (p *Database) PauseProxySQL() error {
+	_, err := p.db.Exec("PROXYSQL PAUSE")
+	return err
+}
+
+// ProxySQLClientConnections returns the number of the client connections to ProxySQL
+func (p *Database) ProxySQLClientConnections() (int, error) {
+	var conns int
+	err := p.db.QueryRow("SELECT Variable_Value FROM stats_mysql_global WHERE Variable_Name = 'Client_Connections_connected'").Scan(&conns)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	return conns, nil
+}
+
+// ProxySQLOnlineServers returns the number of the backend servers ProxySQL sees online
+func (p *Database) ProxySQLOnlineServers() (int, error) {
+	var count int
+	err := p.db.QueryRow("SELECT COUNT(*) FROM runtime_mysql_servers WHERE status = 'ONLINE'").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func (p *Database) Hostname() (string, error) {
 	var hostname string
 	err := p.db.QueryRow("SELECT @@hostname hostname").Scan(&hostname)
@@ -117,6 +190,32 @@ func (p *Database) WsrepLocalStateComment() (string, error) {
 	return value, nil
 }
 
+// WsrepStatus returns the wsrep status variables of the node
+func (p *Database) WsrepStatus() (map[string]string, error) {
+	rows, err := p.db.Query("SHOW GLOBAL STATUS LIKE 'wsrep_%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		status[name] = value
+	}
+
+	return status, rows.Err()
+}
+
+// BootstrapPrimaryComponent makes the non-Primary node a new primary component of the cluster
+func (p *Database) BootstrapPrimaryComponent() error {
+	_, err := p.db.Exec("SET GLOBAL wsrep_provider_options='pc.bootstrap=YES'")
+	return err
+}
+
 func (p *Database) Version() (string, error) {
 	var version string
 
@@ -134,3 +233,287 @@ func (p *Database) Version() (string, error) {
 func (p *Database) Close() error {
 	return p.db.Close()
 }
+
+type ReplicationSource struct {
+	Host   string
+	Port   int
+	Weight int
+}
+
+// ReplicationChannelSources returns the asynchronous connection failover sources of the channel
+func (p *Database) ReplicationChannelSources(channel string) ([]ReplicationSource, error) {
+	rows, err := p.db.Query("SELECT host, port, weight FROM mysql.replication_asynchronous_connection_failover WHERE channel_name = ? ORDER BY weight DESC", channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []ReplicationSource{}
+	for rows.Next() {
+		var src ReplicationSource
+		err := rows.Scan(&src.Host, &src.Port, &src.Weight)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	return sources, rows.Err()
+}
+
+func (p *Database) AddReplicationSource(channel string, src ReplicationSource) error {
+	_, err := p.db.Exec("SELECT asynchronous_connection_failover_add_source(?, ?, ?, null, ?)", channel, src.Host, src.Port, src.Weight)
+	return err
+}
+
+func (p *Database) DeleteReplicationSource(channel string, src ReplicationSource) error {
+	_, err := p.db.Exec("SELECT asynchronous_connection_failover_delete_source(?, ?, ?, null)", channel, src.Host, src.Port)
+	return err
+}
+
+type ReplicationStatus struct {
+	// State is the state of the receiver thread: ON, OFF or CONNECTING
+	State        string
+	Host         string
+	Port         int
+	LastErrorNum int
+	LastError    string
+}
+
+// ReplicationStatus returns the connection status of the channel
+// or ErrNotFound if the channel doesn't exist
+func (p *Database) ReplicationStatus(channel string) (ReplicationStatus, error) {
+	var status ReplicationStatus
+	err := p.db.QueryRow(`SELECT conn.SERVICE_STATE, conf.HOST, conf.PORT, conn.LAST_ERROR_NUMBER, conn.LAST_ERROR_MESSAGE
+		FROM performance_schema.replication_connection_status conn
+		JOIN performance_schema.replication_connection_configuration conf USING (CHANNEL_NAME)
+		WHERE conn.CHANNEL_NAME = ?`, channel).Scan(&status.State, &status.Host, &status.Port, &status.LastErrorNum, &status.LastError)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return status, ErrNotFound
+		}
+		return status, err
+	}
+
+	return status, nil
+}
+
+// ReplicationChannels returns the names of all replication channels
+func (p *Database) ReplicationChannels() ([]string, error) {
+	rows, err := p.db.Query("SELECT DISTINCT CHANNEL_NAME FROM performance_schema.replication_connection_configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []string{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, name)
+	}
+
+	return channels, rows.Err()
+}
+
+// replicaSyntaxVersion is the first version with the SOURCE/REPLICA replication
+// statements and the asynchronous connection failover
+var replicaSyntaxVersion = v.Must(v.NewVersion("8.0.22"))
+
+// ReplicaSyntaxSupported checks if the server has the SOURCE/REPLICA replication statements
+// and the asynchronous connection failover. The older servers use MASTER/SLAVE ones,
+// the channel doesn't fail over to the other sources by itself.
+func (p *Database) ReplicaSyntaxSupported() (bool, error) {
+	version, err := p.Version()
+	if err != nil {
+		return false, fmt.Errorf("get version: %v", err)
+	}
+	// the build suffix, e.g. 8.0.22-13.1, isn't a pre-release
+	ver, err := v.NewVersion(strings.Split(version, "-")[0])
+	if err != nil {
+		return false, fmt.Errorf("parse version %s: %v", version, err)
+	}
+
+	return ver.Compare(replicaSyntaxVersion) >= 0, nil
+}
+
+// StartReplication points the channel to the source and starts it.
+// Since 8.0.22 the channel fails over to other sources of the channel by itself.
+func (p *Database) StartReplication(channel, user, pass string, src ReplicationSource) error {
+	replica, err := p.ReplicaSyntaxSupported()
+	if err != nil {
+		return err
+	}
+
+	if replica {
+		_, err = p.db.Exec(`CHANGE REPLICATION SOURCE TO
+			SOURCE_USER = ?,
+			SOURCE_PASSWORD = ?,
+			SOURCE_HOST = ?,
+			SOURCE_PORT = ?,
+			SOURCE_SSL = 1,
+			SOURCE_AUTO_POSITION = 1,
+			SOURCE_CONNECTION_AUTO_FAILOVER = 1,
+			SOURCE_RETRY_COUNT = 3,
+			SOURCE_CONNECT_RETRY = 60
+			FOR CHANNEL ?`, user, pass, src.Host, src.Port, channel)
+	} else {
+		_, err = p.db.Exec(`CHANGE MASTER TO
+			MASTER_USER = ?,
+			MASTER_PASSWORD = ?,
+			MASTER_HOST = ?,
+			MASTER_PORT = ?,
+			MASTER_SSL = 1,
+			MASTER_AUTO_POSITION = 1,
+			MASTER_RETRY_COUNT = 3,
+			MASTER_CONNECT_RETRY = 60
+			FOR CHANNEL ?`, user, pass, src.Host, src.Port, channel)
+	}
+	if err != nil {
+		return fmt.Errorf("change replication source: %v", err)
+	}
+
+	_, err = p.db.Exec(replicaStatement("START REPLICA", replica)+" FOR CHANNEL ?", channel)
+	if err != nil {
+		return fmt.Errorf("start replica: %v", err)
+	}
+
+	return nil
+}
+
+func (p *Database) StopReplication(channel string) error {
+	replica, err := p.ReplicaSyntaxSupported()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.Exec(replicaStatement("STOP REPLICA", replica)+" FOR CHANNEL ?", channel)
+	return err
+}
+
+// replicaStatement returns the statement with the SLAVE keyword for the servers before 8.0.22
+func replicaStatement(stmt string, replica bool) string {
+	if replica {
+		return stmt
+	}
+	return strings.Replace(stmt, "REPLICA", "SLAVE", 1)
+}
+
+// DeleteReplicationChannel stops the channel and removes its configuration
+func (p *Database) DeleteReplicationChannel(channel string) error {
+	err := p.StopReplication(channel)
+	if err != nil {
+		return fmt.Errorf("stop replica: %v", err)
+	}
+
+	replica, err := p.ReplicaSyntaxSupported()
+	if err != nil {
+		return err
+	}
+
+	if replica {
+		sources, err := p.ReplicationChannelSources(channel)
+		if err != nil {
+			return fmt.Errorf("get sources: %v", err)
+		}
+		for _, src := range sources {
+			err = p.DeleteReplicationSource(channel, src)
+			if err != nil {
+				return fmt.Errorf("delete source %s: %v", src.Host, err)
+			}
+		}
+	}
+
+	_, err = p.db.Exec(replicaStatement("RESET REPLICA", replica)+" ALL FOR CHANNEL ?", channel)
+	if err != nil {
+		return fmt.Errorf("reset replica: %v", err)
+	}
+
+	return nil
+}
+
+type ReplicaStatus struct {
+	SourceHost          string
+	SourcePort          int
+	IORunning           string
+	SQLRunning          string
+	SecondsBehindSource *int64
+	LastIOError         string
+	LastSQLError        string
+	RetrievedGTIDSet    string
+	ExecutedGTIDSet     string
+}
+
+// ReplicaStatus returns SHOW REPLICA STATUS of the channel
+// or ErrNotFound if the channel doesn't exist
+func (p *Database) ReplicaStatus(channel string) (ReplicaStatus, error) {
+	var status ReplicaStatus
+
+	replica, err := p.ReplicaSyntaxSupported()
+	if err != nil {
+		return status, err
+	}
+
+	rows, err := p.db.Query(replicaStatement("SHOW REPLICA", replica)+" STATUS FOR CHANNEL ?", channel)
+	if err != nil {
+		return status, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return status, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return status, err
+		}
+		return status, ErrNotFound
+	}
+
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err = rows.Scan(dest...)
+	if err != nil {
+		return status, err
+	}
+
+	for i, col := range cols {
+		v := values[i].String
+		// the servers before 8.0.22 name the columns Master and Slave
+		switch col {
+		case "Source_Host", "Master_Host":
+			status.SourceHost = v
+		case "Source_Port", "Master_Port":
+			status.SourcePort, _ = strconv.Atoi(v)
+		case "Replica_IO_Running", "Slave_IO_Running":
+			status.IORunning = v
+		case "Replica_SQL_Running", "Slave_SQL_Running":
+			status.SQLRunning = v
+		case "Seconds_Behind_Source", "Seconds_Behind_Master":
+			if values[i].Valid {
+				sec, err := strconv.ParseInt(v, 10, 64)
+				if err == nil {
+					status.SecondsBehindSource = &sec
+				}
+			}
+		case "Last_IO_Error":
+			status.LastIOError = v
+		case "Last_SQL_Error":
+			status.LastSQLError = v
+		// gtid sets are split into lines by the server
+		case "Retrieved_Gtid_Set":
+			status.RetrievedGTIDSet = strings.ReplaceAll(v, "\n", "")
+		case "Executed_Gtid_Set":
+			status.ExecutedGTIDSet = strings.ReplaceAll(v, "\n", "")
+		}
+	}
+
+	return status, nil
+}
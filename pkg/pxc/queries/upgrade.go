@@ -0,0 +1,152 @@
+package queries
+
+import (
+	"strings"
+)
+
+// removedSQLModes are the sql_mode values that don't exist in MySQL 8.0
+var removedSQLModes = []string{
+	"DB2", "MAXDB", "MSSQL", "MYSQL323", "MYSQL40", "ORACLE", "POSTGRESQL",
+	"NO_AUTO_CREATE_USER", "NO_FIELD_OPTIONS", "NO_KEY_OPTIONS", "NO_TABLE_OPTIONS",
+}
+
+// reservedWords are the keywords that became reserved in MySQL 8.0
+var reservedWords = []string{
+	"CUBE", "CUME_DIST", "DENSE_RANK", "EMPTY", "EXCEPT", "FIRST_VALUE", "FUNCTION",
+	"GROUPING", "GROUPS", "JSON_TABLE", "LAG", "LAST_VALUE", "LATERAL", "LEAD",
+	"NTH_VALUE", "NTILE", "OF", "OVER", "PERCENT_RANK", "RANK", "RECURSIVE",
+	"ROW", "ROWS", "ROW_NUMBER", "SYSTEM", "WINDOW",
+}
+
+// dictionaryTables are the data dictionary tables of MySQL 8.0,
+// the tables with the same names can't be kept in the mysql schema
+var dictionaryTables = []string{
+	"catalogs", "character_sets", "collations", "column_statistics", "column_type_elements",
+	"columns", "dd_properties", "events", "foreign_key_column_usage", "foreign_keys",
+	"index_column_usage", "index_partitions", "index_stats", "indexes",
+	"parameter_type_elements", "parameters", "resource_groups", "routines", "schemata",
+	"st_spatial_reference_systems", "table_partition_values", "table_partitions",
+	"table_stats", "tables", "tablespace_files", "tablespaces", "triggers",
+	"view_routine_usage", "view_table_usage",
+}
+
+const systemSchemas = "('mysql', 'sys', 'information_schema', 'performance_schema')"
+
+// RemovedSQLModes returns the modes of the sql_mode value that were removed in MySQL 8.0
+func RemovedSQLModes(sqlMode string) []string {
+	found := []string{}
+	for _, m := range strings.Split(strings.ToUpper(sqlMode), ",") {
+		for _, r := range removedSQLModes {
+			if strings.TrimSpace(m) == r {
+				found = append(found, r)
+			}
+		}
+	}
+
+	return found
+}
+
+// UpgradeCheck runs the checks of the MySQL upgrade checker that can be done with SQL.
+// It returns the problems that break the upgrade from 5.7 to 8.0 and the warnings
+// about the things that may break the applications, e.g. the identifiers that became
+// reserved words work in 8.0 only if they are quoted.
+func (p *Database) UpgradeCheck() (findings, warnings []string, err error) {
+	findings, warnings = []string{}, []string{}
+
+	var sqlMode string
+	err = p.db.QueryRow("SELECT @@GLOBAL.sql_mode").Scan(&sqlMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range RemovedSQLModes(sqlMode) {
+		findings = append(findings, "removed sql_mode: "+m)
+	}
+
+	words := sqlList(reservedWords)
+	checks := []struct {
+		finding string
+		query   string
+		warning bool
+	}{
+		{
+			finding: "reserved word used as identifier",
+			warning: true,
+			query: "SELECT SCHEMA_NAME FROM information_schema.SCHEMATA WHERE UPPER(SCHEMA_NAME) IN " + words +
+				" UNION ALL SELECT CONCAT(TABLE_SCHEMA, '.', TABLE_NAME) FROM information_schema.TABLES" +
+				" WHERE TABLE_SCHEMA NOT IN " + systemSchemas + " AND UPPER(TABLE_NAME) IN " + words +
+				" UNION ALL SELECT CONCAT(TABLE_SCHEMA, '.', TABLE_NAME, '.', COLUMN_NAME) FROM information_schema.COLUMNS" +
+				" WHERE TABLE_SCHEMA NOT IN " + systemSchemas + " AND UPPER(COLUMN_NAME) IN " + words +
+				" UNION ALL SELECT CONCAT(ROUTINE_SCHEMA, '.', ROUTINE_NAME) FROM information_schema.ROUTINES" +
+				" WHERE ROUTINE_SCHEMA NOT IN " + systemSchemas + " AND UPPER(ROUTINE_NAME) IN " + words +
+				" UNION ALL SELECT CONCAT(TRIGGER_SCHEMA, '.', TRIGGER_NAME) FROM information_schema.TRIGGERS" +
+				" WHERE TRIGGER_SCHEMA NOT IN " + systemSchemas + " AND UPPER(TRIGGER_NAME) IN " + words +
+				" UNION ALL SELECT CONCAT(EVENT_SCHEMA, '.', EVENT_NAME) FROM information_schema.EVENTS" +
+				" WHERE EVENT_SCHEMA NOT IN " + systemSchemas + " AND UPPER(EVENT_NAME) IN " + words,
+		},
+		{
+			finding: "removed function used",
+			query: "SELECT CONCAT(TABLE_SCHEMA, '.', TABLE_NAME) FROM information_schema.VIEWS" +
+				" WHERE TABLE_SCHEMA NOT IN " + systemSchemas + " AND VIEW_DEFINITION REGEXP " + removedFunctionsRegexp +
+				" UNION ALL SELECT CONCAT(ROUTINE_SCHEMA, '.', ROUTINE_NAME) FROM information_schema.ROUTINES" +
+				" WHERE ROUTINE_SCHEMA NOT IN " + systemSchemas + " AND ROUTINE_DEFINITION REGEXP " + removedFunctionsRegexp +
+				" UNION ALL SELECT CONCAT(TRIGGER_SCHEMA, '.', TRIGGER_NAME) FROM information_schema.TRIGGERS" +
+				" WHERE TRIGGER_SCHEMA NOT IN " + systemSchemas + " AND ACTION_STATEMENT REGEXP " + removedFunctionsRegexp +
+				" UNION ALL SELECT CONCAT(EVENT_SCHEMA, '.', EVENT_NAME) FROM information_schema.EVENTS" +
+				" WHERE EVENT_SCHEMA NOT IN " + systemSchemas + " AND EVENT_DEFINITION REGEXP " + removedFunctionsRegexp,
+		},
+		{
+			finding: "partitioned table without native partitioning",
+			query: "SELECT DISTINCT CONCAT(p.TABLE_SCHEMA, '.', p.TABLE_NAME) FROM information_schema.PARTITIONS p" +
+				" JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = p.TABLE_SCHEMA AND t.TABLE_NAME = p.TABLE_NAME" +
+				" WHERE p.PARTITION_NAME IS NOT NULL AND t.ENGINE NOT IN ('InnoDB', 'ndbcluster')",
+		},
+		{
+			finding: "table conflicts with the data dictionary",
+			query: "SELECT CONCAT(TABLE_SCHEMA, '.', TABLE_NAME) FROM information_schema.TABLES" +
+				" WHERE TABLE_SCHEMA = 'mysql' AND LOWER(TABLE_NAME) IN " + sqlList(dictionaryTables),
+		},
+	}
+
+	for _, c := range checks {
+		objects, err := p.queryStrings(c.query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, o := range objects {
+			if c.warning {
+				warnings = append(warnings, c.finding+": "+o)
+				continue
+			}
+			findings = append(findings, c.finding+": "+o)
+		}
+	}
+
+	return findings, warnings, nil
+}
+
+// removedFunctionsRegexp matches the calls of the functions removed in MySQL 8.0
+const removedFunctionsRegexp = "'[[:<:]](ENCODE|DECODE|ENCRYPT|DES_ENCRYPT|DES_DECRYPT|PASSWORD|JSON_APPEND)[[:space:]]*[(]'"
+
+func (p *Database) queryStrings(query string) ([]string, error) {
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []string{}
+	for rows.Next() {
+		var s string
+		err := rows.Scan(&s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	return list, rows.Err()
+}
+
+func sqlList(values []string) string {
+	return "('" + strings.Join(values, "', '") + "')"
+}
@@ -197,7 +197,7 @@ func NewServiceProxySQL(cr *api.PerconaXtraDBCluster) *corev1.Service {
 			},
 			Selector: map[string]string{
 				"app.kubernetes.io/name":      "percona-xtradb-cluster",
-				"app.kubernetes.io/instance":  cr.Name,
+				"app.kubernetes.io/instance":  cr.ServingCluster(),
 				"app.kubernetes.io/component": "proxysql",
 			},
 			LoadBalancerSourceRanges: loadBalancerSourceRanges,
@@ -226,6 +226,7 @@ func NewServiceProxySQL(cr *api.PerconaXtraDBCluster) *corev1.Service {
 		obj.ObjectMeta.Labels["app.kubernetes.io/component"] = "proxysql"
 		obj.ObjectMeta.Labels["app.kubernetes.io/managed-by"] = "percona-xtradb-cluster-operator"
 		obj.ObjectMeta.Labels["app.kubernetes.io/part-of"] = "percona-xtradb-cluster"
+		obj.Spec.Selector[api.ProxyServingLabel] = "true"
 	}
 
 	return obj
@@ -278,7 +279,7 @@ func NewServiceHAProxy(cr *api.PerconaXtraDBCluster, owners ...metav1.OwnerRefer
 			},
 			Selector: map[string]string{
 				"app.kubernetes.io/name":      "percona-xtradb-cluster",
-				"app.kubernetes.io/instance":  cr.Name,
+				"app.kubernetes.io/instance":  cr.ServingCluster(),
 				"app.kubernetes.io/component": "haproxy",
 			},
 			LoadBalancerSourceRanges: loadBalancerSourceRanges,
@@ -305,6 +306,10 @@ func NewServiceHAProxy(cr *api.PerconaXtraDBCluster, owners ...metav1.OwnerRefer
 		)
 	}
 
+	if cr.CompareVersionWith("1.9.0") >= 0 {
+		obj.Spec.Selector[api.ProxyServingLabel] = "true"
+	}
+
 	return obj
 }
 
@@ -348,7 +353,7 @@ func NewServiceHAProxyReplicas(cr *api.PerconaXtraDBCluster, owners ...metav1.Ow
 			},
 			Selector: map[string]string{
 				"app.kubernetes.io/name":      "percona-xtradb-cluster",
-				"app.kubernetes.io/instance":  cr.Name,
+				"app.kubernetes.io/instance":  cr.ServingCluster(),
 				"app.kubernetes.io/component": "haproxy",
 			},
 			LoadBalancerSourceRanges: loadBalancerSourceRanges,
@@ -364,5 +369,104 @@ func NewServiceHAProxyReplicas(cr *api.PerconaXtraDBCluster, owners ...metav1.Ow
 		obj.Spec.ExternalTrafficPolicy = svcTrafficPolicyType
 	}
 
+	if cr.CompareVersionWith("1.9.0") >= 0 {
+		obj.Spec.Selector[api.ProxyServingLabel] = "true"
+	}
+
 	return obj
 }
+
+// NewServiceReplicas is the service the clients connect to the read replicas with
+func NewServiceReplicas(cr *api.PerconaXtraDBCluster) *corev1.Service {
+	svcType := corev1.ServiceTypeClusterIP
+	var annotations map[string]string
+	var loadBalancerSourceRanges []string
+	if cr.Spec.Replicas != nil {
+		if len(cr.Spec.Replicas.ServiceType) > 0 {
+			svcType = cr.Spec.Replicas.ServiceType
+		}
+		annotations = cr.Spec.Replicas.ServiceAnnotations
+		loadBalancerSourceRanges = cr.Spec.Replicas.LoadBalancerSourceRanges
+	}
+
+	obj := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cr.Name + "-replicas",
+			Namespace:   cr.Namespace,
+			Labels:      replicasLabels(cr),
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: svcType,
+			Ports: []corev1.ServicePort{
+				{
+					Port: 3306,
+					Name: "mysql",
+				},
+			},
+			Selector:                 replicasSelector(cr),
+			LoadBalancerSourceRanges: loadBalancerSourceRanges,
+		},
+	}
+
+	if svcType == corev1.ServiceTypeLoadBalancer || svcType == corev1.ServiceTypeNodePort {
+		obj.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		if len(cr.Spec.Replicas.ExternalTrafficPolicy) > 0 {
+			obj.Spec.ExternalTrafficPolicy = cr.Spec.Replicas.ExternalTrafficPolicy
+		}
+	}
+
+	return obj
+}
+
+// NewServiceReplicasUnready is the headless service of the replicas statefulset
+func NewServiceReplicasUnready(cr *api.PerconaXtraDBCluster) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name + "-replicas-unready",
+			Namespace: cr.Namespace,
+			Labels:    replicasLabels(cr),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Port: 3306,
+					Name: "mysql",
+				},
+				{
+					Port: 33062,
+					Name: "mysql-admin",
+				},
+			},
+			ClusterIP:                "None",
+			PublishNotReadyAddresses: true,
+			Selector:                 replicasSelector(cr),
+		},
+	}
+}
+
+func replicasLabels(cr *api.PerconaXtraDBCluster) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "percona-xtradb-cluster",
+		"app.kubernetes.io/instance":   cr.Name,
+		"app.kubernetes.io/component":  "replicas",
+		"app.kubernetes.io/managed-by": "percona-xtradb-cluster-operator",
+		"app.kubernetes.io/part-of":    "percona-xtradb-cluster",
+	}
+}
+
+func replicasSelector(cr *api.PerconaXtraDBCluster) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      "percona-xtradb-cluster",
+		"app.kubernetes.io/instance":  cr.Name,
+		"app.kubernetes.io/component": "replicas",
+	}
+}
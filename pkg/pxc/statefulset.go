@@ -114,6 +114,10 @@ func StatefulSet(sfs api.StatefulApp, podSpec *api.PodSpec, cr *api.PerconaXtraD
 		}
 	}
 
+	if HasServingLabel(sfs, cr) {
+		customLabels[api.ProxyServingLabel] = "true"
+	}
+
 	obj := sfs.StatefulSet()
 	obj.Spec = appsv1.StatefulSetSpec{
 		Replicas: &podSpec.Size,
@@ -128,7 +132,7 @@ func StatefulSet(sfs api.StatefulApp, podSpec *api.PodSpec, cr *api.PerconaXtraD
 			},
 			Spec: pod,
 		},
-		UpdateStrategy: sfs.UpdateStrategy(cr),
+		UpdateStrategy: UpdateStrategy(sfs, podSpec, cr),
 	}
 
 	if sfsVolume != nil && sfsVolume.PVCs != nil {
@@ -138,6 +142,12 @@ func StatefulSet(sfs api.StatefulApp, podSpec *api.PodSpec, cr *api.PerconaXtraD
 	return obj, nil
 }
 
+// HasServingLabel is true if the services select the pods of the app by the api.ProxyServingLabel
+func HasServingLabel(sfs api.StatefulApp, cr *api.PerconaXtraDBCluster) bool {
+	component := sfs.Labels()["app.kubernetes.io/component"]
+	return (component == "haproxy" || component == "proxysql") && cr.CompareVersionWith("1.9.0") >= 0
+}
+
 // PodAffinity returns podAffinity options for the pod
 func PodAffinity(af *api.PodAffinity, app api.App) *corev1.Affinity {
 	if af == nil {
@@ -179,3 +189,15 @@ func MergeTemplateAnnotations(sfs *appsv1.StatefulSet, annotations map[string]st
 		sfs.Spec.Template.Annotations[k] = v
 	}
 }
+
+// UpdateStrategy returns the update strategy of the app. The rolling update
+// is stopped with the partition while the pod restarts are deferred.
+func UpdateStrategy(sfs api.StatefulApp, podSpec *api.PodSpec, cr *api.PerconaXtraDBCluster) appsv1.StatefulSetUpdateStrategy {
+	strategy := sfs.UpdateStrategy(cr)
+	if cr.RestartsDeferred() && strategy.Type == appsv1.RollingUpdateStatefulSetStrategyType {
+		partition := podSpec.Size
+		strategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	}
+
+	return strategy
+}
@@ -291,3 +291,47 @@ func (u *Manager) Update170XtrabackupUser(pass string) (err error) {
 
 	return nil
 }
+
+// CreateReplicationUser creates the user replica channels of other clusters connect with
+// or updates its password
+func (u *Manager) CreateReplicationUser(name, pass string) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			errT := tx.Rollback()
+			if errT != nil {
+				err = errors.Wrapf(err, "rollback error: %v, transaction failed with", errT)
+			}
+			return
+		}
+
+		err = tx.Commit()
+		err = errors.Wrap(err, "commit transaction")
+	}()
+
+	_, err = tx.Exec("CREATE USER IF NOT EXISTS ?@'%' IDENTIFIED BY ?", name, pass)
+	if err != nil {
+		return errors.Wrapf(err, "create user %s", name)
+	}
+
+	_, err = tx.Exec("ALTER USER ?@'%' IDENTIFIED BY ?", name, pass)
+	if err != nil {
+		return errors.Wrapf(err, "update password of user %s", name)
+	}
+
+	_, err = tx.Exec("GRANT REPLICATION SLAVE ON *.* TO ?@'%'", name)
+	if err != nil {
+		return errors.Wrapf(err, "grant privileges to user %s", name)
+	}
+
+	_, err = tx.Exec("FLUSH PRIVILEGES")
+	if err != nil {
+		return errors.Wrap(err, "flush privileges")
+	}
+
+	return nil
+}